@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/archive"
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// runExportCommand implements the "export" subcommand
+func runExportCommand(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	formatStr := flags.String("format", "", "output format: csv, jsonl or parquet (default: inferred from -out)")
+	out := flags.String("out", "", "output file (default: stdout)")
+	fromStr := flags.String("from", "0", "start of the range (RFC 3339 or Unix seconds)")
+	toStr := flags.String("to", "", "end of the range (RFC 3339 or Unix seconds, default: everything)")
+	cfg := loadConfig(flags, args)
+
+	format := resolveFormat(*formatStr, *out)
+
+	from, err := parseTime(*fromStr)
+	if err != nil {
+		logging.Fatal("Invalid -from", "error", err)
+	}
+
+	to := int64(math.MaxInt64)
+	if *toStr != "" {
+		parsed, err := parseTime(*toStr)
+		if err != nil {
+			logging.Fatal("Invalid -to", "error", err)
+		}
+		to = parsed.Unix()
+	}
+
+	eventStore, closeStore := openCommandStore(cfg)
+	iterableStore, ok := eventStore.(store.IterableStore)
+	if !ok {
+		_ = closeStore()
+		logging.Fatal("Configured store does not support export")
+	}
+
+	var output io.Writer = os.Stdout
+	closeOutput := func() error { return nil }
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			_ = closeStore()
+			logging.Fatal("Error creating output file", "path", *out, "error", err)
+		}
+		output = file
+		closeOutput = file.Close
+	}
+
+	writer, err := archive.NewWriter(format, output)
+	if err != nil {
+		_ = errors.Join(closeOutput(), closeStore())
+		logging.Fatal("Error creating writer", "error", err)
+	}
+
+	// logging.Fatal exits without running deferred calls, so the file and
+	// store are closed first and their errors fail the export
+	count, err := archive.Export(iterableStore, from.Unix(), to, writer)
+	if err = errors.Join(err, closeOutput(), closeStore()); err != nil {
+		logging.Fatal("Export failed", "exported", count, "error", err)
+	}
+	slog.Info("Export complete", "exported", count)
+}
+
+// runImportCommand implements the "import" subcommand
+func runImportCommand(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	formatStr := flags.String("format", "", "input format: csv, jsonl or parquet (default: inferred from -in)")
+	in := flags.String("in", "", "input file (default: stdin, not supported for parquet)")
+	cfg := loadConfig(flags, args)
+
+	format := resolveFormat(*formatStr, *in)
+
+	eventStore, closeStore := openCommandStore(cfg)
+	backfillStore, ok := eventStore.(store.BackfillStore)
+	if !ok {
+		_ = closeStore()
+		logging.Fatal("Configured store does not support import")
+	}
+
+	input := os.Stdin
+	if *in != "" {
+		file, err := os.Open(*in)
+		if err != nil {
+			_ = closeStore()
+			logging.Fatal("Error opening input file", "path", *in, "error", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	reader, err := archive.NewReader(format, input)
+	if err != nil {
+		_ = closeStore()
+		logging.Fatal("Error creating reader", "error", err)
+	}
+
+	// The store is closed before logging.Fatal, which skips deferred calls,
+	// so buffered writes are flushed and a failed flush fails the import
+	count, err := archive.Import(reader, backfillStore)
+	if err = errors.Join(err, closeStore()); err != nil {
+		logging.Fatal("Import failed", "imported", count, "error", err)
+	}
+	slog.Info("Import complete", "imported", count)
+}
+
+func resolveFormat(name string, path string) archive.Format {
+	var (
+		format archive.Format
+		err    error
+	)
+
+	switch {
+	case name != "":
+		format, err = archive.ParseFormat(name)
+	case path != "":
+		format, err = archive.FormatFromPath(path)
+	default:
+		format = archive.FormatJSONL
+	}
+
+	if err != nil {
+		logging.Fatal("Invalid format", "error", err)
+	}
+	return format
+}
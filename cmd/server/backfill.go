@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/backfill"
+	"github.com/tstojkovski/btc-price-tracker/internal/config"
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/internal/service"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// runBackfillCommand implements the "backfill" subcommand
+func runBackfillCommand(args []string) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fromStr := flags.String("from", "", "start of the range (RFC 3339 or Unix seconds, required)")
+	toStr := flags.String("to", "", "end of the range (RFC 3339 or Unix seconds, default: now)")
+	source := flags.String("source", "", "history source: binance or coingecko (default: the configured provider)")
+	gap := flags.Duration("gap", 0, "minimum period without events treated as a gap (default: backfill.gap_threshold)")
+	cfg := loadConfig(flags, args)
+
+	if *fromStr == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	from, err := parseTime(*fromStr)
+	if err != nil {
+		logging.Fatal("Invalid -from", "error", err)
+	}
+
+	to := time.Now()
+	if *toStr != "" {
+		if to, err = parseTime(*toStr); err != nil {
+			logging.Fatal("Invalid -to", "error", err)
+		}
+	}
+
+	if *source == "" {
+		*source = cfg.Provider.Name
+	}
+	historyProvider, err := historySource(*source)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -source: %v\n", err)
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *gap <= 0 {
+		*gap = cfg.Backfill.GapThreshold
+	}
+
+	eventStore, closeStore := openCommandStore(cfg)
+	backfillStore, ok := eventStore.(store.BackfillStore)
+	if !ok {
+		_ = closeStore()
+		logging.Fatal("Configured store does not support backfill")
+	}
+
+	backfiller := backfill.NewBackfiller(backfillStore, historyProvider, *gap)
+	inserted, err := backfiller.Fill(from, to)
+	if err = errors.Join(err, closeStore()); err != nil {
+		logging.Fatal("Backfill failed", "inserted", inserted, "error", err)
+	}
+	slog.Info("Backfill complete", "inserted", inserted)
+}
+
+// startupBackfill fills gaps in the recent history when enabled
+func startupBackfill(cfg config.Config, eventStore store.EventStore) {
+	if !cfg.Backfill.OnStartup {
+		return
+	}
+
+	backfillStore, ok := eventStore.(store.BackfillStore)
+	if !ok {
+		slog.Warn("Store does not support backfill, skipping startup backfill")
+		return
+	}
+
+	now := time.Now()
+	historyProvider, err := historySource(cfg.Provider.Name)
+	if err != nil {
+		slog.Error("Startup backfill failed", "error", err)
+		return
+	}
+	backfiller := backfill.NewBackfiller(backfillStore, historyProvider, cfg.Backfill.GapThreshold)
+	inserted, err := backfiller.Fill(now.Add(-cfg.Backfill.Window), now)
+	if err != nil {
+		slog.Error("Startup backfill failed", "error", err)
+		return
+	}
+	slog.Info("Startup backfill complete", "inserted", inserted)
+}
+
+// historySource returns the history provider named name, case-insensitively
+func historySource(name string) (service.HistoricalPriceProvider, error) {
+	switch strings.ToLower(name) {
+	case config.ProviderBinance:
+		return service.NewBinancePriceProvider(), nil
+	case config.ProviderCoinGecko:
+		return service.NewCoinGeckoPriceProvider(), nil
+	default:
+		return nil, fmt.Errorf("must be %s or %s, got %q", config.ProviderBinance, config.ProviderCoinGecko, name)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/service"
+)
+
+func TestHistorySource(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected any
+	}{
+		{"binance", &service.BinancePriceProvider{}},
+		{"BINANCE", &service.BinancePriceProvider{}},
+		{"coingecko", &service.CoinGeckoPriceProvider{}},
+		{"CoinGecko", &service.CoinGeckoPriceProvider{}},
+	}
+
+	for _, tc := range tests {
+		provider, err := historySource(tc.name)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if got, want := fmt.Sprintf("%T", provider), fmt.Sprintf("%T", tc.expected); got != want {
+			t.Errorf("%s: expected %s, got %s", tc.name, want, got)
+		}
+	}
+
+	for _, name := range []string{"binnace", "kraken", ""} {
+		if _, err := historySource(name); err == nil {
+			t.Errorf("%q: expected an error", name)
+		}
+	}
+}
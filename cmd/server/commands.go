@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/config"
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// commands maps subcommand names to their implementations. Running the
+// binary without a subcommand starts the server.
+var commands = map[string]func(args []string){
+	"backfill": runBackfillCommand,
+	"config":   runConfigCommand,
+	"export":   runExportCommand,
+	"import":   runImportCommand,
+	"tail":     runTailCommand,
+}
+
+// loadConfig parses args with the common config flags registered on flags,
+// loads the effective configuration and sets up logging. Invalid
+// configuration is fatal.
+func loadConfig(flags *flag.FlagSet, args []string) config.Config {
+	cfg, _ := loadConfigWithLoader(flags, args)
+	return cfg
+}
+
+// loadConfigWithLoader is loadConfig but also returns the loader so the
+// configuration can be reloaded later
+func loadConfigWithLoader(flags *flag.FlagSet, args []string) (config.Config, *config.Loader) {
+	loader := config.NewLoader(flags)
+	_ = flags.Parse(args)
+
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if err := logging.Setup(os.Stderr, cfg.Log.Format, cfg.Log.Level); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if file := loader.File(); file != "" {
+		slog.Info("Loaded config file", "path", file)
+	}
+
+	return cfg, loader
+}
+
+// runConfigCommand implements the "config" subcommand
+func runConfigCommand(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: server config print [flags]")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ExitOnError)
+	cfg := loadConfig(flags, args[1:])
+
+	out, err := cfg.Redacted().YAML()
+	if err != nil {
+		logging.Fatal("Error rendering config", "error", err)
+	}
+	_, _ = os.Stdout.Write(out)
+}
+
+// openCommandStore opens the configured store for a one-off command. The
+// returned function flushes and closes the store. The memory store is
+// refused: the command would get its own empty store that is discarded on
+// exit, and closing it would overwrite the server's snapshot file.
+func openCommandStore(cfg config.Config) (store.EventStore, func() error) {
+	if cfg.Store.Type == store.TypeMemory {
+		logging.Fatal("This command needs a shared store; the memory store only lives inside the server process, set store.type to mongo")
+	}
+
+	eventStore, err := store.New(cfg.StoreConfig())
+	if err != nil {
+		logging.Fatal("Error opening store", "error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	backgroundStore, ok := eventStore.(store.BackgroundStore)
+	if ok {
+		backgroundStore.Start(ctx)
+	}
+
+	return eventStore, func() error {
+		cancel()
+		if !ok {
+			return nil
+		}
+		if err := backgroundStore.Close(); err != nil {
+			return fmt.Errorf("closing store: %w", err)
+		}
+		return nil
+	}
+}
+
+// parseTime accepts RFC 3339 timestamps or Unix seconds
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 or Unix seconds: %w", err)
+	}
+	return parsed, nil
+}
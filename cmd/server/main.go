@@ -1,68 +1,342 @@
 package main
 
 import (
-	"btc-price-tracker/internal/service"
-	"btc-price-tracker/internal/store"
 	"context"
-	"log"
+	"crypto/tls"
+	"flag"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/alert"
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/auth"
+	"github.com/tstojkovski/btc-price-tracker/internal/config"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/health"
+	"github.com/tstojkovski/btc-price-tracker/internal/indicator"
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+	"github.com/tstojkovski/btc-price-tracker/internal/ratelimit"
+	"github.com/tstojkovski/btc-price-tracker/internal/retention"
+	"github.com/tstojkovski/btc-price-tracker/internal/security"
+	"github.com/tstojkovski/btc-price-tracker/internal/service"
+	"github.com/tstojkovski/btc-price-tracker/internal/stats"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+	"github.com/tstojkovski/btc-price-tracker/internal/tlsconfig"
+	"github.com/tstojkovski/btc-price-tracker/internal/tracing"
+	"github.com/tstojkovski/btc-price-tracker/internal/validation"
+	"github.com/tstojkovski/btc-price-tracker/internal/webhook"
 )
 
 const (
-	serverPort      = ":8082"
-	staticDirPath   = "./static"
-	indexHtmlPath   = "static/index.html"
-	providerEnvVar  = "PRICE_PROVIDER"
-	providerBinance = "BINANCE"
+	staticDirPath = "./static"
+	indexHtmlPath = "static/index.html"
 )
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			command(os.Args[2:])
+			return
+		}
+	}
+
+	runServer(os.Args[1:])
+}
+
+// runServer starts the price pipeline and HTTP server and blocks until
+// SIGINT or SIGTERM. SIGHUP reloads the configuration.
+func runServer(args []string) {
+	cfg, loader := loadConfigWithLoader(flag.NewFlagSet("server", flag.ExitOnError), args)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing, os.Stdout)
+	if err != nil {
+		logging.Fatal("Error setting up tracing", "error", err)
+	}
+
 	// Initialize services
-	store := store.NewStoreFromConfig()
-	priceProvider := initializePriceProvider()
-	priceService := service.NewPriceService(store, priceProvider)
-	broadcastService := service.NewBroadcastService(store, priceService.GetUpdateChannel())
+	eventStore, err := store.New(cfg.StoreConfig())
+	if err != nil {
+		logging.Fatal("Error creating store", "error", err)
+	}
+	priceProvider := initializePriceProvider(cfg.Provider)
+	priceService := service.NewPriceService(eventStore, priceProvider, cfg.Provider.PollInterval)
+	validator := validation.NewValidator(cfg.Validation)
+	validator.Prime(eventStore)
+	priceService.SetValidator(validator)
+	priceService.SetDedup(cfg.Dedup)
+	broadcastService := service.NewBroadcastService(eventStore, priceService.GetUpdateChannel())
+	broadcastService.SetPriceFormat(cfg.Server.PriceFormat)
+	indicatorService := indicator.NewService(eventStore, cfg.Indicators)
+	indicatorService.SetPriceFormat(cfg.Server.PriceFormat)
+	broadcastService.StreamIndicators(indicatorService)
 
 	// Start services
+	if backgroundStore, ok := eventStore.(store.BackgroundStore); ok {
+		backgroundStore.Start(ctx)
+	}
+	go startupBackfill(cfg, eventStore)
+	startRetentionCompactor(ctx, cfg, eventStore)
+	alertEngine := startAlertEngine(ctx, cfg, eventStore, priceService)
+	webhookDispatcher := startWebhookDispatcher(ctx, cfg, eventStore, priceService, alertEngine)
+	if alertEngine != nil {
+		broadcastService.BroadcastAlerts(alertEngine.Subscribe())
+	}
+	broadcastService.BroadcastHeartbeats(priceService.GetHeartbeatChannel())
+	statsTracker := startStatsTracker(ctx, cfg, eventStore, priceService)
+	broadcastService.BroadcastStats(statsTracker.Subscribe())
 	priceService.Start(ctx)
 	broadcastService.Start(ctx)
+	go watchReload(ctx, loader, cfg, priceService, alertEngine)
+	authenticator := startAuthenticator(ctx, cfg, eventStore)
 
 	// Setup and start HTTP server
-	server := setupServer(broadcastService)
+	healthChecker := health.NewChecker(eventStore, broadcastService, cfg.Health.StalenessThreshold)
+	healthChecker.UseHeartbeats(priceService)
+	historyAPI := service.NewHistoryAPI(eventStore)
+	historyAPI.SetPriceFormat(cfg.Server.PriceFormat)
+	apis := []routeRegistrar{historyAPI, indicatorService, statsTracker}
+	if alertEngine != nil {
+		apis = append(apis, alertEngine)
+	}
+	if webhookDispatcher != nil {
+		apis = append(apis, webhookDispatcher)
+	}
+	if authenticator != nil {
+		apis = append(apis, authenticator)
+	}
+	limiter := ratelimit.NewLimiter(cfg.RateLimit)
+	limiter.Start(ctx)
+	server := setupServer(ctx, cfg, broadcastService, healthChecker, limiter, authenticator, apis...)
+	setupTLS(ctx, cfg.Server.TLS, server)
+
+	go func() {
+		slog.Info("Server starting", "addr", cfg.Server.Addr, "tls", server.TLSConfig != nil)
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			logging.Fatal("Server error", "error", err)
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("Shutting down server")
+	shutdown(server, cfg.Server.ShutdownTimeout, eventStore, authenticator, shutdownTracing)
+}
 
-	log.Printf("Server starting on %s", serverPort)
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Server error: %v", err)
+// shutdown stops the HTTP server and flushes API key usage, the store and
+// pending spans
+func shutdown(server *http.Server, timeout time.Duration, eventStore store.EventStore, authenticator *auth.Authenticator, shutdownTracing func(context.Context) error) {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down server", "error", err)
+	}
+
+	if authenticator != nil {
+		authenticator.Flush()
+	}
+
+	if backgroundStore, ok := eventStore.(store.BackgroundStore); ok {
+		if err := backgroundStore.Close(); err != nil {
+			slog.Error("Error closing store", "error", err)
+		}
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
 	}
 }
 
-// initializePriceProvider creates a price provider based on environment configuration
-func initializePriceProvider() service.PriceProvider {
-	priceProviderStr := os.Getenv(providerEnvVar)
-	if priceProviderStr == providerBinance {
+// initializePriceProvider creates the configured price provider
+func initializePriceProvider(cfg config.ProviderConfig) service.PriceProvider {
+	slog.Info("Using price provider", "provider", cfg.Name, "poll_interval", cfg.PollInterval.String())
+	if cfg.Name == config.ProviderBinance {
 		return service.NewBinancePriceProvider()
 	}
 	return service.NewCoinGeckoPriceProvider()
 }
 
-// setupServer configures the HTTP server and routes
-func setupServer(broadcastService *service.BroadcastService) *http.Server {
+// startRetentionCompactor starts the background compactor when a retention
+// policy is configured and the store supports it
+func startRetentionCompactor(ctx context.Context, cfg config.Config, eventStore store.EventStore) {
+	policy, enabled := cfg.RetentionPolicy()
+	if !enabled {
+		return
+	}
+
+	retentionStore, ok := eventStore.(store.RetentionStore)
+	if !ok {
+		logging.Fatal("Configured store does not support retention policies")
+	}
+
+	slog.Info("Using retention policy", "policy", policy.String(), "interval", cfg.Retention.Interval.String())
+	retention.NewCompactor(retentionStore, policy, cfg.Retention.Interval).Start(ctx)
+}
+
+// startAlertEngine loads alert rules, applies the configured ones and
+// evaluates them against the price stream
+func startAlertEngine(ctx context.Context, cfg config.Config, eventStore store.EventStore, priceService *service.PriceService) *alert.Engine {
+	alertStore, ok := eventStore.(store.AlertStore)
+	if !ok {
+		if len(cfg.Alerts.Rules) > 0 {
+			logging.Fatal("Configured store does not support alerts")
+		}
+		return nil
+	}
+
+	engine := alert.NewEngine(alertStore)
+	engine.Load()
+	if err := engine.ApplyConfig(cfg.Alerts); err != nil {
+		logging.Fatal("Invalid alert rules", "error", err)
+	}
+
+	slog.Info("Starting alert engine", "rules", len(engine.Rules()))
+	engine.Start(ctx, priceService.Subscribe())
+	return engine
+}
+
+// startWebhookDispatcher delivers price events and fired alerts to the
+// registered webhooks when the store can persist them
+func startWebhookDispatcher(ctx context.Context, cfg config.Config, eventStore store.EventStore, priceService *service.PriceService, alertEngine *alert.Engine) *webhook.Dispatcher {
+	webhookStore, ok := eventStore.(store.WebhookStore)
+	if !ok {
+		return nil
+	}
+
+	dispatcher := webhook.NewDispatcher(webhookStore, cfg.Webhooks)
+	dispatcher.Load()
+
+	var alerts <-chan domain.Alert
+	if alertEngine != nil {
+		alerts = alertEngine.Subscribe()
+	}
+
+	slog.Info("Starting webhook dispatcher", "webhooks", len(dispatcher.Webhooks()))
+	if !cfg.Auth.Enabled {
+		slog.Warn("Webhooks can only be managed with an admin key; the webhook API answers 401 until auth.enabled is set")
+	}
+	dispatcher.Start(ctx, priceService.Subscribe(), alerts)
+	return dispatcher
+}
+
+// startStatsTracker keeps rolling statistics from stored history and the
+// price stream. Candles of the retention policy stand in for expired events.
+func startStatsTracker(ctx context.Context, cfg config.Config, eventStore store.EventStore, priceService *service.PriceService) *stats.Tracker {
+	var resolutions []time.Duration
+	if policy, enabled := cfg.RetentionPolicy(); enabled {
+		resolutions = policy.Resolutions()
+	}
+
+	tracker := stats.NewTracker(eventStore, resolutions)
+	tracker.Load()
+	tracker.Start(ctx, priceService.Subscribe())
+	return tracker
+}
+
+// startAuthenticator loads API keys when authentication is enabled and
+// returns nil otherwise
+func startAuthenticator(ctx context.Context, cfg config.Config, eventStore store.EventStore) *auth.Authenticator {
+	if !cfg.Auth.Enabled {
+		return nil
+	}
+
+	keyStore, ok := eventStore.(store.APIKeyStore)
+	if !ok {
+		logging.Fatal("Configured store does not support API keys")
+	}
+
+	authenticator := auth.NewAuthenticator(keyStore, cfg.Auth)
+	authenticator.Load()
+	authenticator.Start(ctx)
+
+	slog.Info("API key authentication enabled", "keys", len(authenticator.Keys()), "public", cfg.Auth.Public)
+	if cfg.Auth.AdminKey == "" && len(authenticator.Keys()) == 0 {
+		slog.Warn("No API keys exist and auth.admin_key is not set, so no keys can be issued")
+	}
+	return authenticator
+}
+
+// routeRegistrar is implemented by subsystems that serve part of the REST API
+type routeRegistrar interface {
+	RegisterRoutes(mux *http.ServeMux)
+}
+
+// setupServer configures the HTTP server and routes. Requests inherit ctx so
+// open SSE streams end when the server shuts down. With a non-nil
+// authenticator the stream and the REST API require an API key; without one
+// callers are anonymous unless auth.trust_user_header is set. Metrics,
+// health checks and static content never do. Streams are capped and REST
+// requests rate limited before authentication. CORS and security headers
+// apply to every route.
+func setupServer(ctx context.Context, cfg config.Config, broadcastService *service.BroadcastService, healthChecker *health.Checker, limiter *ratelimit.Limiter, authenticator *auth.Authenticator, apis ...routeRegistrar) *http.Server {
 	mux := http.NewServeMux()
+	apiMux := http.NewServeMux()
 
 	// Setup routes
-	mux.HandleFunc("/prices/stream", broadcastService.SSEHandler)
+	var stream http.Handler = http.HandlerFunc(broadcastService.SSEHandler)
+	if authenticator != nil {
+		stream = authenticator.LimitConnections(stream)
+	}
+	apiMux.Handle("/prices/stream", stream)
+	for _, registrar := range apis {
+		registrar.RegisterRoutes(apiMux)
+	}
+
+	var protected http.Handler = apiMux
+	if authenticator != nil {
+		protected = authenticator.Middleware(apiMux)
+	} else if cfg.Auth.TrustUserHeader {
+		protected = api.TrustUserHeader(apiMux)
+	}
+	mux.Handle("/prices/stream", limiter.LimitStreams(protected))
+	mux.Handle("/api/", limiter.LimitRequests(protected))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", healthChecker.LivenessHandler)
+	mux.HandleFunc("/readyz", healthChecker.ReadinessHandler)
 	setupStaticRoutes(mux)
 
 	return &http.Server{
-		Addr:              serverPort,
-		Handler:           mux,
+		Addr:              cfg.Server.Addr,
+		Handler:           logging.RequestID(security.Headers(cfg.Security, mux)),
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
 		ReadHeaderTimeout: time.Second * 30,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+}
+
+// setupTLS serves TLS when a certificate is configured. The certificate and
+// client CAs are reloaded when their files change.
+func setupTLS(ctx context.Context, cfg tlsconfig.Config, server *http.Server) {
+	if !cfg.Enabled() {
+		return
+	}
+
+	reloader, err := tlsconfig.NewReloader(cfg)
+	if err != nil {
+		logging.Fatal("Error loading TLS certificate", "error", err)
+	}
+	reloader.Start(ctx)
+
+	server.TLSConfig = reloader.TLSConfig()
+	if !cfg.HTTP2 {
+		// A non-nil map keeps net/http from enabling HTTP/2
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
 	}
+	slog.Info("Serving TLS", "cert_file", cfg.CertFile, "http2", cfg.HTTP2, "client_auth", cfg.ClientCAFile != "")
 }
 
 // setupStaticRoutes configures routes for static content
@@ -84,14 +358,14 @@ func serveIndexHandler(w http.ResponseWriter, r *http.Request) {
 
 	indexContent, err := os.ReadFile(indexHtmlPath)
 	if err != nil {
-		log.Printf("Error reading index.html: %v", err)
+		logging.FromContext(r.Context()).Error("Error reading index.html", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "text/html")
 	if _, err = w.Write(indexContent); err != nil {
-		log.Printf("Error writing response: %v", err)
+		logging.FromContext(r.Context()).Error("Error writing response", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
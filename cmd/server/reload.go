@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/alert"
+	"github.com/tstojkovski/btc-price-tracker/internal/config"
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/internal/service"
+)
+
+// reloadableKeys are the settings applied on SIGHUP. Anything else that
+// changes is reported but only takes effect after a restart.
+var reloadableKeys = map[string]bool{
+	"log.level":              true,
+	"provider.name":          true,
+	"provider.poll_interval": true,
+	"dedup.enabled":          true,
+	"dedup.epsilon":          true,
+	"dedup.max_interval":     true,
+	"alerts.rules":           true,
+}
+
+// watchReload reloads the configuration on every SIGHUP until ctx ends. The
+// HTTP server and SSE subscribers are left untouched.
+func watchReload(ctx context.Context, loader *config.Loader, cfg config.Config, priceService *service.PriceService, alertEngine *alert.Engine) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-hup:
+			cfg = reloadConfig(loader, cfg, priceService, alertEngine)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reloadConfig loads the configuration again and applies the reloadable
+// settings. It returns the configuration now in effect; an invalid
+// configuration is rejected and the current one is kept. alertEngine is nil
+// when the store does not support alerts.
+func reloadConfig(loader *config.Loader, current config.Config, priceService *service.PriceService, alertEngine *alert.Engine) config.Config {
+	slog.Info("Reloading configuration")
+
+	next, err := loader.Load()
+	if err != nil {
+		slog.Error("Configuration reload rejected, keeping current configuration", "error", err)
+		return current
+	}
+
+	changes := config.Diff(current, next)
+	if len(changes) == 0 {
+		slog.Info("Configuration unchanged")
+		return current
+	}
+
+	var restartRequired []string
+	for _, change := range changes {
+		slog.Info("Configuration changed", "key", change.Key, "old", change.Old, "new", change.New)
+		if !reloadableKeys[change.Key] {
+			restartRequired = append(restartRequired, change.Key)
+		}
+	}
+
+	applied := current
+	if next.Log.Level != current.Log.Level {
+		if err := logging.SetLevel(next.Log.Level); err != nil {
+			slog.Error("Error changing log level", "error", err)
+		} else {
+			applied.Log.Level = next.Log.Level
+		}
+	}
+	if next.Provider.Name != current.Provider.Name {
+		priceService.SetProvider(initializePriceProvider(next.Provider))
+		applied.Provider.Name = next.Provider.Name
+	}
+	if next.Provider.PollInterval != current.Provider.PollInterval {
+		priceService.SetPollInterval(next.Provider.PollInterval)
+		applied.Provider.PollInterval = next.Provider.PollInterval
+	}
+	if next.Dedup != current.Dedup {
+		priceService.SetDedup(next.Dedup)
+		applied.Dedup = next.Dedup
+	}
+	if !slices.Equal(next.Alerts.Rules, current.Alerts.Rules) || next.Alerts.MaxRulesPerOwner != current.Alerts.MaxRulesPerOwner {
+		if alertEngine == nil {
+			slog.Error("Configured store does not support alerts, ignoring alert rules")
+		} else if err := alertEngine.ApplyConfig(next.Alerts); err != nil {
+			slog.Error("Error applying alert rules", "error", err)
+		} else {
+			applied.Alerts = next.Alerts
+		}
+	}
+
+	if len(restartRequired) > 0 {
+		slog.Warn("Some configuration changes require a restart", "keys", strings.Join(restartRequired, ","))
+	}
+
+	return applied
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/alert"
+	"github.com/tstojkovski/btc-price-tracker/internal/config"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+func TestReloadConfig_AlertRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := config.NewLoader(flags)
+	if err := flags.Parse([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeConfig(`
+alerts:
+  rules:
+    - {id: high, type: above, threshold: 100000}
+    - {id: low, type: below, threshold: 50000}
+`)
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := alert.NewEngine(store.NewMemoryStore(10))
+	engine.Load()
+	if err := engine.ApplyConfig(cfg.Alerts); err != nil {
+		t.Fatal(err)
+	}
+	userRule := domain.AlertRule{ID: "mine", Owner: "alice", Type: domain.AlertAbove, Threshold: 1}
+	if err := engine.SetRule(userRule); err != nil {
+		t.Fatal(err)
+	}
+
+	writeConfig(`
+alerts:
+  rules:
+    - {id: high, type: above, threshold: 120000}
+`)
+	cfg = reloadConfig(loader, cfg, nil, engine)
+
+	if len(cfg.Alerts.Rules) != 1 {
+		t.Errorf("Expected the reloaded rules to be applied, got %+v", cfg.Alerts.Rules)
+	}
+	if rule, ok := engine.Rule("high"); !ok || rule.Threshold != 120000 {
+		t.Errorf("Expected rule high to be updated, got %+v", rule)
+	}
+	if _, ok := engine.Rule("low"); ok {
+		t.Error("Expected rule low to be deleted after it was removed from the config")
+	}
+	if _, ok := engine.Rule("mine"); !ok {
+		t.Error("Expected rules created through the API to be kept")
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/pkg/client"
+)
+
+// ANSI colors for the changes format
+const (
+	colorUp    = "\x1b[32m"
+	colorDown  = "\x1b[31m"
+	colorReset = "\x1b[0m"
+)
+
+// priceDecimals is how many decimals the table and changes formats show,
+// so prices line up at the decimal point
+const priceDecimals = 2
+
+// runTailCommand implements the "tail" subcommand, which prints the price
+// stream of a running tracker
+func runTailCommand(args []string) {
+	flags := flag.NewFlagSet("tail", flag.ExitOnError)
+	baseURL := flags.String("url", envOr("TRACKER_URL", "http://localhost:8082"), "address of the running tracker (env TRACKER_URL)")
+	apiKey := flags.String("api-key", os.Getenv("TRACKER_API_KEY"), "API key when authentication is enabled (env TRACKER_API_KEY)")
+	sinceStr := flags.String("since", "", "replay events from this time (RFC 3339, Unix seconds or a duration such as 1h)")
+	format := flags.String("format", "table", "output format: table, jsonl, csv or changes")
+	once := flags.Bool("once", false, "print the latest event, or the events since -since up to now, and exit instead of following the stream")
+	colorMode := flags.String("color", "auto", "highlight changes in color: auto, always or never")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s tail [flags]\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Follows the price stream of a running tracker until interrupted, reconnecting and resuming")
+		fmt.Fprintln(flags.Output(), "when the connection drops. With -once it prints once and exits instead.")
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if err := logging.Setup(os.Stderr, "text", "info"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var since time.Time
+	if *sinceStr != "" {
+		var err error
+		if since, err = parseSince(*sinceStr); err != nil {
+			logging.Fatal("Invalid -since", "error", err)
+		}
+	}
+
+	color, err := useColor(*colorMode)
+	if err != nil {
+		logging.Fatal("Invalid -color", "error", err)
+	}
+	printer, err := newTailPrinter(*format, os.Stdout, color)
+	if err != nil {
+		logging.Fatal("Invalid -format", "error", err)
+	}
+
+	trackerClient, err := client.New(client.Config{BaseURL: *baseURL, APIKey: *apiKey})
+	if err != nil {
+		logging.Fatal("Invalid -url", "error", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if *once {
+		tailOnce(ctx, trackerClient, since, printer)
+		return
+	}
+
+	var printErr error
+	err = trackerClient.Stream(ctx, client.StreamOptions{Since: unixOrZero(since)}, client.Handler{
+		Price: func(event client.PriceUpdateEvent) {
+			if printErr = printer.Print(event); printErr != nil {
+				cancel()
+			}
+		},
+		Disconnected: func(err error, retryIn time.Duration) {
+			slog.Warn("Stream disconnected, reconnecting", "error", err, "retry_in", retryIn.Round(time.Millisecond))
+		},
+	})
+	if printErr != nil {
+		logging.Fatal("Error writing output", "error", printErr)
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logging.Fatal("Stream failed", "error", err)
+	}
+}
+
+// tailOnce prints the events since since, or the latest event, from the
+// REST API
+func tailOnce(ctx context.Context, trackerClient *client.Client, since time.Time, printer tailPrinter) {
+	var events []client.PriceUpdateEvent
+	if since.IsZero() {
+		latest, err := trackerClient.Latest(ctx)
+		if err != nil {
+			logging.Fatal("Error fetching the latest price", "error", err)
+		}
+		events = append(events, latest)
+	} else {
+		var err error
+		if events, err = trackerClient.History(ctx, since, time.Now()); err != nil {
+			logging.Fatal("Error fetching price history", "error", err)
+		}
+	}
+
+	for _, event := range events {
+		if err := printer.Print(event); err != nil {
+			logging.Fatal("Error writing output", "error", err)
+		}
+	}
+}
+
+// parseSince accepts a duration before now as well as the forms of parseTime
+func parseSince(value string) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	return parseTime(value)
+}
+
+// envOr returns the environment variable name, or fallback when it is unset
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// useColor resolves -color. auto colors terminals unless NO_COLOR is set.
+func useColor(mode string) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		info, err := os.Stdout.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	default:
+		return false, fmt.Errorf("must be auto, always or never, got %q", mode)
+	}
+}
+
+// tailPrinter writes events as they arrive, without buffering
+type tailPrinter interface {
+	Print(event client.PriceUpdateEvent) error
+}
+
+func newTailPrinter(format string, w io.Writer, color bool) (tailPrinter, error) {
+	switch format {
+	case "table":
+		return &tablePrinter{w: w}, nil
+	case "jsonl":
+		return &jsonlPrinter{w: w}, nil
+	case "csv":
+		return &csvPrinter{writer: csv.NewWriter(w)}, nil
+	case "changes":
+		return &changesPrinter{w: w, color: color}, nil
+	default:
+		return nil, fmt.Errorf("must be table, jsonl, csv or changes, got %q", format)
+	}
+}
+
+// formatTimestamp renders Unix seconds as RFC 3339 in UTC
+func formatTimestamp(timestamp int64) string {
+	return time.Unix(timestamp, 0).UTC().Format(time.RFC3339)
+}
+
+type tablePrinter struct {
+	w           io.Writer
+	wroteHeader bool
+}
+
+func (tp *tablePrinter) Print(event client.PriceUpdateEvent) error {
+	if !tp.wroteHeader {
+		if _, err := fmt.Fprintf(tp.w, "%-20s  %16s\n", "TIME", "PRICE"); err != nil {
+			return err
+		}
+		tp.wroteHeader = true
+	}
+
+	_, err := fmt.Fprintf(tp.w, "%-20s  %16s\n", formatTimestamp(event.Timestamp), formatPrice(event.Price))
+	return err
+}
+
+// formatPrice renders a price with priceDecimals decimals
+func formatPrice(price client.Price) string {
+	return price.Decimal().StringFixed(priceDecimals)
+}
+
+type jsonlPrinter struct {
+	w io.Writer
+}
+
+func (jp *jsonlPrinter) Print(event client.PriceUpdateEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(jp.w, "%s\n", data)
+	return err
+}
+
+// csvPrinter writes the columns of the export subcommand, so its output can
+// be imported
+type csvPrinter struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func (cp *csvPrinter) Print(event client.PriceUpdateEvent) error {
+	if !cp.wroteHeader {
+		if err := cp.writer.Write([]string{"timestamp", "price", "backfilled"}); err != nil {
+			return err
+		}
+		cp.wroteHeader = true
+	}
+
+	if err := cp.writer.Write([]string{
+		strconv.FormatInt(event.Timestamp, 10),
+		event.Price.String(),
+		strconv.FormatBool(event.Backfilled),
+	}); err != nil {
+		return err
+	}
+	cp.writer.Flush()
+	return cp.writer.Error()
+}
+
+// changesPrinter is a table with the move from the previous price, colored
+// green for rises and red for falls
+type changesPrinter struct {
+	w           io.Writer
+	color       bool
+	previous    *client.Price
+	wroteHeader bool
+}
+
+func (cp *changesPrinter) Print(event client.PriceUpdateEvent) error {
+	if !cp.wroteHeader {
+		if _, err := fmt.Fprintf(cp.w, "%-20s  %16s    %-14s  %s\n", "TIME", "PRICE", "CHANGE", "%"); err != nil {
+			return err
+		}
+		cp.wroteHeader = true
+	}
+
+	line := fmt.Sprintf("%-20s  %16s", formatTimestamp(event.Timestamp), formatPrice(event.Price))
+	color := ""
+	if cp.previous != nil {
+		change := event.Price.Sub(*cp.previous)
+		percent := 0.0
+		if cp.previous.Sign() != 0 {
+			percent = change.Float64() / cp.previous.Float64() * 100
+		}
+
+		switch change.Sign() {
+		case 1:
+			line += fmt.Sprintf("  ▲ %-14s  %+.3f%%", "+"+formatPrice(change), percent)
+			color = colorUp
+		case -1:
+			line += fmt.Sprintf("  ▼ %-14s  %+.3f%%", formatPrice(change), percent)
+			color = colorDown
+		default:
+			line += fmt.Sprintf("  = %-14s  %+.3f%%", formatPrice(change), percent)
+		}
+	}
+	price := event.Price
+	cp.previous = &price
+
+	if cp.color && color != "" {
+		line = color + line + colorReset
+	}
+	_, err := fmt.Fprintln(cp.w, line)
+	return err
+}
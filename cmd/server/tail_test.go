@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/pkg/client"
+)
+
+func tailEvents(t *testing.T, prices ...string) []client.PriceUpdateEvent {
+	t.Helper()
+	events := make([]client.PriceUpdateEvent, len(prices))
+	for i, value := range prices {
+		price, err := client.ParsePrice(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events[i] = client.PriceUpdateEvent{Timestamp: 1700000000 + int64(i)*60, Price: price}
+	}
+	return events
+}
+
+func TestTailPrinters(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		color    bool
+		prices   []string
+		expected []string
+	}{
+		{
+			name:   "Table",
+			format: "table",
+			prices: []string{"69420.25", "69421"},
+			expected: []string{
+				"TIME                             PRICE",
+				"2023-11-14T22:13:20Z          69420.25",
+				"2023-11-14T22:14:20Z          69421.00",
+			},
+		},
+		{
+			name:   "JSONL",
+			format: "jsonl",
+			prices: []string{"69420.25"},
+			expected: []string{
+				`{"timestamp":1700000000,"price":"69420.25"}`,
+			},
+		},
+		{
+			name:   "CSV",
+			format: "csv",
+			prices: []string{"69420.25", "69421"},
+			expected: []string{
+				"timestamp,price,backfilled",
+				"1700000000,69420.25,false",
+				"1700000060,69421,false",
+			},
+		},
+		{
+			name:   "Changes",
+			format: "changes",
+			prices: []string{"100", "110.5", "99.45", "99.45"},
+			expected: []string{
+				"TIME                             PRICE    CHANGE          %",
+				"2023-11-14T22:13:20Z            100.00",
+				"2023-11-14T22:14:20Z            110.50  ▲ +10.50          +10.500%",
+				"2023-11-14T22:15:20Z             99.45  ▼ -11.05          -10.000%",
+				"2023-11-14T22:16:20Z             99.45  = 0.00            +0.000%",
+			},
+		},
+		{
+			name:   "Changes in color",
+			format: "changes",
+			color:  true,
+			prices: []string{"100", "110.5", "99.45", "99.45"},
+			expected: []string{
+				"TIME                             PRICE    CHANGE          %",
+				"2023-11-14T22:13:20Z            100.00",
+				colorUp + "2023-11-14T22:14:20Z            110.50  ▲ +10.50          +10.500%" + colorReset,
+				colorDown + "2023-11-14T22:15:20Z             99.45  ▼ -11.05          -10.000%" + colorReset,
+				"2023-11-14T22:16:20Z             99.45  = 0.00            +0.000%",
+			},
+		},
+		{
+			name:   "Changes from zero",
+			format: "changes",
+			prices: []string{"0", "1"},
+			expected: []string{
+				"TIME                             PRICE    CHANGE          %",
+				"2023-11-14T22:13:20Z              0.00",
+				"2023-11-14T22:14:20Z              1.00  ▲ +1.00           +0.000%",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			printer, err := newTailPrinter(tc.format, &buffer, tc.color)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, event := range tailEvents(t, tc.prices...) {
+				if err := printer.Print(event); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected := strings.Join(tc.expected, "\n") + "\n"
+			if buffer.String() != expected {
+				t.Errorf("Expected\n%q\ngot\n%q", expected, buffer.String())
+			}
+		})
+	}
+
+	if _, err := newTailPrinter("xml", &bytes.Buffer{}, false); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+	}{
+		{"1700000000", time.Unix(1700000000, 0)},
+		{"2023-11-14T22:13:20Z", time.Unix(1700000000, 0)},
+		{"1h", time.Now().Add(-time.Hour)},
+		{"90m", time.Now().Add(-90 * time.Minute)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := parseSince(tc.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := got.Sub(tc.expected).Abs(); diff > time.Second {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	for _, value := range []string{"yesterday", "2023-11-14", "-"} {
+		if _, err := parseSince(value); err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	tests := []struct {
+		mode     string
+		expected bool
+	}{
+		{"always", true},
+		{"never", false},
+		{"auto", false},
+	}
+
+	for _, tc := range tests {
+		got, err := useColor(tc.mode)
+		if err != nil || got != tc.expected {
+			t.Errorf("%s: expected %v, got %v (%v)", tc.mode, tc.expected, got, err)
+		}
+	}
+
+	if _, err := useColor("sometimes"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,146 @@
+package alert
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// ruleRequest is the body accepted when creating or updating a rule.
+// Window and Cooldown are in seconds.
+type ruleRequest struct {
+	Type       domain.AlertRuleType `json:"type"`
+	Threshold  float64              `json:"threshold"`
+	Percent    float64              `json:"percent"`
+	Window     int64                `json:"window"`
+	Hysteresis float64              `json:"hysteresis"`
+	Cooldown   int64                `json:"cooldown"`
+}
+
+func (req ruleRequest) rule(id string, owner string) domain.AlertRule {
+	return domain.AlertRule{
+		ID:         id,
+		Owner:      owner,
+		Type:       req.Type,
+		Threshold:  req.Threshold,
+		Percent:    req.Percent,
+		Window:     req.Window,
+		Hysteresis: req.Hysteresis,
+		Cooldown:   req.Cooldown,
+	}
+}
+
+// RegisterRoutes adds the alert rule API to mux. Callers only see and change
+// their own rules, and changes need an authenticated caller. Anonymous
+// callers can read the rules without an owner, which include those from the
+// config file.
+func (e *Engine) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/alerts", e.listRules)
+	mux.HandleFunc("POST /api/v1/alerts", e.createRule)
+	mux.HandleFunc("GET /api/v1/alerts/{id}", e.getRule)
+	mux.HandleFunc("PUT /api/v1/alerts/{id}", e.updateRule)
+	mux.HandleFunc("DELETE /api/v1/alerts/{id}", e.deleteRule)
+}
+
+func (e *Engine) listRules(w http.ResponseWriter, r *http.Request) {
+	user := api.User(r)
+
+	rules := []domain.AlertRule{}
+	for _, rule := range e.Rules() {
+		if rule.Owner == user {
+			rules = append(rules, rule)
+		}
+	}
+	api.WriteJSON(w, http.StatusOK, rules)
+}
+
+func (e *Engine) createRule(w http.ResponseWriter, r *http.Request) {
+	user, ok := api.RequireUser(w, r)
+	if !ok {
+		return
+	}
+	var req ruleRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	rule := req.rule(newRuleID(), user)
+	if err := e.SetRule(rule); errors.Is(err, ErrRuleLimit) {
+		api.WriteError(w, http.StatusConflict, "%v", err)
+		return
+	} else if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	api.WriteJSON(w, http.StatusCreated, rule)
+}
+
+func (e *Engine) getRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := e.ownedRule(w, r, false)
+	if !ok {
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, rule)
+}
+
+func (e *Engine) updateRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := api.RequireUser(w, r); !ok {
+		return
+	}
+	existing, ok := e.ownedRule(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req ruleRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	rule := req.rule(existing.ID, existing.Owner)
+	if err := e.SetRule(rule); err != nil {
+		api.WriteError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, rule)
+}
+
+func (e *Engine) deleteRule(w http.ResponseWriter, r *http.Request) {
+	if _, ok := api.RequireUser(w, r); !ok {
+		return
+	}
+	rule, ok := e.ownedRule(w, r, true)
+	if !ok {
+		return
+	}
+	e.DeleteRule(rule.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedRule looks up the rule named in the path. Rules owned by someone else
+// are reported as missing so their IDs are not disclosed. Rules from the
+// config file cannot be changed when mutate is set.
+func (e *Engine) ownedRule(w http.ResponseWriter, r *http.Request, mutate bool) (domain.AlertRule, bool) {
+	id := r.PathValue("id")
+
+	rule, ok := e.Rule(id)
+	if !ok || rule.Owner != api.User(r) {
+		api.WriteError(w, http.StatusNotFound, "alert rule %q not found", id)
+		return domain.AlertRule{}, false
+	}
+	if mutate && rule.Source == domain.AlertSourceConfig {
+		api.WriteError(w, http.StatusForbidden, "alert rule %q is managed by the config file", id)
+		return domain.AlertRule{}, false
+	}
+	return rule, true
+}
+
+func newRuleID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,318 @@
+// Package alert evaluates price alert rules against the live update stream.
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// ErrRuleLimit is returned when a user already has the most rules allowed
+var ErrRuleLimit = errors.New("alert rule limit reached")
+
+// Engine evaluates alert rules on every price update. Rules and their state
+// live in the store so fired alerts are not repeated after a restart.
+type Engine struct {
+	store store.AlertStore
+
+	mutex  sync.Mutex
+	rules  map[string]domain.AlertRule
+	states map[string]domain.AlertState
+	// maxRulesPerOwner is the configured per-user limit; 0 means unlimited
+	maxRulesPerOwner int
+	// history holds recent events, oldest first, for percent change rules
+	history []domain.PriceUpdateEvent
+
+	subscribers []chan domain.Alert
+}
+
+func NewEngine(store store.AlertStore) *Engine {
+	return &Engine{
+		store:  store,
+		rules:  make(map[string]domain.AlertRule),
+		states: make(map[string]domain.AlertState),
+	}
+}
+
+// Load reads rules and their state from the store and primes the history
+// used by percent change rules
+func (e *Engine) Load() {
+	rules := e.store.GetAlertRules()
+	states := e.store.GetAlertStates()
+
+	var window int64
+	for _, rule := range rules {
+		if rule.Type == domain.AlertPercentChange {
+			window = max(window, rule.Window)
+		}
+	}
+	history := e.loadHistory(window)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, rule := range rules {
+		e.rules[rule.ID] = rule
+	}
+	for _, state := range states {
+		e.states[state.RuleID] = state
+	}
+	e.mergeHistory(history)
+}
+
+// loadHistory reads the live events of the last window seconds from the
+// store. It is called without the mutex so evaluation is not held up.
+func (e *Engine) loadHistory(window int64) []domain.PriceUpdateEvent {
+	if window == 0 {
+		return nil
+	}
+	latest, exists := e.store.GetLatestEvent()
+	if !exists {
+		return nil
+	}
+
+	var history []domain.PriceUpdateEvent
+	err := store.EachEvent(e.store, latest.Timestamp-window, latest.Timestamp, func(event domain.PriceUpdateEvent) error {
+		if !event.Backfilled {
+			history = append(history, event)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Warn("Error loading history for alert rules", "error", err)
+	}
+	return history
+}
+
+// mergeHistory puts loaded events in front of the history evaluated since,
+// leaving out those already in it. Callers hold the mutex.
+func (e *Engine) mergeHistory(loaded []domain.PriceUpdateEvent) {
+	if len(e.history) > 0 {
+		first := e.history[0].Timestamp
+		end := 0
+		for end < len(loaded) && loaded[end].Timestamp < first {
+			end++
+		}
+		loaded = loaded[:end]
+	}
+	e.history = append(loaded, e.history...)
+}
+
+func (e *Engine) maxWindow() int64 {
+	var window int64
+	for _, rule := range e.rules {
+		if rule.Type == domain.AlertPercentChange {
+			window = max(window, rule.Window)
+		}
+	}
+	return window
+}
+
+// Rules returns the current rules ordered by ID
+func (e *Engine) Rules() []domain.AlertRule {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	rules := make([]domain.AlertRule, 0, len(e.rules))
+	for _, rule := range e.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// Rule returns the rule with the given ID
+func (e *Engine) Rule(id string) (domain.AlertRule, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	rule, ok := e.rules[id]
+	return rule, ok
+}
+
+// SetRule validates and saves a rule. A rule that changes starts over from
+// its initial state. New rules of a user beyond the configured limit are
+// refused with ErrRuleLimit.
+func (e *Engine) SetRule(rule domain.AlertRule) error {
+	if err := ValidateRule(rule); err != nil {
+		return err
+	}
+
+	// History only needs loading when the rule looks further back than the
+	// current rules, and is loaded without holding up evaluation
+	e.mutex.Lock()
+	longer := rule.Type == domain.AlertPercentChange && rule.Window > e.maxWindow()
+	e.mutex.Unlock()
+	var history []domain.PriceUpdateEvent
+	if longer {
+		history = e.loadHistory(rule.Window)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	existing, ok := e.rules[rule.ID]
+	if ok && existing == rule {
+		return nil
+	}
+	if !ok && rule.Source != domain.AlertSourceConfig && e.maxRulesPerOwner > 0 && e.ownedRules(rule.Owner) >= e.maxRulesPerOwner {
+		return fmt.Errorf("%w: %d rules per user", ErrRuleLimit, e.maxRulesPerOwner)
+	}
+
+	e.store.SaveAlertRule(rule)
+	e.store.DeleteAlertState(rule.ID)
+	e.rules[rule.ID] = rule
+	delete(e.states, rule.ID)
+	if longer {
+		e.mergeHistory(history)
+	}
+	return nil
+}
+
+// ownedRules counts the rules of owner created through the API. Callers
+// hold the mutex.
+func (e *Engine) ownedRules(owner string) int {
+	count := 0
+	for _, rule := range e.rules {
+		if rule.Owner == owner && rule.Source != domain.AlertSourceConfig {
+			count++
+		}
+	}
+	return count
+}
+
+// DeleteRule removes a rule and its state and reports whether it existed
+func (e *Engine) DeleteRule(id string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, ok := e.rules[id]; !ok {
+		return false
+	}
+
+	e.store.DeleteAlertRule(id)
+	e.store.DeleteAlertState(id)
+	delete(e.rules, id)
+	delete(e.states, id)
+	return true
+}
+
+// ApplyConfig creates or updates the configured rules and deletes the rules
+// of an earlier config that are no longer listed. Rules created through the
+// API are left alone.
+func (e *Engine) ApplyConfig(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	e.maxRulesPerOwner = config.MaxRulesPerOwner
+	e.mutex.Unlock()
+
+	configured := make(map[string]bool)
+	for _, rc := range config.Rules {
+		if err := e.SetRule(rc.Rule()); err != nil {
+			return err
+		}
+		configured[rc.ID] = true
+	}
+
+	for _, rule := range e.Rules() {
+		if rule.Source == domain.AlertSourceConfig && !configured[rule.ID] {
+			e.DeleteRule(rule.ID)
+		}
+	}
+	return nil
+}
+
+// Subscribe returns a channel receiving every alert fired from now on.
+// Alerts are dropped for a subscriber that falls behind.
+func (e *Engine) Subscribe() <-chan domain.Alert {
+	subscriber := make(chan domain.Alert, 100)
+
+	e.mutex.Lock()
+	e.subscribers = append(e.subscribers, subscriber)
+	e.mutex.Unlock()
+
+	return subscriber
+}
+
+// Start evaluates rules against updates until ctx is cancelled
+func (e *Engine) Start(ctx context.Context, updates <-chan domain.PriceUpdateEvent) {
+	go func() {
+		for {
+			select {
+			case event := <-updates:
+				e.publish(e.evaluate(event))
+			case <-ctx.Done():
+				slog.Info("Stopping alert engine")
+				return
+			}
+		}
+	}()
+}
+
+// evaluate applies an event to every rule, persisting state that changed
+func (e *Engine) evaluate(event domain.PriceUpdateEvent) []domain.Alert {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.history = append(e.history, event)
+	cutoff := event.Timestamp - e.maxWindow()
+	trim := 0
+	for trim < len(e.history)-1 && e.history[trim].Timestamp < cutoff {
+		trim++
+	}
+	e.history = e.history[trim:]
+
+	ids := make([]string, 0, len(e.rules))
+	for id := range e.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var alerts []domain.Alert
+	for _, id := range ids {
+		state, ok := e.states[id]
+		if !ok {
+			state = initialState(id)
+		}
+
+		alert, next := evaluateRule(e.rules[id], state, event, e.history)
+		if !ok || next != state {
+			e.states[id] = next
+			e.store.SaveAlertState(next)
+		}
+		if alert != nil {
+			alerts = append(alerts, *alert)
+		}
+	}
+
+	return alerts
+}
+
+func (e *Engine) publish(alerts []domain.Alert) {
+	e.mutex.Lock()
+	subscribers := e.subscribers
+	e.mutex.Unlock()
+
+	for _, alert := range alerts {
+		slog.Info("Alert fired", "rule", alert.RuleID, "type", alert.Type, "price", alert.Price, "message", alert.Message)
+		metrics.AlertsFired.WithLabelValues(string(alert.Type)).Inc()
+
+		for _, subscriber := range subscribers {
+			select {
+			case subscriber <- alert:
+			default:
+				slog.Warn("Alert subscriber buffer full, alert dropped", "rule", alert.RuleID)
+			}
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// feed evaluates prices one second apart starting at start and returns the
+// timestamps at which alerts fired
+func feed(engine *Engine, start int64, prices ...float64) []int64 {
+	var fired []int64
+	for i, price := range prices {
+		for _, alert := range engine.evaluate(domain.PriceUpdateEvent{Timestamp: start + int64(i), Price: domain.NewPrice(price)}) {
+			fired = append(fired, alert.Timestamp)
+		}
+	}
+	return fired
+}
+
+func newTestEngine(t *testing.T, rule domain.AlertRule) (*Engine, *store.MemoryStore) {
+	t.Helper()
+
+	memStore := store.NewMemoryStore(100)
+	engine := NewEngine(memStore)
+	engine.Load()
+	if err := engine.SetRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	return engine, memStore
+}
+
+func TestEngine_AboveWithHysteresis(t *testing.T) {
+	engine, _ := newTestEngine(t, domain.AlertRule{ID: "above", Type: domain.AlertAbove, Threshold: 100, Hysteresis: 5})
+
+	// Dipping to 98 stays inside the band, so the rule doesn't re-arm
+	fired := feed(engine, 1, 99, 101, 98, 102, 94, 101)
+	if len(fired) != 2 || fired[0] != 2 || fired[1] != 6 {
+		t.Errorf("Expected alerts at 2 and 6, got %v", fired)
+	}
+}
+
+func TestEngine_BelowWithCooldown(t *testing.T) {
+	engine, _ := newTestEngine(t, domain.AlertRule{ID: "below", Type: domain.AlertBelow, Threshold: 100, Cooldown: 10})
+
+	// Re-armed at 3 but still cooling down at 4; fires once the cooldown ends
+	fired := feed(engine, 1, 99, 101, 101, 99, 99, 99, 99, 99, 99, 99, 99, 99)
+	if len(fired) != 2 || fired[0] != 1 || fired[1] != 11 {
+		t.Errorf("Expected alerts at 1 and 11, got %v", fired)
+	}
+}
+
+func TestEngine_Crosses(t *testing.T) {
+	engine, _ := newTestEngine(t, domain.AlertRule{ID: "crosses", Type: domain.AlertCrosses, Threshold: 100, Hysteresis: 1})
+
+	// The first price only establishes the side; 100.5 is inside the band
+	fired := feed(engine, 1, 95, 100.5, 99.5, 102, 98, 98)
+	if len(fired) != 2 || fired[0] != 4 || fired[1] != 5 {
+		t.Errorf("Expected alerts at 4 and 5, got %v", fired)
+	}
+}
+
+func TestEngine_PercentChange(t *testing.T) {
+	engine, _ := newTestEngine(t, domain.AlertRule{ID: "move", Type: domain.AlertPercentChange, Percent: 5, Window: 3, Hysteresis: 1})
+
+	var alerts []domain.Alert
+	for i, price := range []float64{100, 102, 106, 106, 107, 107, 107, 107} {
+		alerts = append(alerts, engine.evaluate(domain.PriceUpdateEvent{Timestamp: int64(i), Price: domain.NewPrice(price)})...)
+	}
+
+	// +6% at t=2 fires, then the rule stays disarmed until the move within
+	// the window drops below 4%
+	if len(alerts) != 1 || alerts[0].Timestamp != 2 || alerts[0].Change != 6 {
+		t.Errorf("Expected one alert at 2 with +6%%, got %+v", alerts)
+	}
+}
+
+func TestEngine_PrimesHistoryForLongerWindow(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	engine := NewEngine(memStore)
+	engine.Load()
+	for i, price := range []float64{100, 101, 102} {
+		event := domain.PriceUpdateEvent{Timestamp: int64(i + 1), Price: domain.NewPrice(price)}
+		memStore.Store(event)
+		engine.evaluate(event)
+	}
+
+	// The stored events before the rule existed count towards its window
+	if err := engine.SetRule(domain.AlertRule{ID: "move", Type: domain.AlertPercentChange, Percent: 5, Window: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if fired := feed(engine, 4, 106); len(fired) != 1 {
+		t.Errorf("Expected +6%% since the first stored event to fire, got %v", fired)
+	}
+}
+
+func TestEngine_RuleLimit(t *testing.T) {
+	engine := NewEngine(store.NewMemoryStore(10))
+	config := DefaultConfig()
+	config.MaxRulesPerOwner = 1
+	config.Rules = []RuleConfig{{ID: "btc-100k", Type: "above", Threshold: 100000}}
+	if err := engine.ApplyConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	rule := domain.AlertRule{ID: "a1", Owner: "alice", Type: domain.AlertAbove, Threshold: 1}
+	if err := engine.SetRule(rule); err != nil {
+		t.Fatalf("Expected the first rule to be accepted, got %v", err)
+	}
+	rule.Threshold = 2
+	if err := engine.SetRule(rule); err != nil {
+		t.Errorf("Expected an update to be accepted, got %v", err)
+	}
+	if err := engine.SetRule(domain.AlertRule{ID: "a2", Owner: "alice", Type: domain.AlertAbove, Threshold: 1}); !errors.Is(err, ErrRuleLimit) {
+		t.Errorf("Expected ErrRuleLimit, got %v", err)
+	}
+	if err := engine.SetRule(domain.AlertRule{ID: "b1", Owner: "bob", Type: domain.AlertAbove, Threshold: 1}); err != nil {
+		t.Errorf("Expected other users to be unaffected, got %v", err)
+	}
+}
+
+func TestEngine_StateSurvivesRestart(t *testing.T) {
+	engine, memStore := newTestEngine(t, domain.AlertRule{ID: "above", Type: domain.AlertAbove, Threshold: 100})
+	if fired := feed(engine, 1, 101); len(fired) != 1 {
+		t.Fatalf("Expected the rule to fire, got %v", fired)
+	}
+
+	restarted := NewEngine(memStore)
+	restarted.Load()
+	if fired := feed(restarted, 2, 102); len(fired) != 0 {
+		t.Errorf("Expected no alert after restart, got %v", fired)
+	}
+	if len(restarted.Rules()) != 1 {
+		t.Errorf("Expected the rule to be loaded from the store")
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	invalid := []domain.AlertRule{
+		{Type: domain.AlertAbove, Threshold: 100},
+		{ID: "a", Type: "sideways", Threshold: 100},
+		{ID: "a", Type: domain.AlertBelow},
+		{ID: "a", Type: domain.AlertPercentChange, Percent: 5},
+		{ID: "a", Type: domain.AlertPercentChange, Percent: 5, Window: 60, Hysteresis: 5},
+		{ID: "a", Type: domain.AlertAbove, Threshold: 100, Cooldown: -1},
+	}
+	for _, rule := range invalid {
+		if err := ValidateRule(rule); err == nil {
+			t.Errorf("Expected %+v to be invalid", rule)
+		}
+	}
+}
+
+func TestEngine_API(t *testing.T) {
+	engine := NewEngine(store.NewMemoryStore(10))
+	mux := http.NewServeMux()
+	engine.RegisterRoutes(mux)
+
+	do := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(api.UserHeader, user)
+		w := httptest.NewRecorder()
+		api.TrustUserHeader(mux).ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/v1/alerts", "alice", `{"type":"above","threshold":100000,"cooldown":3600}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var created domain.AlertRule
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.Owner != "alice" {
+		t.Errorf("Expected generated ID owned by alice, got %+v", created)
+	}
+
+	if w := do("POST", "/api/v1/alerts", "alice", `{"type":"above"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid rule, got %d", w.Code)
+	}
+
+	engine.maxRulesPerOwner = 1
+	if w := do("POST", "/api/v1/alerts", "alice", `{"type":"above","threshold":1}`); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 beyond the rule limit, got %d", w.Code)
+	}
+
+	// Other users neither see nor change the rule
+	if w := do("GET", "/api/v1/alerts", "bob", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("Expected empty list for bob, got %s", w.Body)
+	}
+	if w := do("DELETE", "/api/v1/alerts/"+created.ID, "bob", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for bob, got %d", w.Code)
+	}
+
+	w = do("PUT", "/api/v1/alerts/"+created.ID, "alice", `{"type":"below","threshold":90000}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if rule, _ := engine.Rule(created.ID); rule.Type != domain.AlertBelow || rule.Owner != "alice" {
+		t.Errorf("Expected updated rule, got %+v", rule)
+	}
+
+	if w := do("DELETE", "/api/v1/alerts/"+created.ID, "alice", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if len(engine.Rules()) != 0 {
+		t.Error("Expected rule to be deleted")
+	}
+}
+
+func TestEngine_APIProtectsSharedRules(t *testing.T) {
+	engine := NewEngine(store.NewMemoryStore(10))
+	if err := engine.ApplyConfig(Config{Rules: []RuleConfig{{ID: "btc-100k", Type: "above", Threshold: 100000}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.SetRule(domain.AlertRule{ID: "pinned", Owner: "alice", Source: domain.AlertSourceConfig, Type: domain.AlertAbove, Threshold: 1}); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	engine.RegisterRoutes(mux)
+
+	do := func(method, path string, ctx context.Context, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(ctx)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+	anonymous := context.Background()
+	alice := api.WithUser(context.Background(), "alice")
+
+	if code := do("GET", "/api/v1/alerts/btc-100k", anonymous, ""); code != http.StatusOK {
+		t.Errorf("Expected anonymous callers to read shared rules, got %d", code)
+	}
+	if code := do("POST", "/api/v1/alerts", anonymous, `{"type":"above","threshold":1}`); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an anonymous create, got %d", code)
+	}
+	if code := do("PUT", "/api/v1/alerts/btc-100k", anonymous, `{"type":"above","threshold":1}`); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an anonymous update, got %d", code)
+	}
+	if code := do("DELETE", "/api/v1/alerts/btc-100k", anonymous, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an anonymous delete, got %d", code)
+	}
+	if code := do("DELETE", "/api/v1/alerts/pinned", alice, ""); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a rule from the config file, got %d", code)
+	}
+	if len(engine.Rules()) != 2 {
+		t.Errorf("Expected the shared rules to be kept, got %+v", engine.Rules())
+	}
+}
@@ -0,0 +1,213 @@
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// RuleConfig is an alert rule as written in the config file, with durations
+// instead of seconds
+type RuleConfig struct {
+	ID         string        `yaml:"id"`
+	Type       string        `yaml:"type"`
+	Threshold  float64       `yaml:"threshold,omitempty"`
+	Percent    float64       `yaml:"percent,omitempty"`
+	Window     time.Duration `yaml:"window,omitempty"`
+	Hysteresis float64       `yaml:"hysteresis,omitempty"`
+	Cooldown   time.Duration `yaml:"cooldown,omitempty"`
+}
+
+// Config lists alert rules that are created or updated on startup
+type Config struct {
+	Rules []RuleConfig `yaml:"rules"`
+	// MaxRulesPerOwner limits the rules each user can create through the
+	// API; 0 means unlimited. Rules from the config file do not count.
+	MaxRulesPerOwner int `yaml:"max_rules_per_owner"`
+}
+
+// DefaultConfig returns the alert defaults: no configured rules and up to
+// 100 rules per user
+func DefaultConfig() Config {
+	return Config{MaxRulesPerOwner: 100}
+}
+
+// Rule converts the configured rule to its domain form
+func (rc RuleConfig) Rule() domain.AlertRule {
+	return domain.AlertRule{
+		ID:         rc.ID,
+		Source:     domain.AlertSourceConfig,
+		Type:       domain.AlertRuleType(rc.Type),
+		Threshold:  rc.Threshold,
+		Percent:    rc.Percent,
+		Window:     int64(rc.Window / time.Second),
+		Hysteresis: rc.Hysteresis,
+		Cooldown:   int64(rc.Cooldown / time.Second),
+	}
+}
+
+// Validate checks every configured rule and that IDs are unique
+func (c Config) Validate() error {
+	var errs []error
+	seen := make(map[string]bool)
+
+	for i, rc := range c.Rules {
+		if err := ValidateRule(rc.Rule()); err != nil {
+			errs = append(errs, fmt.Errorf("alerts.rules[%d]: %w", i, err))
+		}
+		if seen[rc.ID] {
+			errs = append(errs, fmt.Errorf("alerts.rules[%d]: duplicate id %q", i, rc.ID))
+		}
+		seen[rc.ID] = true
+	}
+	if c.MaxRulesPerOwner < 0 {
+		errs = append(errs, fmt.Errorf("alerts.max_rules_per_owner must not be negative, got %d", c.MaxRulesPerOwner))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateRule checks that a rule is complete for its type
+func ValidateRule(rule domain.AlertRule) error {
+	var errs []error
+
+	if rule.ID == "" {
+		errs = append(errs, errors.New("id is required"))
+	}
+
+	switch rule.Type {
+	case domain.AlertAbove, domain.AlertBelow, domain.AlertCrosses:
+		if rule.Threshold <= 0 {
+			errs = append(errs, fmt.Errorf("threshold must be positive, got %v", rule.Threshold))
+		}
+	case domain.AlertPercentChange:
+		if rule.Percent <= 0 {
+			errs = append(errs, fmt.Errorf("percent must be positive, got %v", rule.Percent))
+		}
+		if rule.Window <= 0 {
+			errs = append(errs, fmt.Errorf("window must be positive, got %ds", rule.Window))
+		}
+		if rule.Hysteresis >= rule.Percent {
+			errs = append(errs, fmt.Errorf("hysteresis must be smaller than percent, got %v", rule.Hysteresis))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("type must be above, below, crosses or percent_change, got %q", rule.Type))
+	}
+
+	if rule.Hysteresis < 0 {
+		errs = append(errs, fmt.Errorf("hysteresis must not be negative, got %v", rule.Hysteresis))
+	}
+	if rule.Cooldown < 0 {
+		errs = append(errs, fmt.Errorf("cooldown must not be negative, got %ds", rule.Cooldown))
+	}
+
+	return errors.Join(errs...)
+}
+
+// initialState is the state of a rule that has never been evaluated
+func initialState(ruleID string) domain.AlertState {
+	return domain.AlertState{RuleID: ruleID, Armed: true}
+}
+
+// evaluateRule applies one event to a rule. history holds the recent events
+// in timestamp order, ending with event. It returns the fired alert, if any,
+// and the next state.
+func evaluateRule(rule domain.AlertRule, state domain.AlertState, event domain.PriceUpdateEvent, history []domain.PriceUpdateEvent) (*domain.Alert, domain.AlertState) {
+	price := event.Price.Float64()
+	cooledDown := rule.Cooldown <= 0 || state.LastFired == 0 || event.Timestamp-state.LastFired >= rule.Cooldown
+
+	fire := func(change float64, message string) *domain.Alert {
+		state.LastFired = event.Timestamp
+		return &domain.Alert{
+			RuleID:    rule.ID,
+			Owner:     rule.Owner,
+			Type:      rule.Type,
+			Timestamp: event.Timestamp,
+			Price:     event.Price,
+			Threshold: rule.Threshold,
+			Change:    change,
+			Message:   message,
+		}
+	}
+
+	switch rule.Type {
+	case domain.AlertAbove:
+		if price > rule.Threshold {
+			if state.Armed && cooledDown {
+				state.Armed = false
+				return fire(0, fmt.Sprintf("BTC price %.2f rose above %.2f", price, rule.Threshold)), state
+			}
+		} else if price <= rule.Threshold-rule.Hysteresis {
+			state.Armed = true
+		}
+
+	case domain.AlertBelow:
+		if price < rule.Threshold {
+			if state.Armed && cooledDown {
+				state.Armed = false
+				return fire(0, fmt.Sprintf("BTC price %.2f fell below %.2f", price, rule.Threshold)), state
+			}
+		} else if price >= rule.Threshold+rule.Hysteresis {
+			state.Armed = true
+		}
+
+	case domain.AlertCrosses:
+		// Inside the hysteresis band the price stays on its confirmed side
+		side := 0
+		if price > rule.Threshold+rule.Hysteresis {
+			side = 1
+		} else if price < rule.Threshold-rule.Hysteresis {
+			side = -1
+		}
+		if side == 0 || side == state.Side {
+			break
+		}
+
+		previous := state.Side
+		state.Side = side
+		if previous != 0 && cooledDown {
+			direction := "above"
+			if side < 0 {
+				direction = "below"
+			}
+			return fire(0, fmt.Sprintf("BTC price %.2f crossed %s %.2f", price, direction, rule.Threshold)), state
+		}
+
+	case domain.AlertPercentChange:
+		change, ok := percentChange(rule.Window, event, history)
+		if !ok {
+			break
+		}
+
+		if math.Abs(change) >= rule.Percent {
+			if state.Armed && cooledDown {
+				state.Armed = false
+				window := time.Duration(rule.Window) * time.Second
+				return fire(change, fmt.Sprintf("BTC price moved %+.2f%% to %.2f within %s", change, price, window)), state
+			}
+		} else if math.Abs(change) < rule.Percent-rule.Hysteresis {
+			state.Armed = true
+		}
+	}
+
+	return nil, state
+}
+
+// percentChange returns the change from the oldest price within window
+// seconds before event to event's price
+func percentChange(window int64, event domain.PriceUpdateEvent, history []domain.PriceUpdateEvent) (float64, bool) {
+	since := event.Timestamp - window
+	for _, past := range history {
+		if past.Timestamp < since {
+			continue
+		}
+		if past.Timestamp >= event.Timestamp || past.Price.Sign() == 0 {
+			return 0, false
+		}
+		return (event.Price.Float64() - past.Price.Float64()) / past.Price.Float64() * 100, true
+	}
+	return 0, false
+}
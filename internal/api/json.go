@@ -0,0 +1,56 @@
+// Package api holds the helpers shared by the REST handlers under /api/v1.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// maxBodySize bounds request bodies accepted by the REST API
+const maxBodySize = 1 << 20
+
+// Error is the body of every error response
+type Error struct {
+	Error string `json:"error"`
+}
+
+// WriteJSON writes body as JSON with the given status
+func WriteJSON(w http.ResponseWriter, status int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		slog.Error("Error encoding JSON response", "error", err)
+		status, data = http.StatusInternalServerError, []byte(`{"error":"encoding response"}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		slog.Error("Error writing JSON response", "error", err)
+	}
+}
+
+// WriteError writes an error response with the given status
+func WriteError(w http.ResponseWriter, status int, format string, args ...any) {
+	WriteJSON(w, status, Error{Error: fmt.Sprintf(format, args...)})
+}
+
+// DecodeJSON decodes a JSON request body into target, rejecting unknown
+// fields. On failure it writes a 400 response and returns false.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, target any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(target); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			WriteError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return false
+		}
+		WriteError(w, http.StatusBadRequest, "invalid request body: %v", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// PriceFormat selects how API responses and stream events encode prices.
+// Snapshots, the spill file and webhook payloads always use strings.
+type PriceFormat string
+
+const (
+	// PriceFormatString encodes prices as decimal strings, e.g. "69420.25"
+	PriceFormatString PriceFormat = "string"
+	// PriceFormatNumber encodes prices as JSON numbers for older clients
+	PriceFormatNumber PriceFormat = "number"
+)
+
+// Price is a price in a response, encoded in the format it was created with
+type Price struct {
+	value   domain.Price
+	numeric bool
+}
+
+// Price returns p for a response in format f
+func (f PriceFormat) Price(p domain.Price) Price {
+	return Price{value: p, numeric: f == PriceFormatNumber}
+}
+
+// MarshalJSON encodes the price as a number or as a decimal string
+func (p Price) MarshalJSON() ([]byte, error) {
+	if p.numeric {
+		return json.Marshal(json.Number(p.value.String()))
+	}
+	return json.Marshal(p.value)
+}
+
+// PriceEvent is a domain.PriceUpdateEvent in a response
+type PriceEvent struct {
+	Timestamp  int64 `json:"timestamp"`
+	Price      Price `json:"price"`
+	Backfilled bool  `json:"backfilled,omitempty"`
+}
+
+// Event returns event for a response in format f
+func (f PriceFormat) Event(event domain.PriceUpdateEvent) PriceEvent {
+	return PriceEvent{Timestamp: event.Timestamp, Price: f.Price(event.Price), Backfilled: event.Backfilled}
+}
+
+// Events returns events for a response in format f, never nil
+func (f PriceFormat) Events(events []domain.PriceUpdateEvent) []PriceEvent {
+	converted := make([]PriceEvent, len(events))
+	for i, event := range events {
+		converted[i] = f.Event(event)
+	}
+	return converted
+}
+
+// Alert is a domain.Alert in a response
+type Alert struct {
+	RuleID    string               `json:"rule_id"`
+	Owner     string               `json:"owner,omitempty"`
+	Type      domain.AlertRuleType `json:"type"`
+	Timestamp int64                `json:"timestamp"`
+	Price     Price                `json:"price"`
+	Threshold float64              `json:"threshold,omitempty"`
+	Change    float64              `json:"change,omitempty"`
+	Message   string               `json:"message"`
+}
+
+// Alert returns alert for a response in format f
+func (f PriceFormat) Alert(alert domain.Alert) Alert {
+	return Alert{
+		RuleID:    alert.RuleID,
+		Owner:     alert.Owner,
+		Type:      alert.Type,
+		Timestamp: alert.Timestamp,
+		Price:     f.Price(alert.Price),
+		Threshold: alert.Threshold,
+		Change:    alert.Change,
+		Message:   alert.Message,
+	}
+}
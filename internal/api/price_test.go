@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+func TestPriceFormat_Price(t *testing.T) {
+	price, err := domain.ParsePrice("69420.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format   PriceFormat
+		expected string
+	}{
+		{PriceFormatString, `"69420.1"`},
+		{PriceFormatNumber, `69420.1`},
+		{"", `"69420.1"`},
+	}
+
+	for _, tc := range tests {
+		data, err := json.Marshal(tc.format.Price(price))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.format, tc.expected, data)
+		}
+	}
+}
+
+func TestPriceFormat_MatchesDomainEncoding(t *testing.T) {
+	price := domain.NewPrice(69420.25)
+	event := domain.PriceUpdateEvent{Timestamp: 100, Price: price, Backfilled: true, TraceParent: "00-trace"}
+	alert := domain.Alert{RuleID: "r1", Owner: "alice", Type: domain.AlertPercentChange, Timestamp: 100, Price: price, Threshold: 1, Change: 2.5, Message: "moved"}
+
+	// Response types carry every field of the domain types they stand for
+	tests := []struct {
+		name     string
+		domain   any
+		response any
+	}{
+		{"Event", event, PriceFormatString.Event(event)},
+		{"Events", []domain.PriceUpdateEvent{event}, PriceFormatString.Events([]domain.PriceUpdateEvent{event})},
+		{"Alert", alert, PriceFormatString.Alert(alert)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expected, _ := json.Marshal(tc.domain)
+			got, err := json.Marshal(tc.response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(expected) {
+				t.Errorf("Expected %s, got %s", expected, got)
+			}
+		})
+	}
+
+	data, _ := json.Marshal(PriceFormatNumber.Event(event))
+	if expected := `{"timestamp":100,"price":69420.25,"backfilled":true}`; string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+	if data, _ := json.Marshal(PriceFormatNumber.Events(nil)); string(data) != "[]" {
+		t.Errorf("Expected an empty array, got %s", data)
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+// UserHeader names the caller on deployments without authentication that
+// opt in with TrustUserHeader
+const UserHeader = "X-User-ID"
+
+type userKey struct{}
+
+// WithUser returns a context carrying an authenticated user
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// User returns the caller of r as set by authentication middleware or
+// TrustUserHeader. Empty means anonymous.
+func User(r *http.Request) string {
+	user, _ := r.Context().Value(userKey{}).(string)
+	return user
+}
+
+// TrustUserHeader takes the caller of every request from the X-User-ID
+// header. The header is trusted as-is, so this must only be used behind a
+// proxy that sets it.
+func TrustUserHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), r.Header.Get(UserHeader))))
+	})
+}
+
+// RequireUser returns the caller of r, or writes a 401 response and returns
+// false for anonymous requests
+func RequireUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	user := User(r)
+	if user == "" {
+		WriteError(w, http.StatusUnauthorized, "authentication required")
+		return "", false
+	}
+	return user, true
+}
@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// importBatchSize is how many events are buffered before being inserted
+const importBatchSize = 1000
+
+// NewWriter creates a streaming writer for the given format
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatParquet:
+		return newParquetWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// NewReader creates a streaming reader for the given format. Parquet needs
+// random access to the file footer, so it can only be read from a file.
+func NewReader(format Format, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r), nil
+	case FormatJSONL:
+		return newJSONLReader(r), nil
+	case FormatParquet:
+		file, ok := r.(*os.File)
+		if !ok {
+			return nil, errors.New("parquet input must be a file")
+		}
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return newParquetReader(file, info.Size())
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+// Export streams every event between from and to, inclusive, to w and
+// returns the number of events written
+func Export(source store.IterableStore, from, to int64, w Writer) (int, error) {
+	count := 0
+	err := source.IterateEvents(from, to, func(event domain.PriceUpdateEvent) error {
+		if err := w.Write(event); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, w.Close()
+}
+
+// Import reads every event from r and inserts it into the store in
+// batches, returning the number of events imported. It stops at the first
+// batch the store fails to insert.
+func Import(r Reader, target store.BackfillStore) (int, error) {
+	count := 0
+	batch := make([]domain.PriceUpdateEvent, 0, importBatchSize)
+
+	for {
+		event, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+
+		batch = append(batch, event)
+		if len(batch) == importBatchSize {
+			if err := target.InsertEvents(batch); err != nil {
+				return count, fmt.Errorf("inserting events: %w", err)
+			}
+			count += len(batch)
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := target.InsertEvents(batch); err != nil {
+			return count, fmt.Errorf("inserting events: %w", err)
+		}
+		count += len(batch)
+	}
+
+	return count, nil
+}
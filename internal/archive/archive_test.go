@@ -0,0 +1,163 @@
+package archive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	source := store.NewMemoryStore(10)
+	source.Store(domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(50000.12)})
+	source.Store(domain.PriceUpdateEvent{Timestamp: 200, Price: domain.NewPrice(51000.5), Backfilled: true})
+	source.Store(domain.PriceUpdateEvent{Timestamp: 300, Price: domain.NewPrice(52000.0)})
+	source.Store(domain.PriceUpdateEvent{Timestamp: 400, Price: domain.NewPrice(53000.0)})
+
+	for _, format := range []Format{FormatCSV, FormatJSONL, FormatParquet} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "export."+string(format))
+
+			file, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writer, err := NewWriter(format, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exported, err := Export(source, 200, 300, writer)
+			if err != nil {
+				t.Fatalf("Export failed: %v", err)
+			}
+			file.Close()
+
+			if exported != 2 {
+				t.Errorf("Expected 2 exported events, got %d", exported)
+			}
+
+			file, err = os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			reader, err := NewReader(format, file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target := store.NewMemoryStore(10)
+			imported, err := Import(reader, target)
+			if err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+			if imported != 2 {
+				t.Errorf("Expected 2 imported events, got %d", imported)
+			}
+
+			events := target.GetEventsSince(0)
+			if len(events) != 2 {
+				t.Fatalf("Expected 2 events, got %d", len(events))
+			}
+			if events[0].Timestamp != 200 || events[0].Price.String() != "51000.5" || !events[0].Backfilled {
+				t.Errorf("Unexpected first event: %+v", events[0])
+			}
+			if events[1].Timestamp != 300 || events[1].Price.String() != "52000" || events[1].Backfilled {
+				t.Errorf("Unexpected second event: %+v", events[1])
+			}
+		})
+	}
+}
+
+// failingStore rejects every insert, like MongoDB while it is unreachable
+type failingStore struct {
+	*store.MemoryStore
+}
+
+func (failingStore) InsertEvents(events []domain.PriceUpdateEvent) error {
+	return errors.New("connection refused")
+}
+
+func TestImport_ReportsInsertErrors(t *testing.T) {
+	reader := newCSVReader(strings.NewReader("timestamp,price,backfilled\n100,50000,false\n200,51000,false\n"))
+
+	imported, err := Import(reader, failingStore{store.NewMemoryStore(10)})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("Expected the insert error, got %v", err)
+	}
+	if imported != 0 {
+		t.Errorf("Expected no events reported as imported, got %d", imported)
+	}
+}
+
+func TestCSVReader_WithoutHeader(t *testing.T) {
+	reader := newCSVReader(strings.NewReader("100,50000.5\n200,51000\n"))
+
+	event, err := reader.Read()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if event.Timestamp != 100 || event.Price.String() != "50000.5" {
+		t.Errorf("Unexpected event: %+v", event)
+	}
+
+	if _, err := newCSVReader(strings.NewReader("timestamp,price\nabc,1\n")).Read(); err == nil {
+		t.Error("Expected error for invalid timestamp")
+	}
+}
+
+func TestParquetReader_LegacyDoublePrices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.parquet")
+	rows := []legacyParquetEvent{{Timestamp: 100, Price: 50000.12}, {Timestamp: 200, Price: 51000, Backfilled: true}}
+	if err := parquet.WriteFile(path, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := NewReader(FormatParquet, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := store.NewMemoryStore(10)
+	if imported, err := Import(reader, target); err != nil || imported != 2 {
+		t.Fatalf("Expected 2 imported events, got %d (%v)", imported, err)
+	}
+
+	events := target.GetEventsSince(0)
+	if events[0].Price.String() != "50000.12" || events[1].Price.String() != "51000" || !events[1].Backfilled {
+		t.Errorf("Unexpected events: %+v", events)
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := map[string]Format{
+		"prices.csv":     FormatCSV,
+		"prices.jsonl":   FormatJSONL,
+		"prices.ndjson":  FormatJSONL,
+		"prices.parquet": FormatParquet,
+	}
+
+	for path, expected := range tests {
+		if format, err := FormatFromPath(path); err != nil || format != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", path, expected, format, err)
+		}
+	}
+
+	if _, err := FormatFromPath("prices.xlsx"); err == nil {
+		t.Error("Expected error for unknown extension")
+	}
+}
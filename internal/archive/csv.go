@@ -0,0 +1,97 @@
+package archive
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+var csvHeader = []string{"timestamp", "price", "backfilled"}
+
+type csvWriter struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) Write(event domain.PriceUpdateEvent) error {
+	if !cw.wroteHeader {
+		if err := cw.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		cw.wroteHeader = true
+	}
+
+	return cw.writer.Write([]string{
+		strconv.FormatInt(event.Timestamp, 10),
+		event.Price.String(),
+		strconv.FormatBool(event.Backfilled),
+	})
+}
+
+func (cw *csvWriter) Close() error {
+	if !cw.wroteHeader {
+		if err := cw.writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+type csvReader struct {
+	reader     *csv.Reader
+	readHeader bool
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return &csvReader{reader: reader}
+}
+
+func (cr *csvReader) Read() (domain.PriceUpdateEvent, error) {
+	record, err := cr.reader.Read()
+	if err != nil {
+		return domain.PriceUpdateEvent{}, err
+	}
+
+	// Skip the header row when present
+	if !cr.readHeader {
+		cr.readHeader = true
+		if len(record) > 0 && record[0] == csvHeader[0] {
+			return cr.Read()
+		}
+	}
+
+	if len(record) < 2 {
+		line, _ := cr.reader.FieldPos(0)
+		return domain.PriceUpdateEvent{}, fmt.Errorf("line %d: expected at least timestamp and price", line)
+	}
+
+	timestamp, err := strconv.ParseInt(record[0], 10, 64)
+	if err != nil {
+		return domain.PriceUpdateEvent{}, fmt.Errorf("invalid timestamp %q: %w", record[0], err)
+	}
+
+	price, err := domain.ParsePrice(record[1])
+	if err != nil {
+		return domain.PriceUpdateEvent{}, err
+	}
+
+	event := domain.PriceUpdateEvent{Timestamp: timestamp, Price: price}
+	if len(record) > 2 && record[2] != "" {
+		if event.Backfilled, err = strconv.ParseBool(record[2]); err != nil {
+			return domain.PriceUpdateEvent{}, fmt.Errorf("invalid backfilled flag %q: %w", record[2], err)
+		}
+	}
+
+	return event, nil
+}
@@ -0,0 +1,49 @@
+package archive
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// Format is a file format for exporting and importing price history
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatJSONL   Format = "jsonl"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat validates a format name
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatCSV, FormatJSONL, FormatParquet:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q: expected csv, jsonl or parquet", name)
+	}
+}
+
+// FormatFromPath infers the format from a file extension
+func FormatFromPath(path string) (Format, error) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if ext == "json" || ext == "ndjson" {
+		ext = string(FormatJSONL)
+	}
+	return ParseFormat(ext)
+}
+
+// Writer writes events one at a time. Close must be called to flush any
+// buffered output; it does not close the underlying writer.
+type Writer interface {
+	Write(event domain.PriceUpdateEvent) error
+	Close() error
+}
+
+// Reader reads events one at a time and returns io.EOF when done
+type Reader interface {
+	Read() (domain.PriceUpdateEvent, error)
+}
@@ -0,0 +1,41 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+type jsonlWriter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	buffer := bufio.NewWriter(w)
+	return &jsonlWriter{buffer: buffer, encoder: json.NewEncoder(buffer)}
+}
+
+func (jw *jsonlWriter) Write(event domain.PriceUpdateEvent) error {
+	return jw.encoder.Encode(event)
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.buffer.Flush()
+}
+
+type jsonlReader struct {
+	decoder *json.Decoder
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	return &jsonlReader{decoder: json.NewDecoder(bufio.NewReader(r))}
+}
+
+func (jr *jsonlReader) Read() (domain.PriceUpdateEvent, error) {
+	var event domain.PriceUpdateEvent
+	err := jr.decoder.Decode(&event)
+	return event, err
+}
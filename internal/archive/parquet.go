@@ -0,0 +1,126 @@
+package archive
+
+import (
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// parquetRowGroupSize bounds how many rows are buffered before a row group
+// is flushed, keeping memory flat for large exports
+const parquetRowGroupSize = 64 * 1024
+
+const parquetBatchSize = 1024
+
+// parquetEvent stores the price as its exact decimal string
+type parquetEvent struct {
+	Timestamp  int64  `parquet:"timestamp"`
+	Price      string `parquet:"price"`
+	Backfilled bool   `parquet:"backfilled"`
+}
+
+// legacyParquetEvent is the layout of files exported before prices were
+// decimals
+type legacyParquetEvent struct {
+	Timestamp  int64   `parquet:"timestamp"`
+	Price      float64 `parquet:"price"`
+	Backfilled bool    `parquet:"backfilled"`
+}
+
+type parquetWriter struct {
+	writer *parquet.GenericWriter[parquetEvent]
+	rows   []parquetEvent
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{
+		writer: parquet.NewGenericWriter[parquetEvent](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		rows:   make([]parquetEvent, 0, parquetBatchSize),
+	}
+}
+
+func (pw *parquetWriter) Write(event domain.PriceUpdateEvent) error {
+	pw.rows = append(pw.rows, parquetEvent{
+		Timestamp:  event.Timestamp,
+		Price:      event.Price.String(),
+		Backfilled: event.Backfilled,
+	})
+	if len(pw.rows) < cap(pw.rows) {
+		return nil
+	}
+	return pw.flushRows()
+}
+
+func (pw *parquetWriter) flushRows() error {
+	_, err := pw.writer.Write(pw.rows)
+	pw.rows = pw.rows[:0]
+	return err
+}
+
+func (pw *parquetWriter) Close() error {
+	if len(pw.rows) > 0 {
+		if err := pw.flushRows(); err != nil {
+			return err
+		}
+	}
+	return pw.writer.Close()
+}
+
+type parquetReader[T any] struct {
+	reader  *parquet.GenericReader[T]
+	rows    []T
+	next    int
+	convert func(T) (domain.PriceUpdateEvent, error)
+}
+
+// newParquetReader reads files with either a decimal string or a legacy
+// double price column
+func newParquetReader(r io.ReaderAt, size int64) (Reader, error) {
+	file, err := parquet.OpenFile(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if column, ok := file.Schema().Lookup("price"); ok && column.Node.Type().Kind() == parquet.Double {
+		return newRowReader(file, func(row legacyParquetEvent) (domain.PriceUpdateEvent, error) {
+			return domain.PriceUpdateEvent{Timestamp: row.Timestamp, Price: domain.NewPrice(row.Price), Backfilled: row.Backfilled}, nil
+		}), nil
+	}
+
+	return newRowReader(file, func(row parquetEvent) (domain.PriceUpdateEvent, error) {
+		price, err := domain.ParsePrice(row.Price)
+		if err != nil {
+			return domain.PriceUpdateEvent{}, err
+		}
+		return domain.PriceUpdateEvent{Timestamp: row.Timestamp, Price: price, Backfilled: row.Backfilled}, nil
+	}), nil
+}
+
+func newRowReader[T any](file *parquet.File, convert func(T) (domain.PriceUpdateEvent, error)) *parquetReader[T] {
+	return &parquetReader[T]{
+		reader:  parquet.NewGenericReader[T](file),
+		rows:    make([]T, 0, parquetBatchSize),
+		convert: convert,
+	}
+}
+
+func (pr *parquetReader[T]) Read() (domain.PriceUpdateEvent, error) {
+	if pr.next == len(pr.rows) {
+		pr.rows = pr.rows[:cap(pr.rows)]
+		n, err := pr.reader.Read(pr.rows)
+		if n == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return domain.PriceUpdateEvent{}, err
+		}
+		pr.rows = pr.rows[:n]
+		pr.next = 0
+	}
+
+	row := pr.rows[pr.next]
+	pr.next++
+	return pr.convert(row)
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// keyResponse is a key as returned by the admin API. Key is only set when
+// the key is issued.
+type keyResponse struct {
+	domain.APIKey
+	Key         string `json:"key,omitempty"`
+	Connections int    `json:"connections"`
+}
+
+// RegisterRoutes adds the key management API to mux. Every endpoint needs
+// an admin key.
+func (a *Authenticator) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/keys", RequireAdmin(a.listKeys))
+	mux.HandleFunc("POST /api/v1/keys", RequireAdmin(a.issueKey))
+	mux.HandleFunc("GET /api/v1/keys/{id}", RequireAdmin(a.getKey))
+	mux.HandleFunc("DELETE /api/v1/keys/{id}", RequireAdmin(a.revokeKey))
+	mux.HandleFunc("GET /api/v1/keys/{id}/usage", RequireAdmin(a.keyUsage))
+}
+
+// response hides the key hash, which is never returned
+func (a *Authenticator) response(key domain.APIKey) keyResponse {
+	key.Hash = ""
+	return keyResponse{APIKey: key, Connections: a.Connections(key.ID)}
+}
+
+func (a *Authenticator) listKeys(w http.ResponseWriter, r *http.Request) {
+	keys := a.Keys()
+	responses := make([]keyResponse, len(keys))
+	for i, key := range keys {
+		responses[i] = a.response(key)
+	}
+	api.WriteJSON(w, http.StatusOK, responses)
+}
+
+func (a *Authenticator) issueKey(w http.ResponseWriter, r *http.Request) {
+	var req KeyRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	key, secret, err := a.Issue(req)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	response := a.response(key)
+	response.Key = secret
+	api.WriteJSON(w, http.StatusCreated, response)
+}
+
+func (a *Authenticator) getKey(w http.ResponseWriter, r *http.Request) {
+	key, ok := a.Key(r.PathValue("id"))
+	if !ok {
+		api.WriteError(w, http.StatusNotFound, "API key %q not found", r.PathValue("id"))
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, a.response(key))
+}
+
+func (a *Authenticator) revokeKey(w http.ResponseWriter, r *http.Request) {
+	if _, err := a.Revoke(r.PathValue("id")); err != nil {
+		writeKeyError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Authenticator) keyUsage(w http.ResponseWriter, r *http.Request) {
+	usage, err := a.Usage(r.PathValue("id"))
+	if err != nil {
+		writeKeyError(w, r, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, usage)
+}
+
+func writeKeyError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrNotFound) {
+		api.WriteError(w, http.StatusNotFound, "API key %q not found", r.PathValue("id"))
+		return
+	}
+	api.WriteError(w, http.StatusInternalServerError, "%v", err)
+}
@@ -0,0 +1,440 @@
+// Package auth authenticates stream and REST API requests with API keys kept
+// in the store, and enforces per-key connection limits and daily quotas.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+const (
+	// keyPrefix marks API keys so they are easy to recognise in leaked text
+	keyPrefix = "btk_"
+	// KeyQueryParam carries the key for clients that cannot set headers,
+	// such as the browser EventSource API. Only the stream accepts it, since
+	// URLs end up in logs and browser history.
+	KeyQueryParam = "api_key"
+	// streamPath is the only route that accepts KeyQueryParam
+	streamPath = "/prices/stream"
+	// KeyHeader is an alternative to an Authorization bearer token
+	KeyHeader = "X-API-Key"
+
+	// adminKeyID identifies requests made with the configured admin key
+	adminKeyID = "config"
+	// certificateKeyPrefix starts the ID and owner of requests authenticated
+	// with a TLS client certificate, so a certificate's common name cannot
+	// act as the owner of an API key
+	certificateKeyPrefix = "cert:"
+	dayLayout            = "2006-01-02"
+
+	// missReloadInterval limits how often unknown keys reload the keys from
+	// the store, so guessing keys cannot load the store
+	missReloadInterval = 5 * time.Second
+)
+
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrInvalidKey = errors.New("invalid API key")
+)
+
+type keyContextKey struct{}
+
+// KeyFromContext returns the API key a request was authenticated with
+func KeyFromContext(ctx context.Context) (domain.APIKey, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(domain.APIKey)
+	return key, ok
+}
+
+// Authenticator checks API keys and accounts for their usage. Keys are kept
+// in the store and reloaded periodically, so keys issued or revoked by
+// another server are picked up. Usage is counted in memory and added to the
+// store's counts periodically.
+type Authenticator struct {
+	store  store.APIKeyStore
+	config Config
+	now    func() time.Time
+
+	// syncMutex serializes reading and writing the store, so a reload never
+	// misses or double counts usage that is being flushed
+	syncMutex sync.Mutex
+
+	mutex  sync.Mutex
+	keys   map[string]domain.APIKey
+	byHash map[string]string
+	// usage holds today's counts per key ID, including unflushed ones
+	usage map[string]domain.APIKeyUsage
+	// unflushed holds the counts per key and day since the last flush
+	unflushed   map[string]domain.APIKeyUsage
+	loadedAt    time.Time
+	connections map[string]int
+}
+
+func NewAuthenticator(store store.APIKeyStore, config Config) *Authenticator {
+	return &Authenticator{
+		store:       store,
+		config:      config,
+		now:         time.Now,
+		keys:        make(map[string]domain.APIKey),
+		byHash:      make(map[string]string),
+		usage:       make(map[string]domain.APIKeyUsage),
+		unflushed:   make(map[string]domain.APIKeyUsage),
+		connections: make(map[string]int),
+	}
+}
+
+// Load reads the keys and today's usage from the store, replacing the keys
+// read before
+func (a *Authenticator) Load() {
+	a.syncMutex.Lock()
+	defer a.syncMutex.Unlock()
+
+	today := a.today()
+	keys := a.store.GetAPIKeys()
+	stored := make(map[string]domain.APIKeyUsage)
+	for _, key := range keys {
+		for _, usage := range a.store.GetAPIKeyUsage(key.ID) {
+			if usage.Day == today {
+				stored[key.ID] = usage
+			}
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.keys = make(map[string]domain.APIKey, len(keys))
+	a.byHash = make(map[string]string, len(keys))
+	for _, key := range keys {
+		a.keys[key.ID] = key
+		a.byHash[key.Hash] = key.ID
+	}
+
+	a.usage = stored
+	for _, delta := range a.unflushed {
+		if delta.Day == today {
+			a.usage[delta.KeyID] = addUsage(a.usage[delta.KeyID], delta)
+		}
+	}
+	a.loadedAt = a.now()
+}
+
+// Start adds usage to the store every UsageFlushInterval and reloads the
+// keys every KeyReloadInterval until ctx is cancelled. Call Flush on
+// shutdown to keep the final counts.
+func (a *Authenticator) Start(ctx context.Context) {
+	go func() {
+		flush := time.NewTicker(a.config.UsageFlushInterval)
+		defer flush.Stop()
+		reload := time.NewTicker(a.config.KeyReloadInterval)
+		defer reload.Stop()
+
+		for {
+			select {
+			case <-flush.C:
+				a.Flush()
+			case <-reload.C:
+				a.Load()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Flush adds usage counted since the last flush to the store
+func (a *Authenticator) Flush() {
+	a.syncMutex.Lock()
+	defer a.syncMutex.Unlock()
+
+	a.mutex.Lock()
+	changed := a.unflushed
+	a.unflushed = make(map[string]domain.APIKeyUsage)
+	a.mutex.Unlock()
+
+	for _, usage := range changed {
+		a.store.AddAPIKeyUsage(usage)
+	}
+}
+
+// Middleware authenticates requests before passing them to next. Requests
+// with a valid key act as the key's owner and requests with a verified TLS
+// client certificate as its common name. Other requests are only let
+// through when the configuration makes read access public.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := credentials(r)
+		if secret == "" {
+			if key, ok := certificateKey(r); ok {
+				ctx := context.WithValue(r.Context(), keyContextKey{}, key)
+				next.ServeHTTP(w, r.WithContext(api.WithUser(ctx, key.Owner)))
+				return
+			}
+			if !a.config.Public || !readOnly(r) {
+				reject(w, r, http.StatusUnauthorized, "unauthorized", "API key required")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, ok := a.authenticate(secret)
+		if !ok {
+			reject(w, r, http.StatusUnauthorized, "unauthorized", "invalid API key")
+			return
+		}
+		if !a.countRequest(key) {
+			w.Header().Set("Retry-After", strconv.FormatInt(a.untilTomorrow(), 10))
+			reject(w, r, http.StatusTooManyRequests, "quota", "daily quota of %d requests exceeded", key.DailyQuota)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), keyContextKey{}, key)
+		next.ServeHTTP(w, r.WithContext(api.WithUser(ctx, key.Owner)))
+	})
+}
+
+// LimitConnections enforces the connection limit of the request's key while
+// next serves a long-lived stream. Anonymous requests are not limited here.
+func (a *Authenticator) LimitConnections(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := KeyFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !a.acquire(key) {
+			reject(w, r, http.StatusTooManyRequests, "connections", "connection limit of %d streams reached", key.MaxConnections)
+			return
+		}
+		defer a.release(key)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAdmin only lets requests made with an admin key through to next
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := KeyFromContext(r.Context())
+		if !ok {
+			reject(w, r, http.StatusUnauthorized, "unauthorized", "API key required")
+			return
+		}
+		if !key.Admin {
+			reject(w, r, http.StatusForbidden, "forbidden", "admin API key required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (a *Authenticator) authenticate(secret string) (domain.APIKey, bool) {
+	if a.config.AdminKey != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.config.AdminKey)) == 1 {
+		return domain.APIKey{ID: adminKeyID, Owner: "admin", Admin: true}, true
+	}
+
+	key, ok := a.lookup(secret)
+	if !ok && a.reloadDue() {
+		// The key may have been issued by another server since the last load
+		a.Load()
+		key, ok = a.lookup(secret)
+	}
+	if !ok || key.Revoked() {
+		return domain.APIKey{}, false
+	}
+	return key, true
+}
+
+func (a *Authenticator) lookup(secret string) (domain.APIKey, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	key, ok := a.keys[a.byHash[hash(secret)]]
+	return key, ok
+}
+
+// reloadDue reports whether an unknown key may reload the keys, and if so
+// counts the reload against missReloadInterval
+func (a *Authenticator) reloadDue() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := a.now()
+	if now.Sub(a.loadedAt) < missReloadInterval {
+		return false
+	}
+	a.loadedAt = now
+	return true
+}
+
+// certificateKey returns a key without limits for requests made with a
+// client certificate verified during the TLS handshake
+func certificateKey(r *http.Request) (domain.APIKey, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return domain.APIKey{}, false
+	}
+
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if name == "" {
+		return domain.APIKey{}, false
+	}
+	owner := certificateKeyPrefix + name
+	return domain.APIKey{ID: owner, Owner: owner}, true
+}
+
+// countRequest records a request made with key and reports whether it is
+// within the key's daily quota. Keys that are not stored, from the config
+// or a client certificate, are not limited.
+func (a *Authenticator) countRequest(key domain.APIKey) bool {
+	if key.Hash == "" {
+		return true
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	allowed := key.DailyQuota == 0 || a.todaysUsage(key.ID).Requests < key.DailyQuota
+	if allowed {
+		a.count(key.ID, 1, 0)
+	} else {
+		a.count(key.ID, 0, 1)
+	}
+	return allowed
+}
+
+func (a *Authenticator) acquire(key domain.APIKey) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if key.MaxConnections > 0 && a.connections[key.ID] >= key.MaxConnections {
+		if key.Hash != "" {
+			a.count(key.ID, 0, 1)
+		}
+		return false
+	}
+	a.connections[key.ID]++
+	return true
+}
+
+func (a *Authenticator) release(key domain.APIKey) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.connections[key.ID]--
+	if a.connections[key.ID] <= 0 {
+		delete(a.connections, key.ID)
+	}
+}
+
+// todaysUsage returns the usage of a key for the current UTC day. Callers
+// hold the mutex.
+func (a *Authenticator) todaysUsage(keyID string) domain.APIKeyUsage {
+	today := a.today()
+	if usage, ok := a.usage[keyID]; ok && usage.Day == today {
+		return usage
+	}
+	return domain.APIKeyUsage{KeyID: keyID, Day: today}
+}
+
+// count records requests and rejections of a key today, both in its daily
+// total and in the counts for the next flush. Callers hold the mutex.
+func (a *Authenticator) count(keyID string, requests int64, rejected int64) {
+	delta := domain.APIKeyUsage{
+		KeyID:      keyID,
+		Day:        a.today(),
+		Requests:   requests,
+		Rejected:   rejected,
+		LastUsedAt: a.now().Unix(),
+	}
+	a.usage[keyID] = addUsage(a.todaysUsage(keyID), delta)
+
+	id := keyID + "/" + delta.Day
+	a.unflushed[id] = addUsage(a.unflushed[id], delta)
+}
+
+// addUsage returns the sum of two counts for the same key and day
+func addUsage(usage domain.APIKeyUsage, delta domain.APIKeyUsage) domain.APIKeyUsage {
+	return domain.APIKeyUsage{
+		KeyID:      delta.KeyID,
+		Day:        delta.Day,
+		Requests:   usage.Requests + delta.Requests,
+		Rejected:   usage.Rejected + delta.Rejected,
+		LastUsedAt: max(usage.LastUsedAt, delta.LastUsedAt),
+	}
+}
+
+func (a *Authenticator) today() string {
+	return a.now().UTC().Format(dayLayout)
+}
+
+// untilTomorrow returns the seconds until quotas reset at UTC midnight
+func (a *Authenticator) untilTomorrow() int64 {
+	now := a.now().UTC()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return int64(tomorrow.Sub(now).Seconds()) + 1
+}
+
+// credentials returns the API key sent with r, if any
+func credentials(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if key := r.Header.Get(KeyHeader); key != "" {
+		return key
+	}
+	if r.URL.Path != streamPath {
+		return ""
+	}
+	return r.URL.Query().Get(KeyQueryParam)
+}
+
+// readOnly reports whether r only reads data, which public access allows
+func readOnly(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+func reject(w http.ResponseWriter, r *http.Request, status int, reason string, format string, args ...any) {
+	metrics.AuthRejections.WithLabelValues(reason).Inc()
+	logging.FromContext(r.Context()).Debug("Request rejected", "reason", reason, "status", status)
+
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="btc-price-tracker"`)
+	}
+	api.WriteError(w, status, format, args...)
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newKey returns a random API key
+func newKey() string {
+	return keyPrefix + randomHex(24)
+}
+
+func newID() string {
+	return randomHex(8)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
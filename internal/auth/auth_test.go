@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+const testAdminKey = "test-admin-key-0123456789"
+
+func newTestAuthenticator(t *testing.T, public bool) (*Authenticator, *store.MemoryStore) {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.Enabled = true
+	config.Public = public
+	config.AdminKey = testAdminKey
+
+	memoryStore := store.NewMemoryStore(10)
+	a := NewAuthenticator(memoryStore, config)
+	a.now = func() time.Time { return time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC) }
+	return a, memoryStore
+}
+
+// echoUser is a handler that writes the user a request acts as
+var echoUser = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte(api.User(r)))
+})
+
+func serve(handler http.Handler, method string, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_Credentials(t *testing.T) {
+	a, _ := newTestAuthenticator(t, false)
+	_, secret, err := a.Issue(KeyRequest{Owner: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := a.Middleware(echoUser)
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		status int
+		user   string
+	}{
+		{"No key", "/api/v1/stats", nil, http.StatusUnauthorized, ""},
+		{"Unknown key", "/api/v1/stats", http.Header{KeyHeader: {"btk_nope"}}, http.StatusUnauthorized, ""},
+		{"Bearer token", "/api/v1/stats", http.Header{"Authorization": {"Bearer " + secret}}, http.StatusOK, "alice"},
+		{"Key header", "/api/v1/stats", http.Header{KeyHeader: {secret}}, http.StatusOK, "alice"},
+		{"Query parameter", "/prices/stream?api_key=" + secret, nil, http.StatusOK, "alice"},
+		{"Query parameter outside the stream", "/api/v1/stats?api_key=" + secret, nil, http.StatusUnauthorized, ""},
+		{"User header is ignored", "/api/v1/stats", http.Header{KeyHeader: {secret}, api.UserHeader: {"bob"}}, http.StatusOK, "alice"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(handler, http.MethodGet, tc.target, tc.header)
+			if rec.Code != tc.status {
+				t.Fatalf("Expected status %d, got %d: %s", tc.status, rec.Code, rec.Body)
+			}
+			if tc.status == http.StatusOK && rec.Body.String() != tc.user {
+				t.Errorf("Expected user %q, got %q", tc.user, rec.Body)
+			}
+			if tc.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestMiddleware_PublicAccess(t *testing.T) {
+	a, _ := newTestAuthenticator(t, true)
+	handler := a.Middleware(echoUser)
+
+	rec := serve(handler, http.MethodGet, "/prices/stream", http.Header{api.UserHeader: {"bob"}})
+	if rec.Code != http.StatusOK || rec.Body.String() != "" {
+		t.Errorf("Expected anonymous read access, got %d %q", rec.Code, rec.Body)
+	}
+
+	if rec := serve(handler, http.MethodPost, "/api/v1/alerts", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected changes to need a key, got %d", rec.Code)
+	}
+}
+
+func TestMiddleware_ClientCertificate(t *testing.T) {
+	a, _ := newTestAuthenticator(t, false)
+	handler := a.Middleware(echoUser)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{
+		{Subject: pkix.Name{CommonName: "ingest-worker"}},
+	}}}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "cert:ingest-worker" {
+		t.Errorf("Expected verified certificate to authenticate, got %d %q", rec.Code, rec.Body)
+	}
+
+	if _, _, err := a.Issue(KeyRequest{Owner: "cert:ingest-worker"}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Expected keys not to take a certificate owner, got %v", err)
+	}
+}
+
+func TestMiddleware_DailyQuota(t *testing.T) {
+	a, memoryStore := newTestAuthenticator(t, false)
+	quota := int64(2)
+	key, secret, _ := a.Issue(KeyRequest{Owner: "alice", DailyQuota: &quota})
+	handler := a.Middleware(echoUser)
+	header := http.Header{KeyHeader: {secret}}
+
+	for i := 0; i < 2; i++ {
+		if rec := serve(handler, http.MethodGet, "/api/v1/stats", header); rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := serve(handler, http.MethodGet, "/api/v1/stats", header)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 once the quota is used, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "43201" {
+		t.Errorf("Expected retry at UTC midnight, got Retry-After %q", rec.Header().Get("Retry-After"))
+	}
+
+	// Quotas reset on the next UTC day
+	a.now = func() time.Time { return time.Date(2024, 4, 2, 0, 0, 1, 0, time.UTC) }
+	if rec := serve(handler, http.MethodGet, "/api/v1/stats", header); rec.Code != http.StatusOK {
+		t.Errorf("Expected quota to reset, got %d", rec.Code)
+	}
+
+	a.Flush()
+	usage := memoryStore.GetAPIKeyUsage(key.ID)
+	if len(usage) != 2 {
+		t.Fatalf("Expected usage for 2 days, got %+v", usage)
+	}
+	if usage[0].Day != "2024-04-01" || usage[0].Requests != 2 || usage[0].Rejected != 1 {
+		t.Errorf("Unexpected usage for the first day: %+v", usage[0])
+	}
+	if usage[1].Day != "2024-04-02" || usage[1].Requests != 1 {
+		t.Errorf("Unexpected usage for the second day: %+v", usage[1])
+	}
+
+	// Counts survive a restart
+	restarted := NewAuthenticator(memoryStore, a.config)
+	restarted.now = a.now
+	restarted.Load()
+	if rec := serve(restarted.Middleware(echoUser), http.MethodGet, "/api/v1/stats", header); rec.Code != http.StatusOK {
+		t.Fatalf("Expected key to be loaded, got %d", rec.Code)
+	}
+	restarted.Flush()
+	if usage := memoryStore.GetAPIKeyUsage(key.ID); usage[1].Requests != 2 {
+		t.Errorf("Expected usage to continue after a restart, got %+v", usage[1])
+	}
+}
+
+func TestAuthenticator_SharedStore(t *testing.T) {
+	first, memoryStore := newTestAuthenticator(t, false)
+	second := NewAuthenticator(memoryStore, first.config)
+	clock := first.now()
+	second.now = func() time.Time { return clock }
+	second.Load()
+
+	// A key issued by another server is found by reloading on a miss, but
+	// unknown keys reload at most every missReloadInterval
+	key, secret, _ := first.Issue(KeyRequest{Owner: "alice"})
+	header := http.Header{KeyHeader: {secret}}
+	if rec := serve(second.Middleware(echoUser), http.MethodGet, "/api/v1/stats", header); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected no reload within %v of the last, got %d", missReloadInterval, rec.Code)
+	}
+	clock = clock.Add(missReloadInterval)
+	for _, a := range []*Authenticator{first, second, first} {
+		if rec := serve(a.Middleware(echoUser), http.MethodGet, "/api/v1/stats", header); rec.Code != http.StatusOK {
+			t.Fatalf("Expected key to be accepted, got %d", rec.Code)
+		}
+	}
+
+	// Usage from both servers adds up instead of overwriting each other
+	first.Flush()
+	second.Flush()
+	usage := memoryStore.GetAPIKeyUsage(key.ID)
+	if len(usage) != 1 || usage[0].Requests != 3 {
+		t.Errorf("Expected 3 requests across both servers, got %+v", usage)
+	}
+
+	// Revocations are picked up by the next reload
+	if _, err := first.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	second.Load()
+	if rec := serve(second.Middleware(echoUser), http.MethodGet, "/api/v1/stats", header); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked key to be refused after a reload, got %d", rec.Code)
+	}
+}
+
+func TestLimitConnections(t *testing.T) {
+	a, _ := newTestAuthenticator(t, false)
+	limit := 1
+	key, secret, _ := a.Issue(KeyRequest{Owner: "alice", MaxConnections: &limit})
+
+	opened := make(chan struct{})
+	closeStream := make(chan struct{})
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(opened)
+		<-closeStream
+	})
+	handler := a.Middleware(a.LimitConnections(stream))
+	header := http.Header{KeyHeader: {secret}}
+
+	done := make(chan struct{})
+	go func() {
+		serve(handler, http.MethodGet, "/prices/stream", header)
+		close(done)
+	}()
+	<-opened
+
+	if rec := serve(handler, http.MethodGet, "/prices/stream", header); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected second stream to be refused, got %d", rec.Code)
+	}
+
+	close(closeStream)
+	<-done
+
+	// The slot is released when the stream ends
+	if a.Connections(key.ID) != 0 {
+		t.Errorf("Expected no open connections, got %d", a.Connections(key.ID))
+	}
+	if rec := serve(a.Middleware(a.LimitConnections(echoUser)), http.MethodGet, "/prices/stream", header); rec.Code != http.StatusOK {
+		t.Errorf("Expected stream after the first closed, got %d", rec.Code)
+	}
+}
+
+func TestAdminAPI(t *testing.T) {
+	a, _ := newTestAuthenticator(t, false)
+	mux := http.NewServeMux()
+	a.RegisterRoutes(mux)
+	handler := a.Middleware(mux)
+	admin := http.Header{"Authorization": {"Bearer " + testAdminKey}}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/keys", strings.NewReader(`{"owner":"alice","daily_quota":0}`))
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var issued keyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &issued); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(issued.Key, keyPrefix) || !strings.HasPrefix(issued.Key, issued.Prefix) {
+		t.Errorf("Unexpected key %q with prefix %q", issued.Key, issued.Prefix)
+	}
+	if issued.Hash != "" || issued.DailyQuota != 0 || issued.MaxConnections != DefaultConfig().DefaultMaxConnections {
+		t.Errorf("Unexpected issued key: %+v", issued.APIKey)
+	}
+
+	// Keys without admin rights cannot manage keys
+	if rec := serve(handler, http.MethodGet, "/api/v1/keys", http.Header{KeyHeader: {issued.Key}}); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a non-admin key, got %d", rec.Code)
+	}
+
+	rec = serve(handler, http.MethodGet, "/api/v1/keys", admin)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), issued.Key) || strings.Contains(rec.Body.String(), `"hash"`) {
+		t.Errorf("Expected key list without secrets, got %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(handler, http.MethodGet, "/api/v1/keys/"+issued.ID+"/usage", admin)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"requests":1`) {
+		t.Errorf("Expected usage of the forbidden request, got %d: %s", rec.Code, rec.Body)
+	}
+
+	if rec := serve(handler, http.MethodDelete, "/api/v1/keys/"+issued.ID, admin); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodGet, "/api/v1/keys", http.Header{KeyHeader: {issued.Key}}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected revoked key to be refused, got %d", rec.Code)
+	}
+	if rec := serve(handler, http.MethodDelete, "/api/v1/keys/missing", admin); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// minAdminKeyLength keeps the bootstrap admin key from being guessable
+const minAdminKeyLength = 16
+
+// Config controls API key authentication
+type Config struct {
+	// Enabled requires an API key on the stream and the REST API
+	Enabled bool `yaml:"enabled"`
+	// Public lets requests without a key read prices and open streams while
+	// authentication is enabled. Changes and admin endpoints still need a key.
+	Public bool `yaml:"public"`
+	// TrustUserHeader takes the caller from the X-User-ID header while
+	// authentication is disabled. Only set it behind a proxy that sets the
+	// header, since any client could otherwise act as any user.
+	TrustUserHeader bool `yaml:"trust_user_header"`
+	// AdminKey is a key with admin rights that is not kept in the store, for
+	// issuing the first keys
+	AdminKey string `yaml:"admin_key"`
+	// DefaultMaxConnections and DefaultDailyQuota apply to keys issued
+	// without explicit limits; 0 means unlimited
+	DefaultMaxConnections int           `yaml:"default_max_connections"`
+	DefaultDailyQuota     int64         `yaml:"default_daily_quota"`
+	UsageFlushInterval    time.Duration `yaml:"usage_flush_interval"`
+	// KeyReloadInterval is how often keys issued or revoked by other servers
+	// are picked up. Unknown keys also reload them, at most every 5s.
+	KeyReloadInterval time.Duration `yaml:"key_reload_interval"`
+}
+
+// DefaultConfig returns the authentication defaults: disabled, with
+// conservative limits for issued keys
+func DefaultConfig() Config {
+	return Config{
+		DefaultMaxConnections: 5,
+		DefaultDailyQuota:     100000,
+		UsageFlushInterval:    30 * time.Second,
+		KeyReloadInterval:     time.Minute,
+	}
+}
+
+// Validate checks the authentication settings
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Enabled && c.TrustUserHeader {
+		errs = append(errs, errors.New("auth.trust_user_header cannot be used with auth.enabled"))
+	}
+	if c.AdminKey != "" && len(c.AdminKey) < minAdminKeyLength {
+		errs = append(errs, fmt.Errorf("auth.admin_key must be at least %d characters", minAdminKeyLength))
+	}
+	if c.DefaultMaxConnections < 0 {
+		errs = append(errs, fmt.Errorf("auth.default_max_connections must not be negative, got %d", c.DefaultMaxConnections))
+	}
+	if c.DefaultDailyQuota < 0 {
+		errs = append(errs, fmt.Errorf("auth.default_daily_quota must not be negative, got %d", c.DefaultDailyQuota))
+	}
+	if c.UsageFlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("auth.usage_flush_interval must be positive, got %v", c.UsageFlushInterval))
+	}
+	if c.KeyReloadInterval <= 0 {
+		errs = append(errs, fmt.Errorf("auth.key_reload_interval must be positive, got %v", c.KeyReloadInterval))
+	}
+
+	return errors.Join(errs...)
+}
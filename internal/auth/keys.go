@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// KeyRequest describes a key to issue. Nil limits take the configured
+// defaults; 0 means unlimited.
+type KeyRequest struct {
+	Owner          string `json:"owner"`
+	Admin          bool   `json:"admin"`
+	MaxConnections *int   `json:"max_connections"`
+	DailyQuota     *int64 `json:"daily_quota"`
+}
+
+// Issue creates a key and returns it together with the secret key, which
+// is not kept and cannot be retrieved again
+func (a *Authenticator) Issue(req KeyRequest) (domain.APIKey, string, error) {
+	if req.Owner == "" {
+		return domain.APIKey{}, "", fmt.Errorf("%w: owner is required", ErrInvalidKey)
+	}
+	if strings.HasPrefix(req.Owner, certificateKeyPrefix) {
+		return domain.APIKey{}, "", fmt.Errorf("%w: owner must not start with %q", ErrInvalidKey, certificateKeyPrefix)
+	}
+
+	key := domain.APIKey{
+		ID:             newID(),
+		Owner:          req.Owner,
+		Admin:          req.Admin,
+		MaxConnections: a.config.DefaultMaxConnections,
+		DailyQuota:     a.config.DefaultDailyQuota,
+		CreatedAt:      a.now().Unix(),
+	}
+	if req.MaxConnections != nil {
+		key.MaxConnections = *req.MaxConnections
+	}
+	if req.DailyQuota != nil {
+		key.DailyQuota = *req.DailyQuota
+	}
+	if key.MaxConnections < 0 || key.DailyQuota < 0 {
+		return domain.APIKey{}, "", fmt.Errorf("%w: limits must not be negative", ErrInvalidKey)
+	}
+
+	secret := newKey()
+	key.Prefix = secret[:len(keyPrefix)+8]
+	key.Hash = hash(secret)
+
+	// Holding syncMutex keeps a concurrent Load from dropping the new key
+	a.syncMutex.Lock()
+	defer a.syncMutex.Unlock()
+
+	a.store.SaveAPIKey(key)
+	a.mutex.Lock()
+	a.keys[key.ID] = key
+	a.byHash[key.Hash] = key.ID
+	a.mutex.Unlock()
+	return key, secret, nil
+}
+
+// Revoke stops a key from being accepted. The key and its usage are kept.
+func (a *Authenticator) Revoke(id string) (domain.APIKey, error) {
+	a.syncMutex.Lock()
+	defer a.syncMutex.Unlock()
+
+	a.mutex.Lock()
+	key, ok := a.keys[id]
+	if !ok {
+		a.mutex.Unlock()
+		return domain.APIKey{}, ErrNotFound
+	}
+	if !key.Revoked() {
+		key.RevokedAt = a.now().Unix()
+		a.keys[id] = key
+	}
+	a.mutex.Unlock()
+
+	a.store.SaveAPIKey(key)
+	return key, nil
+}
+
+// Keys returns every key, including revoked ones, ordered by ID
+func (a *Authenticator) Keys() []domain.APIKey {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	keys := make([]domain.APIKey, 0, len(a.keys))
+	for _, key := range a.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// Key returns the key with the given ID
+func (a *Authenticator) Key(id string) (domain.APIKey, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	key, ok := a.keys[id]
+	return key, ok
+}
+
+// Usage returns the daily usage of a key, oldest day first, including
+// requests not yet written to the store
+func (a *Authenticator) Usage(id string) ([]domain.APIKeyUsage, error) {
+	if _, ok := a.Key(id); !ok {
+		return nil, ErrNotFound
+	}
+	a.Flush()
+
+	usage := a.store.GetAPIKeyUsage(id)
+	if usage == nil {
+		usage = []domain.APIKeyUsage{}
+	}
+	return usage, nil
+}
+
+// Connections returns the number of streams open with a key
+func (a *Authenticator) Connections(id string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.connections[id]
+}
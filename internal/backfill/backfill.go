@@ -0,0 +1,129 @@
+package backfill
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/service"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// Gap is a period in which the store has no events. Start and End are Unix
+// timestamps of the last event before and the first event after the gap.
+type Gap struct {
+	Start int64
+	End   int64
+}
+
+// Backfiller detects gaps in an EventStore and fills them from a
+// provider's historical price API
+type Backfiller struct {
+	store     store.BackfillStore
+	source    service.HistoricalPriceProvider
+	threshold time.Duration
+}
+
+// NewBackfiller creates a backfiller that treats any period longer than
+// threshold without events as a gap
+func NewBackfiller(store store.BackfillStore, source service.HistoricalPriceProvider, threshold time.Duration) *Backfiller {
+	return &Backfiller{
+		store:     store,
+		source:    source,
+		threshold: threshold,
+	}
+}
+
+// FindGaps returns the gaps in the store between from and to, reading only
+// the events in that range
+func (b *Backfiller) FindGaps(from, to time.Time) ([]Gap, error) {
+	finder := newGapFinder(from.Unix(), int64(b.threshold/time.Second))
+	err := store.EachEvent(b.store, from.Unix(), to.Unix(), func(event domain.PriceUpdateEvent) error {
+		finder.add(event.Timestamp)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading events: %w", err)
+	}
+	return finder.finish(to.Unix()), nil
+}
+
+func findGaps(events []domain.PriceUpdateEvent, from, to, threshold int64) []Gap {
+	finder := newGapFinder(from, threshold)
+	for _, event := range events {
+		finder.add(event.Timestamp)
+	}
+	return finder.finish(to)
+}
+
+// gapFinder collects gaps from event timestamps in order
+type gapFinder struct {
+	previous  int64
+	threshold int64
+	gaps      []Gap
+}
+
+func newGapFinder(from, threshold int64) *gapFinder {
+	return &gapFinder{previous: from, threshold: threshold}
+}
+
+func (f *gapFinder) add(timestamp int64) {
+	if timestamp-f.previous > f.threshold {
+		f.gaps = append(f.gaps, Gap{Start: f.previous, End: timestamp})
+	}
+	f.previous = timestamp
+}
+
+// finish returns the gaps, including one before to if it is too far from
+// the last event
+func (f *gapFinder) finish(to int64) []Gap {
+	if to-f.previous > f.threshold {
+		f.gaps = append(f.gaps, Gap{Start: f.previous, End: to})
+	}
+	return f.gaps
+}
+
+// Fill fetches history for every gap between from and to and inserts it
+// into the store. It returns the number of events inserted, which on error
+// counts only the gaps filled before the failure.
+func (b *Backfiller) Fill(from, to time.Time) (int, error) {
+	if !from.Before(to) {
+		return 0, errors.New("backfill range is empty: from must be before to")
+	}
+
+	gaps, err := b.FindGaps(from, to)
+	if err != nil {
+		return 0, err
+	}
+
+	inserted := 0
+	for _, gap := range gaps {
+		history, err := b.source.FetchHistory(time.Unix(gap.Start, 0), time.Unix(gap.End, 0))
+		if err != nil {
+			return inserted, err
+		}
+
+		// Keep strictly inside the gap so existing events aren't duplicated
+		events := make([]domain.PriceUpdateEvent, 0, len(history))
+		for _, event := range history {
+			if event.Timestamp > gap.Start && event.Timestamp < gap.End {
+				event.Backfilled = true
+				events = append(events, event)
+			}
+		}
+
+		if len(events) > 0 {
+			if err := b.store.InsertEvents(events); err != nil {
+				return inserted, err
+			}
+		}
+
+		slog.Info("Backfilled gap", "count", len(events),
+			"from", time.Unix(gap.Start, 0).UTC(), "to", time.Unix(gap.End, 0).UTC())
+		inserted += len(events)
+	}
+
+	return inserted, nil
+}
@@ -0,0 +1,128 @@
+package backfill
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+type mockHistoryProvider struct {
+	requests [][2]int64
+}
+
+// FetchHistory returns one event per minute across the requested range
+func (m *mockHistoryProvider) FetchHistory(from, to time.Time) ([]domain.PriceUpdateEvent, error) {
+	m.requests = append(m.requests, [2]int64{from.Unix(), to.Unix()})
+
+	var events []domain.PriceUpdateEvent
+	for ts := from.Unix() - from.Unix()%60; ts <= to.Unix(); ts += 60 {
+		events = append(events, domain.PriceUpdateEvent{Timestamp: ts, Price: domain.NewPrice(float64(ts))})
+	}
+	return events, nil
+}
+
+func TestFindGaps(t *testing.T) {
+	events := []domain.PriceUpdateEvent{
+		{Timestamp: 100}, {Timestamp: 110}, {Timestamp: 400}, {Timestamp: 410},
+	}
+
+	tests := []struct {
+		name     string
+		from, to int64
+		expected []Gap
+	}{
+		{"Gap in the middle", 100, 420, []Gap{{Start: 110, End: 400}}},
+		{"Leading and trailing gaps", 0, 1000, []Gap{{Start: 0, End: 100}, {Start: 110, End: 400}, {Start: 410, End: 1000}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gaps := findGaps(events, tc.from, tc.to, 30)
+			if len(gaps) != len(tc.expected) {
+				t.Fatalf("Expected %d gaps, got %v", len(tc.expected), gaps)
+			}
+			for i := range gaps {
+				if gaps[i] != tc.expected[i] {
+					t.Errorf("Expected gap %v, got %v", tc.expected[i], gaps[i])
+				}
+			}
+		})
+	}
+
+	if gaps := findGaps(nil, 0, 20, 30); len(gaps) != 0 {
+		t.Errorf("Expected no gaps in a range shorter than the threshold, got %v", gaps)
+	}
+}
+
+func TestBackfiller_Fill(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 1000, Price: domain.NewPrice(1)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 1010, Price: domain.NewPrice(2)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 1300, Price: domain.NewPrice(3)})
+
+	provider := &mockHistoryProvider{}
+	backfiller := NewBackfiller(memStore, provider, 30*time.Second)
+
+	inserted, err := backfiller.Fill(time.Unix(1000, 0), time.Unix(1300, 0))
+	if err != nil {
+		t.Fatalf("Fill failed: %v", err)
+	}
+
+	// Minutes 1020, 1080, 1140, 1200 and 1260 fall strictly inside the gap
+	if inserted != 5 {
+		t.Errorf("Expected 5 inserted events, got %d", inserted)
+	}
+	if len(provider.requests) != 1 || provider.requests[0] != [2]int64{1010, 1300} {
+		t.Errorf("Unexpected provider requests: %v", provider.requests)
+	}
+
+	events := memStore.GetEventsSince(0)
+	if len(events) != 8 {
+		t.Fatalf("Expected 8 events, got %d", len(events))
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Timestamp <= events[i-1].Timestamp {
+			t.Fatalf("Expected events in timestamp order, got %v", events)
+		}
+	}
+	if !events[2].Backfilled || events[1].Backfilled {
+		t.Error("Expected only inserted events to be marked as backfilled")
+	}
+
+	latest, _ := memStore.GetLatestEvent()
+	if latest.Timestamp != 1300 {
+		t.Errorf("Expected latest event to stay at 1300, got %d", latest.Timestamp)
+	}
+
+	// A second run finds no gaps
+	if inserted, _ := backfiller.Fill(time.Unix(1000, 0), time.Unix(1300, 0)); inserted != 0 {
+		t.Errorf("Expected second fill to insert nothing, got %d", inserted)
+	}
+}
+
+// failingStore rejects every insert, like MongoDB while it is unreachable
+type failingStore struct {
+	*store.MemoryStore
+}
+
+func (failingStore) InsertEvents(events []domain.PriceUpdateEvent) error {
+	return errors.New("connection refused")
+}
+
+func TestBackfiller_FillReportsInsertErrors(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 1000, Price: domain.NewPrice(1)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 1300, Price: domain.NewPrice(2)})
+
+	backfiller := NewBackfiller(failingStore{memStore}, &mockHistoryProvider{}, 30*time.Second)
+	inserted, err := backfiller.Fill(time.Unix(1000, 0), time.Unix(1300, 0))
+	if err == nil || err.Error() != "connection refused" {
+		t.Errorf("Expected the insert error, got %v", err)
+	}
+	if inserted != 0 {
+		t.Errorf("Expected no events reported as inserted, got %d", inserted)
+	}
+}
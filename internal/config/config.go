@@ -0,0 +1,284 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/alert"
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/auth"
+	"github.com/tstojkovski/btc-price-tracker/internal/indicator"
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/internal/ratelimit"
+	"github.com/tstojkovski/btc-price-tracker/internal/retention"
+	"github.com/tstojkovski/btc-price-tracker/internal/security"
+	"github.com/tstojkovski/btc-price-tracker/internal/service"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+	"github.com/tstojkovski/btc-price-tracker/internal/tlsconfig"
+	"github.com/tstojkovski/btc-price-tracker/internal/tracing"
+	"github.com/tstojkovski/btc-price-tracker/internal/validation"
+	"github.com/tstojkovski/btc-price-tracker/internal/webhook"
+)
+
+const (
+	ProviderBinance   = "binance"
+	ProviderCoinGecko = "coingecko"
+)
+
+// Config is the complete, typed configuration of the service
+type Config struct {
+	Server     ServerConfig        `yaml:"server"`
+	Log        LogConfig           `yaml:"log"`
+	Provider   ProviderConfig      `yaml:"provider"`
+	Store      store.Config        `yaml:"store"`
+	Retention  RetentionConfig     `yaml:"retention"`
+	Backfill   BackfillConfig      `yaml:"backfill"`
+	Health     HealthConfig        `yaml:"health"`
+	Validation validation.Config   `yaml:"validation"`
+	Dedup      service.DedupConfig `yaml:"dedup"`
+	Tracing    tracing.Config      `yaml:"tracing"`
+	Alerts     alert.Config        `yaml:"alerts"`
+	Webhooks   webhook.Config      `yaml:"webhooks"`
+	Indicators indicator.Config    `yaml:"indicators"`
+	Auth       auth.Config         `yaml:"auth"`
+	Security   security.Config     `yaml:"security"`
+	RateLimit  ratelimit.Config    `yaml:"rate_limit"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	// PriceFormat is how prices are encoded in API responses and stream
+	// events: "string" keeps every digit, "number" matches older clients
+	PriceFormat api.PriceFormat  `yaml:"price_format"`
+	TLS         tlsconfig.Config `yaml:"tls"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+type ProviderConfig struct {
+	Name         string        `yaml:"name"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+type RetentionConfig struct {
+	// Policy is a retention policy such as "raw:24h,1m:720h,1h:forever".
+	// Retention is disabled when empty.
+	Policy   string        `yaml:"policy"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+type BackfillConfig struct {
+	OnStartup    bool          `yaml:"on_startup"`
+	Window       time.Duration `yaml:"window"`
+	GapThreshold time.Duration `yaml:"gap_threshold"`
+}
+
+type HealthConfig struct {
+	StalenessThreshold time.Duration `yaml:"staleness_threshold"`
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:            ":8082",
+			ShutdownTimeout: 10 * time.Second,
+			PriceFormat:     api.PriceFormatString,
+			TLS:             tlsconfig.DefaultConfig(),
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Provider: ProviderConfig{
+			Name:         ProviderCoinGecko,
+			PollInterval: 10 * time.Second,
+		},
+		Store: store.DefaultConfig(),
+		Retention: RetentionConfig{
+			Interval: time.Minute,
+		},
+		Backfill: BackfillConfig{
+			Window:       24 * time.Hour,
+			GapThreshold: 2 * time.Minute,
+		},
+		Health: HealthConfig{
+			StalenessThreshold: 60 * time.Second,
+		},
+		Validation: validation.DefaultConfig(),
+		Dedup:      service.DefaultDedupConfig(),
+		Tracing:    tracing.DefaultConfig(),
+		Alerts:     alert.DefaultConfig(),
+		Webhooks:   webhook.DefaultConfig(),
+		Indicators: indicator.DefaultConfig(),
+		Auth:       auth.DefaultConfig(),
+		Security:   security.DefaultConfig(),
+		RateLimit:  ratelimit.DefaultConfig(),
+	}
+}
+
+// normalize canonicalizes values that have accepted aliases
+func (c *Config) normalize() {
+	c.Server.PriceFormat = api.PriceFormat(strings.ToLower(string(c.Server.PriceFormat)))
+	c.Provider.Name = strings.ToLower(c.Provider.Name)
+	c.Store.Type = strings.ToLower(c.Store.Type)
+	if c.Store.Type == "mongodb" {
+		c.Store.Type = store.TypeMongo
+	}
+	c.Log.Level = strings.ToLower(c.Log.Level)
+	c.Log.Format = strings.ToLower(c.Log.Format)
+	c.Tracing.Exporter = strings.ToLower(c.Tracing.Exporter)
+}
+
+// Validate checks every setting and reports all problems at once
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.shutdown_timeout must be positive, got %v", c.Server.ShutdownTimeout))
+	}
+	switch c.Server.PriceFormat {
+	case api.PriceFormatString, api.PriceFormatNumber:
+	default:
+		errs = append(errs, fmt.Errorf("server.price_format must be string or number, got %q", c.Server.PriceFormat))
+	}
+	if err := c.Server.TLS.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("log.format must be json or text, got %q", c.Log.Format))
+	}
+
+	switch c.Provider.Name {
+	case ProviderBinance, ProviderCoinGecko:
+	default:
+		errs = append(errs, fmt.Errorf("provider.name must be %q or %q, got %q", ProviderBinance, ProviderCoinGecko, c.Provider.Name))
+	}
+	if c.Provider.PollInterval < time.Second {
+		errs = append(errs, fmt.Errorf("provider.poll_interval must be at least 1s, got %v", c.Provider.PollInterval))
+	}
+
+	if err := c.Store.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Retention.Policy != "" {
+		if _, err := retention.ParsePolicy(c.Retention.Policy); err != nil {
+			errs = append(errs, fmt.Errorf("retention.policy: %w", err))
+		}
+		if c.Retention.Interval <= 0 {
+			errs = append(errs, fmt.Errorf("retention.interval must be positive, got %v", c.Retention.Interval))
+		}
+	}
+
+	if c.Backfill.Window <= 0 {
+		errs = append(errs, fmt.Errorf("backfill.window must be positive, got %v", c.Backfill.Window))
+	}
+	if c.Backfill.GapThreshold <= 0 {
+		errs = append(errs, fmt.Errorf("backfill.gap_threshold must be positive, got %v", c.Backfill.GapThreshold))
+	}
+
+	if c.Health.StalenessThreshold <= 0 {
+		errs = append(errs, fmt.Errorf("health.staleness_threshold must be positive, got %v", c.Health.StalenessThreshold))
+	}
+
+	if err := c.Validation.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.Dedup.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Dedup.Enabled && c.Dedup.MaxInterval >= c.Backfill.GapThreshold {
+		// Longer runs without stored events would be backfilled as gaps
+		errs = append(errs, fmt.Errorf("dedup.max_interval must be shorter than backfill.gap_threshold, got %v and %v",
+			c.Dedup.MaxInterval, c.Backfill.GapThreshold))
+	}
+
+	if err := c.Tracing.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.Alerts.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.Webhooks.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.Indicators.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.Auth.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.Security.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.RateLimit.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// RetentionPolicy returns the parsed retention policy and whether retention is enabled
+func (c Config) RetentionPolicy() (retention.Policy, bool) {
+	if c.Retention.Policy == "" {
+		return retention.Policy{}, false
+	}
+	policy, err := retention.ParsePolicy(c.Retention.Policy)
+	if err != nil {
+		return retention.Policy{}, false
+	}
+	return policy, true
+}
+
+// StoreConfig returns the store configuration to open. When a retention
+// policy is enabled, raw MongoDB documents expire with the policy's raw tier
+// instead of store.mongo.ttl, which would otherwise cut the raw retention
+// short or expire raw events the policy keeps forever.
+func (c Config) StoreConfig() store.Config {
+	storeConfig := c.Store
+	if policy, enabled := c.RetentionPolicy(); enabled {
+		storeConfig.Mongo.TTL = policy.RawRetention
+	}
+	return storeConfig
+}
+
+// Redacted returns a copy that is safe to print or log
+func (c Config) Redacted() Config {
+	c.Store.Mongo.URI = logging.RedactURI(c.Store.Mongo.URI)
+	if c.Auth.AdminKey != "" {
+		c.Auth.AdminKey = "xxxxx"
+	}
+	return c
+}
+
+// YAML renders the configuration in the same format accepted by the config file
+func (c Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
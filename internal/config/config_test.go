@@ -0,0 +1,225 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLoader(t *testing.T, env map[string]string, args ...string) *Loader {
+	t.Helper()
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	loader := NewLoader(flags)
+	loader.lookupEnv = func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return loader
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefault_IsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Expected defaults to be valid, got %v", err)
+	}
+}
+
+func TestLoader_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  addr: ":9000"
+provider:
+  name: binance
+  poll_interval: 30s
+store:
+  type: memory
+  memory:
+    size: 500
+`)
+
+	env := map[string]string{
+		"PRICE_PROVIDER": "COINGECKO",
+		"STORE_SIZE":     "250",
+	}
+	loader := newTestLoader(t, env, "-config", path, "-provider", "binance")
+
+	cfg, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// File overrides defaults
+	if cfg.Server.Addr != ":9000" || cfg.Provider.PollInterval != 30*time.Second {
+		t.Errorf("Expected file values, got addr %q, poll interval %v", cfg.Server.Addr, cfg.Provider.PollInterval)
+	}
+	// Environment overrides file
+	if cfg.Store.Memory.Size != 250 {
+		t.Errorf("Expected STORE_SIZE to override file, got %d", cfg.Store.Memory.Size)
+	}
+	// Flags override environment
+	if cfg.Provider.Name != ProviderBinance {
+		t.Errorf("Expected -provider to override PRICE_PROVIDER, got %q", cfg.Provider.Name)
+	}
+	// Untouched settings keep their defaults
+	if cfg.Health.StalenessThreshold != 60*time.Second {
+		t.Errorf("Expected default staleness threshold, got %v", cfg.Health.StalenessThreshold)
+	}
+}
+
+func TestLoader_MongoTTLSeconds(t *testing.T) {
+	env := map[string]string{"STORE_TYPE": "mongodb", "MONGO_TTL": "86400"}
+
+	cfg, err := newTestLoader(t, env).Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Store.Type != "mongo" {
+		t.Errorf("Expected mongodb alias to normalize to mongo, got %q", cfg.Store.Type)
+	}
+	if cfg.Store.Mongo.TTL != 24*time.Hour {
+		t.Errorf("Expected TTL of 24h, got %v", cfg.Store.Mongo.TTL)
+	}
+}
+
+func TestConfig_StoreConfigFollowsRetention(t *testing.T) {
+	cfg := Default()
+	cfg.Store.Mongo.TTL = time.Hour
+	if ttl := cfg.StoreConfig().Mongo.TTL; ttl != time.Hour {
+		t.Errorf("Expected store.mongo.ttl without a retention policy, got %v", ttl)
+	}
+
+	cfg.Retention.Policy = "raw:24h,1m:720h"
+	if ttl := cfg.StoreConfig().Mongo.TTL; ttl != 24*time.Hour {
+		t.Errorf("Expected the raw retention as TTL, got %v", ttl)
+	}
+
+	cfg.Retention.Policy = "1m:720h"
+	if ttl := cfg.StoreConfig().Mongo.TTL; ttl != 0 {
+		t.Errorf("Expected raw events kept forever to have no TTL, got %v", ttl)
+	}
+}
+
+func TestLoader_FailsFast(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		file     string
+		expected []string
+	}{
+		{
+			name:     "Invalid MONGO_TTL",
+			env:      map[string]string{"MONGO_TTL": "a day"},
+			expected: []string{"MONGO_TTL"},
+		},
+		{
+			name:     "Every invalid value is reported",
+			env:      map[string]string{"STORE_TYPE": "redis", "PRICE_PROVIDER": "kraken", "LOG_LEVEL": "loud"},
+			expected: []string{"store.type", "provider.name", "log.level"},
+		},
+		{
+			name:     "Invalid price format",
+			env:      map[string]string{"PRICE_FORMAT": "float"},
+			expected: []string{"server.price_format"},
+		},
+		{
+			name:     "Invalid retention policy",
+			env:      map[string]string{"RETENTION_POLICY": "1m:soon"},
+			expected: []string{"retention.policy"},
+		},
+		{
+			name:     "Unknown key in file",
+			file:     "server:\n  port: 8082\n",
+			expected: []string{"field port not found"},
+		},
+		{
+			name:     "Invalid alert rule",
+			file:     "alerts:\n  rules:\n    - id: move\n      type: percent_change\n      percent: 3\n",
+			expected: []string{"alerts.rules[0]", "window must be positive"},
+		},
+		{
+			name:     "User header with authentication",
+			file:     "auth:\n  enabled: true\n  trust_user_header: true\n",
+			expected: []string{"auth.trust_user_header"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			env := tc.env
+			if tc.file != "" {
+				env = map[string]string{FileEnvVar: writeConfigFile(t, tc.file)}
+			}
+
+			_, err := newTestLoader(t, env).Load()
+			if err == nil {
+				t.Fatal("Expected Load to fail")
+			}
+			for _, fragment := range tc.expected {
+				if !strings.Contains(err.Error(), fragment) {
+					t.Errorf("Expected error to mention %q, got: %v", fragment, err)
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_RedactedYAML(t *testing.T) {
+	cfg := Default()
+	cfg.Store.Mongo.URI = "mongodb://admin:s3cret@db:27017"
+	cfg.Auth.AdminKey = "0123456789abcdef-admin"
+
+	out, err := cfg.Redacted().YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(out), "s3cret") {
+		t.Error("Expected password to be redacted")
+	}
+	if strings.Contains(string(out), "0123456789abcdef-admin") {
+		t.Error("Expected admin key to be redacted")
+	}
+	if !strings.Contains(string(out), "poll_interval: 10s") {
+		t.Errorf("Expected durations to be rendered as strings, got:\n%s", out)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := Default()
+	new := Default()
+	new.Provider.PollInterval = 30 * time.Second
+	new.Store.Mongo.URI = "mongodb://admin:s3cret@db:27017"
+
+	changes := Diff(old, new)
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", changes)
+	}
+
+	if changes[0].Key != "provider.poll_interval" || changes[0].Old != "10s" || changes[0].New != "30s" {
+		t.Errorf("Unexpected change: %+v", changes[0])
+	}
+	if changes[1].Key != "store.mongo.uri" || strings.Contains(changes[1].New, "s3cret") {
+		t.Errorf("Expected redacted URI change, got %+v", changes[1])
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+}
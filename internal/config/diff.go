@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Change is a single setting that differs between two configurations
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+// Diff lists the settings that differ between old and new, keyed by their
+// dotted YAML path. Both configurations are redacted first so the result is
+// safe to log.
+func Diff(old, new Config) []Change {
+	before := flatten(old.Redacted())
+	after := flatten(new.Redacted())
+
+	var changes []Change
+	for key, value := range after {
+		if before[key] != value {
+			changes = append(changes, Change{Key: key, Old: before[key], New: value})
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			changes = append(changes, Change{Key: key, Old: value})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// flatten renders c as YAML and collects its scalar values by dotted path
+func flatten(c Config) map[string]string {
+	values := make(map[string]string)
+
+	data, err := c.YAML()
+	if err != nil {
+		return values
+	}
+
+	var tree map[string]any
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return values
+	}
+
+	flattenInto(values, "", tree)
+	return values
+}
+
+func flattenInto(values map[string]string, prefix string, node map[string]any) {
+	for key, value := range node {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		if child, ok := value.(map[string]any); ok {
+			flattenInto(values, path, child)
+			continue
+		}
+		values[path] = fmt.Sprint(value)
+	}
+}
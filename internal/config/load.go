@@ -0,0 +1,260 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+)
+
+// FileEnvVar names the environment variable holding the config file path
+const FileEnvVar = "CONFIG_FILE"
+
+// envOverrides maps environment variables onto settings. The names predate
+// the config file and are kept for compatibility with existing deployments.
+var envOverrides = map[string]func(c *Config, value string) error{
+	"SERVER_ADDR":    func(c *Config, v string) error { c.Server.Addr = v; return nil },
+	"PRICE_FORMAT":   func(c *Config, v string) error { c.Server.PriceFormat = api.PriceFormat(v); return nil },
+	"LOG_LEVEL":      func(c *Config, v string) error { c.Log.Level = v; return nil },
+	"LOG_FORMAT":     func(c *Config, v string) error { c.Log.Format = v; return nil },
+	"PRICE_PROVIDER": func(c *Config, v string) error { c.Provider.Name = v; return nil },
+	"POLL_INTERVAL":  func(c *Config, v string) error { return parseDuration(v, &c.Provider.PollInterval) },
+	"STORE_TYPE":     func(c *Config, v string) error { c.Store.Type = v; return nil },
+	"STORE_SIZE":     func(c *Config, v string) error { return parseInt(v, &c.Store.Memory.Size) },
+	"STORE_SNAPSHOT_PATH": func(c *Config, v string) error {
+		c.Store.Memory.SnapshotPath = v
+		return nil
+	},
+	"STORE_SNAPSHOT_INTERVAL": func(c *Config, v string) error {
+		return parseDuration(v, &c.Store.Memory.SnapshotInterval)
+	},
+	"MONGO_URI":        func(c *Config, v string) error { c.Store.Mongo.URI = v; return nil },
+	"MONGO_DATABASE":   func(c *Config, v string) error { c.Store.Mongo.Database = v; return nil },
+	"MONGO_COLLECTION": func(c *Config, v string) error { c.Store.Mongo.Collection = v; return nil },
+	"MONGO_TTL":        func(c *Config, v string) error { return parseSeconds(v, &c.Store.Mongo.TTL) },
+	"MONGO_WRITE_QUEUE_SIZE": func(c *Config, v string) error {
+		return parseInt(v, &c.Store.Mongo.Writer.QueueSize)
+	},
+	"MONGO_WRITE_BATCH_SIZE": func(c *Config, v string) error {
+		return parseInt(v, &c.Store.Mongo.Writer.BatchSize)
+	},
+	"MONGO_WRITE_FLUSH_INTERVAL": func(c *Config, v string) error {
+		return parseDuration(v, &c.Store.Mongo.Writer.FlushInterval)
+	},
+	"MONGO_SPILL_PATH":   func(c *Config, v string) error { c.Store.Mongo.Writer.SpillPath = v; return nil },
+	"RETENTION_POLICY":   func(c *Config, v string) error { c.Retention.Policy = v; return nil },
+	"RETENTION_INTERVAL": func(c *Config, v string) error { return parseDuration(v, &c.Retention.Interval) },
+	"BACKFILL_ON_STARTUP": func(c *Config, v string) error {
+		return parseBool(v, &c.Backfill.OnStartup)
+	},
+	"BACKFILL_WINDOW": func(c *Config, v string) error { return parseDuration(v, &c.Backfill.Window) },
+	"BACKFILL_GAP_THRESHOLD": func(c *Config, v string) error {
+		return parseDuration(v, &c.Backfill.GapThreshold)
+	},
+	"HEALTH_STALENESS_THRESHOLD": func(c *Config, v string) error {
+		return parseDuration(v, &c.Health.StalenessThreshold)
+	},
+	"TRACING_EXPORTER": func(c *Config, v string) error { c.Tracing.Exporter = v; return nil },
+	"TRACING_ENDPOINT": func(c *Config, v string) error { c.Tracing.Endpoint = v; return nil },
+	"TRACING_INSECURE": func(c *Config, v string) error { return parseBool(v, &c.Tracing.Insecure) },
+	"TRACING_SAMPLE_RATIO": func(c *Config, v string) error {
+		return parseFloat(v, &c.Tracing.SampleRatio)
+	},
+	"AUTH_ENABLED":       func(c *Config, v string) error { return parseBool(v, &c.Auth.Enabled) },
+	"AUTH_PUBLIC":        func(c *Config, v string) error { return parseBool(v, &c.Auth.Public) },
+	"AUTH_ADMIN_KEY":     func(c *Config, v string) error { c.Auth.AdminKey = v; return nil },
+	"TLS_CERT_FILE":      func(c *Config, v string) error { c.Server.TLS.CertFile = v; return nil },
+	"TLS_KEY_FILE":       func(c *Config, v string) error { c.Server.TLS.KeyFile = v; return nil },
+	"TLS_CLIENT_CA_FILE": func(c *Config, v string) error { c.Server.TLS.ClientCAFile = v; return nil },
+	"CORS_ALLOWED_ORIGINS": func(c *Config, v string) error {
+		c.Security.CORS.AllowedOrigins = parseList(v)
+		return nil
+	},
+	"CORS_READ_ORIGINS": func(c *Config, v string) error {
+		c.Security.CORS.ReadOrigins = parseList(v)
+		return nil
+	},
+	"TRUSTED_PROXIES": func(c *Config, v string) error {
+		c.RateLimit.TrustedProxies = parseList(v)
+		return nil
+	},
+	"MAX_STREAMS": func(c *Config, v string) error { return parseInt(v, &c.RateLimit.MaxStreams) },
+	"MAX_STREAMS_PER_IP": func(c *Config, v string) error {
+		return parseInt(v, &c.RateLimit.MaxStreamsPerIP)
+	},
+}
+
+// Loader binds the common configuration flags to a FlagSet and builds the
+// effective configuration once the flags have been parsed. Precedence from
+// lowest to highest is: defaults, config file, environment, flags.
+type Loader struct {
+	flags     *flag.FlagSet
+	file      *string
+	overrides map[string]func(c *Config, value string) error
+	lookupEnv func(string) (string, bool)
+}
+
+// NewLoader registers -config and the override flags on flags
+func NewLoader(flags *flag.FlagSet) *Loader {
+	l := &Loader{
+		flags:     flags,
+		overrides: make(map[string]func(c *Config, value string) error),
+		lookupEnv: os.LookupEnv,
+	}
+
+	l.file = flags.String("config", "", "path to a YAML config file (default: $"+FileEnvVar+")")
+	l.override("addr", "HTTP listen address", func(c *Config, v string) error { c.Server.Addr = v; return nil })
+	l.override("provider", "price provider: binance or coingecko", func(c *Config, v string) error { c.Provider.Name = v; return nil })
+	l.override("poll-interval", "price polling interval", func(c *Config, v string) error { return parseDuration(v, &c.Provider.PollInterval) })
+	l.override("store", "store type: memory or mongo", func(c *Config, v string) error { c.Store.Type = v; return nil })
+	l.override("mongo-uri", "MongoDB connection string", func(c *Config, v string) error { c.Store.Mongo.URI = v; return nil })
+	l.override("log-level", "log level: debug, info, warn or error", func(c *Config, v string) error { c.Log.Level = v; return nil })
+	l.override("log-format", "log format: json or text", func(c *Config, v string) error { c.Log.Format = v; return nil })
+	l.override("trace-exporter", "trace exporter: none, stdout or otlp", func(c *Config, v string) error { c.Tracing.Exporter = v; return nil })
+
+	return l
+}
+
+func (l *Loader) override(name string, usage string, apply func(c *Config, value string) error) {
+	l.flags.String(name, "", usage)
+	l.overrides[name] = apply
+}
+
+// Load builds and validates the effective configuration
+func (l *Loader) Load() (Config, error) {
+	config := Default()
+
+	path := *l.file
+	if path == "" {
+		path, _ = l.lookupEnv(FileEnvVar)
+	}
+	if path != "" {
+		if err := loadFile(path, &config); err != nil {
+			return Config{}, err
+		}
+	}
+
+	names := make([]string, 0, len(envOverrides))
+	for name := range envOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if value, ok := l.lookupEnv(name); ok && value != "" {
+			if err := envOverrides[name](&config, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+
+	l.flags.Visit(func(f *flag.Flag) {
+		if apply, ok := l.overrides[f.Name]; ok {
+			if err := apply(&config, f.Value.String()); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", f.Name, err))
+			}
+		}
+	})
+
+	config.normalize()
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return config, nil
+}
+
+// File returns the config file in use, if any
+func (l *Loader) File() string {
+	if *l.file != "" {
+		return *l.file
+	}
+	path, _ := l.lookupEnv(FileEnvVar)
+	return path
+}
+
+func loadFile(path string, config *Config) error {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func parseDuration(value string, target *time.Duration) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*target = parsed
+	return nil
+}
+
+// parseSeconds accepts either a plain number of seconds or a duration string
+func parseSeconds(value string, target *time.Duration) error {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		*target = time.Duration(seconds) * time.Second
+		return nil
+	}
+	return parseDuration(value, target)
+}
+
+func parseInt(value string, target *int) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*target = parsed
+	return nil
+}
+
+func parseBool(value string, target *bool) error {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*target = parsed
+	return nil
+}
+
+func parseFloat(value string, target *float64) error {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	*target = parsed
+	return nil
+}
+
+// parseList splits a comma-separated list, dropping empty entries
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
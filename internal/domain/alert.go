@@ -0,0 +1,61 @@
+package domain
+
+// AlertRuleType selects how an alert rule is evaluated
+type AlertRuleType string
+
+const (
+	// AlertAbove fires when the price rises above Threshold
+	AlertAbove AlertRuleType = "above"
+	// AlertBelow fires when the price falls below Threshold
+	AlertBelow AlertRuleType = "below"
+	// AlertCrosses fires whenever the price crosses Threshold in either direction
+	AlertCrosses AlertRuleType = "crosses"
+	// AlertPercentChange fires when the price moves at least Percent within Window
+	AlertPercentChange AlertRuleType = "percent_change"
+)
+
+// AlertSourceConfig marks rules defined in the config file
+const AlertSourceConfig = "config"
+
+// AlertRule describes a condition on the price stream. Window and Cooldown
+// are expressed in seconds. Hysteresis is in USD for level rules and in
+// percentage points for percent change rules.
+type AlertRule struct {
+	ID string `json:"id"`
+	// Owner is the user who created the rule; empty for rules from the config file
+	Owner string `json:"owner,omitempty"`
+	// Source is AlertSourceConfig for rules managed by the config file
+	Source     string        `json:"source,omitempty"`
+	Type       AlertRuleType `json:"type"`
+	Threshold  float64       `json:"threshold,omitempty"`
+	Percent    float64       `json:"percent,omitempty"`
+	Window     int64         `json:"window,omitempty"`
+	Hysteresis float64       `json:"hysteresis,omitempty"`
+	Cooldown   int64         `json:"cooldown,omitempty"`
+}
+
+// AlertState is the evaluation state of a rule. It is persisted so a
+// restart does not re-fire alerts that already fired.
+type AlertState struct {
+	RuleID string `json:"rule_id"`
+	// Armed is false after a rule fires until the price moves back past the
+	// hysteresis band
+	Armed bool `json:"armed"`
+	// Side is the side of the threshold the price was last confirmed on for
+	// crossing rules: 1 above, -1 below, 0 unknown
+	Side      int   `json:"side"`
+	LastFired int64 `json:"last_fired,omitempty"`
+}
+
+// Alert is a fired alert rule
+type Alert struct {
+	RuleID    string        `json:"rule_id"`
+	Owner     string        `json:"owner,omitempty"`
+	Type      AlertRuleType `json:"type"`
+	Timestamp int64         `json:"timestamp"`
+	Price     Price         `json:"price"`
+	Threshold float64       `json:"threshold,omitempty"`
+	// Change is the percent move that fired a percent change rule
+	Change  float64 `json:"change,omitempty"`
+	Message string  `json:"message"`
+}
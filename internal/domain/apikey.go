@@ -0,0 +1,37 @@
+package domain
+
+// APIKey grants access to the stream and the REST API. Only a hash of the
+// key is kept; the key itself is returned once, when it is issued.
+type APIKey struct {
+	ID string `json:"id"`
+	// Owner is the user requests made with the key act as
+	Owner string `json:"owner"`
+	// Prefix is the start of the key, shown to help identify it
+	Prefix string `json:"prefix"`
+	Hash   string `json:"hash,omitempty"`
+	Admin  bool   `json:"admin,omitempty"`
+	// MaxConnections limits concurrent streams; 0 means unlimited
+	MaxConnections int `json:"max_connections"`
+	// DailyQuota limits requests per UTC day; 0 means unlimited
+	DailyQuota int64 `json:"daily_quota"`
+	CreatedAt  int64 `json:"created_at"`
+	// RevokedAt is set once the key is revoked; revoked keys are kept for
+	// their usage history
+	RevokedAt int64 `json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key can no longer be used
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != 0
+}
+
+// APIKeyUsage counts the requests made with a key on one UTC day
+type APIKeyUsage struct {
+	KeyID string `json:"key_id"`
+	// Day is the UTC date, e.g. 2024-04-01
+	Day      string `json:"day"`
+	Requests int64  `json:"requests"`
+	// Rejected counts requests refused by the quota or connection limit
+	Rejected   int64 `json:"rejected"`
+	LastUsedAt int64 `json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,13 @@
+package domain
+
+// Candle is an OHLC aggregate of price updates over a fixed resolution.
+// Timestamp is the Unix time of the start of the bucket.
+type Candle struct {
+	Timestamp  int64 `json:"timestamp"`
+	Resolution int64 `json:"resolution"`
+	Open       Price `json:"open"`
+	High       Price `json:"high"`
+	Low        Price `json:"low"`
+	Close      Price `json:"close"`
+	Count      int64 `json:"count"`
+}
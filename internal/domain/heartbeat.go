@@ -0,0 +1,7 @@
+package domain
+
+// Heartbeat marks a fetch whose price was unchanged and therefore neither
+// stored nor broadcast, so clients can tell a flat market from a dead feed
+type Heartbeat struct {
+	Timestamp int64 `json:"timestamp"`
+}
@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+// Price is an exact decimal USD price. The zero value is a price of 0.
+type Price struct {
+	value decimal.Decimal
+}
+
+// ParsePrice parses a decimal string such as an exchange quote
+func ParsePrice(s string) (Price, error) {
+	value, err := decimal.NewFromString(s)
+	if err != nil {
+		return Price{}, fmt.Errorf("invalid price %q: %w", s, err)
+	}
+	return Price{value: value}, nil
+}
+
+// NewPrice converts a float to the shortest decimal that represents it, so
+// NewPrice(0.1) is exactly 0.1. It is meant for values that are already
+// floats, such as documents stored before prices were decimals. NaN and
+// infinities have no decimal form and become zero.
+func NewPrice(f float64) Price {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Price{}
+	}
+	return Price{value: decimal.NewFromFloat(f)}
+}
+
+// Float64 returns the nearest float, for statistics and indicators
+func (p Price) Float64() float64 {
+	return p.value.InexactFloat64()
+}
+
+// String returns the exact decimal representation
+func (p Price) String() string {
+	return p.value.String()
+}
+
+// Sign returns -1, 0 or 1 for negative, zero and positive prices
+func (p Price) Sign() int {
+	return p.value.Sign()
+}
+
+// Cmp compares p and other, returning -1, 0 or 1
+func (p Price) Cmp(other Price) int {
+	return p.value.Cmp(other.value)
+}
+
+// Equal reports whether p and other are the same amount, regardless of
+// trailing zeros
+func (p Price) Equal(other Price) bool {
+	return p.value.Equal(other.value)
+}
+
+// Sub returns the exact difference p - other
+func (p Price) Sub(other Price) Price {
+	return Price{value: p.value.Sub(other.value)}
+}
+
+// Add returns the exact sum p + other
+func (p Price) Add(other Price) Price {
+	return Price{value: p.value.Add(other.value)}
+}
+
+// Abs returns the absolute value of p
+func (p Price) Abs() Price {
+	return Price{value: p.value.Abs()}
+}
+
+// Half returns p / 2, which is always exact
+func (p Price) Half() Price {
+	return Price{value: p.value.Div(decimal.NewFromInt(2))}
+}
+
+// Percent returns percent percent of p. The percentage is converted like
+// NewPrice, so Percent(10) is exactly a tenth.
+func (p Price) Percent(percent float64) Price {
+	return Price{value: p.value.Mul(NewPrice(percent).value).Div(decimal.NewFromInt(100))}
+}
+
+// MarshalJSON encodes the price as a decimal string. The API can send
+// numbers instead, see api.PriceFormat.
+func (p Price) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + p.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts both the string and the number form
+func (p *Price) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*p = Price{}
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+
+	parsed, err := ParsePrice(string(data))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
+
+// LogValue renders prices in log output as their exact decimal
+func (p Price) LogValue() slog.Value {
+	return slog.StringValue(p.String())
+}
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParsePrice_KeepsDigits(t *testing.T) {
+	price, err := ParsePrice("69420.12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price.String() != "69420.12345678" {
+		t.Errorf("Expected 69420.12345678, got %s", price)
+	}
+
+	if NewPrice(0.1).String() != "0.1" {
+		t.Errorf("Expected NewPrice to use the shortest decimal form, got %s", NewPrice(0.1))
+	}
+
+	if _, err := ParsePrice("abc"); err == nil {
+		t.Error("Expected error for non-numeric price")
+	}
+	if NewPrice(math.NaN()).Sign() != 0 || NewPrice(math.Inf(1)).Sign() != 0 {
+		t.Error("Expected NaN and infinity to become zero")
+	}
+}
+
+func TestPrice_Sub(t *testing.T) {
+	a, _ := ParsePrice("69420.3")
+	b, _ := ParsePrice("69420.1")
+	if diff := a.Sub(b); diff.String() != "0.2" {
+		t.Errorf("Expected an exact difference of 0.2, got %s", diff)
+	}
+	if b.Sub(a).Sign() != -1 {
+		t.Error("Expected a negative difference")
+	}
+}
+
+func TestPrice_JSON(t *testing.T) {
+	event := PriceUpdateEvent{Timestamp: 100, Price: mustParsePrice(t, "69420.10")}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"timestamp":100,"price":"69420.1"}` {
+		t.Errorf("Unexpected string encoding: %s", data)
+	}
+
+	for _, input := range []string{`{"price":"69420.1"}`, `{"price":69420.1}`} {
+		var decoded PriceUpdateEvent
+		if err := json.Unmarshal([]byte(input), &decoded); err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if !decoded.Price.Equal(event.Price) {
+			t.Errorf("%s: expected 69420.1, got %s", input, decoded.Price)
+		}
+	}
+
+	for _, input := range []string{`{"price":"high"}`, `{"price":"123}`, `{"price":"\"123"}`, `{"price":"123\""}`, `{"price":"\"\"123\"\""}`, `{"price":""}`, `{"price":"null"}`} {
+		var decoded PriceUpdateEvent
+		if err := json.Unmarshal([]byte(input), &decoded); err == nil {
+			t.Errorf("%s: expected an error, got %s", input, decoded.Price)
+		}
+	}
+}
+
+func mustParsePrice(t *testing.T, s string) Price {
+	t.Helper()
+
+	price, err := ParsePrice(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return price
+}
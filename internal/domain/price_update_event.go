@@ -1,6 +1,13 @@
 package domain
 
+// PriceUpdateEvent is a stored price. There is at most one event per second,
+// so the Unix timestamp identifies it, e.g. as the stream's event ID.
 type PriceUpdateEvent struct {
-	Timestamp int64   `json:"timestamp"`
-	Price     float64 `json:"price"`
+	Timestamp int64 `json:"timestamp"`
+	Price     Price `json:"price"`
+	// Backfilled marks events recovered from exchange history rather than live polling
+	Backfilled bool `json:"backfilled,omitempty"`
+	// TraceParent is the W3C trace context of the poll that produced the
+	// event, carried through store and broadcast for tracing. Never persisted.
+	TraceParent string `json:"-"`
 }
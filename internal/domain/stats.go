@@ -0,0 +1,26 @@
+package domain
+
+// WindowStats summarizes the prices in a rolling window ending now. Change,
+// StdDev and Volatility are zero until the window holds two events.
+type WindowStats struct {
+	// Window is the window length, e.g. "24h"
+	Window string  `json:"window"`
+	Count  int     `json:"count"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	// Change is the percent change from Open to Close
+	Change float64 `json:"change"`
+	// StdDev is the population standard deviation of the prices in USD
+	StdDev float64 `json:"stddev"`
+	// Volatility is the realized volatility over the window, the square root
+	// of the summed squared log returns, in percent
+	Volatility float64 `json:"volatility"`
+}
+
+// PriceStats holds the rolling statistics after the event at Timestamp
+type PriceStats struct {
+	Timestamp int64         `json:"timestamp"`
+	Windows   []WindowStats `json:"windows"`
+}
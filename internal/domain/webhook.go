@@ -0,0 +1,32 @@
+package domain
+
+// Webhook event types
+const (
+	WebhookEventPrice = "price"
+	WebhookEventAlert = "alert"
+)
+
+// Webhook is an HTTP endpoint that receives price events and fired alerts.
+// Deliveries are signed with Secret.
+type Webhook struct {
+	ID     string `json:"id"`
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Events limits deliveries to the listed event types; empty means all
+	Events []string `json:"events,omitempty"`
+	// Rules limits alert deliveries to the listed rule IDs; empty means all
+	Rules     []string `json:"rules,omitempty"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+// DeadLetter is a webhook delivery that failed after every retry
+type DeadLetter struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	Event     string `json:"event"`
+	// Payload is the JSON body that could not be delivered
+	Payload   string `json:"payload"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error"`
+	FailedAt  int64  `json:"failed_at"`
+}
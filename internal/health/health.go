@@ -0,0 +1,163 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Broadcaster is the part of BroadcastService the checker depends on
+type Broadcaster interface {
+	IsRunning() bool
+	ClientCount() int
+}
+
+// HeartbeatSource reports the Unix time of the latest fetched price,
+// including unchanged prices that were not stored
+type HeartbeatSource interface {
+	LastHeartbeat() int64
+}
+
+// ComponentStatus is the health of a single component
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report is the JSON body returned by the readiness endpoint
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Checker reports liveness and readiness of the service
+type Checker struct {
+	store       store.EventStore
+	broadcaster Broadcaster
+	heartbeats  HeartbeatSource
+	staleAfter  time.Duration
+	startedAt   time.Time
+	now         func() time.Time
+}
+
+// NewChecker creates a checker that reports the provider as failing once
+// the latest stored event is older than staleAfter
+func NewChecker(store store.EventStore, broadcaster Broadcaster, staleAfter time.Duration) *Checker {
+	return &Checker{
+		store:       store,
+		broadcaster: broadcaster,
+		staleAfter:  staleAfter,
+		startedAt:   time.Now(),
+		now:         time.Now,
+	}
+}
+
+// UseHeartbeats makes fetches of unchanged prices count as fresh updates,
+// for when duplicates are not stored
+func (c *Checker) UseHeartbeats(heartbeats HeartbeatSource) {
+	c.heartbeats = heartbeats
+}
+
+// Check evaluates every component
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{
+		Status: StatusOK,
+		Components: map[string]ComponentStatus{
+			"store":       c.checkStore(ctx),
+			"provider":    c.checkProvider(),
+			"broadcaster": c.checkBroadcaster(),
+		},
+	}
+
+	for _, component := range report.Components {
+		if component.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+
+	return report
+}
+
+func (c *Checker) checkStore(ctx context.Context) ComponentStatus {
+	pinger, ok := c.store.(store.Pinger)
+	if !ok {
+		return ComponentStatus{Status: StatusOK}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if err := pinger.Ping(ctx); err != nil {
+		return ComponentStatus{Status: StatusFail, Detail: err.Error()}
+	}
+	return ComponentStatus{Status: StatusOK}
+}
+
+func (c *Checker) checkProvider() ComponentStatus {
+	now := c.now()
+
+	latest, exists := c.store.GetLatestEvent()
+	if c.heartbeats != nil {
+		if heartbeat := c.heartbeats.LastHeartbeat(); heartbeat > latest.Timestamp {
+			latest.Timestamp, exists = heartbeat, true
+		}
+	}
+	if !exists {
+		// Give the provider one staleness window after startup to deliver a price
+		if now.Sub(c.startedAt) < c.staleAfter {
+			return ComponentStatus{Status: StatusOK, Detail: "waiting for first price update"}
+		}
+		return ComponentStatus{Status: StatusFail, Detail: "no price updates received"}
+	}
+
+	age := now.Sub(time.Unix(latest.Timestamp, 0)).Truncate(time.Second)
+	if age > c.staleAfter {
+		return ComponentStatus{
+			Status: StatusFail,
+			Detail: fmt.Sprintf("latest price update is %v old, threshold is %v", age, c.staleAfter),
+		}
+	}
+	return ComponentStatus{Status: StatusOK, Detail: fmt.Sprintf("latest price update is %v old", age)}
+}
+
+func (c *Checker) checkBroadcaster() ComponentStatus {
+	if !c.broadcaster.IsRunning() {
+		return ComponentStatus{Status: StatusFail, Detail: "broadcast loop is not running"}
+	}
+	return ComponentStatus{Status: StatusOK, Detail: fmt.Sprintf("%d connected clients", c.broadcaster.ClientCount())}
+}
+
+// LivenessHandler reports that the process is up and serving requests
+func (c *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// ReadinessHandler reports whether the service can serve fresh prices
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Error writing health response", "error", err)
+	}
+}
@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+type mockBroadcaster struct {
+	running bool
+}
+
+func (m *mockBroadcaster) IsRunning() bool  { return m.running }
+func (m *mockBroadcaster) ClientCount() int { return 2 }
+
+type unreachableStore struct {
+	*store.MemoryStore
+}
+
+func (s unreachableStore) Ping(context.Context) error {
+	return errors.New("server selection timeout")
+}
+
+func TestChecker_ReadinessHandler(t *testing.T) {
+	now := time.Unix(10_000, 0)
+
+	fresh := store.NewMemoryStore(10)
+	fresh.Store(domain.PriceUpdateEvent{Timestamp: now.Unix() - 5, Price: domain.NewPrice(50000)})
+
+	stale := store.NewMemoryStore(10)
+	stale.Store(domain.PriceUpdateEvent{Timestamp: now.Unix() - 120, Price: domain.NewPrice(50000)})
+
+	tests := []struct {
+		name           string
+		store          store.EventStore
+		running        bool
+		startedAt      time.Time
+		expectedStatus int
+		failing        string
+	}{
+		{"Healthy", fresh, true, now.Add(-time.Hour), http.StatusOK, ""},
+		{"Stale provider", stale, true, now.Add(-time.Hour), http.StatusServiceUnavailable, "provider"},
+		{"Unreachable store", unreachableStore{fresh}, true, now.Add(-time.Hour), http.StatusServiceUnavailable, "store"},
+		{"Broadcaster stopped", fresh, false, now.Add(-time.Hour), http.StatusServiceUnavailable, "broadcaster"},
+		{"Waiting for first price", store.NewMemoryStore(10), true, now.Add(-time.Second), http.StatusOK, ""},
+		{"No prices after grace period", store.NewMemoryStore(10), true, now.Add(-time.Hour), http.StatusServiceUnavailable, "provider"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			checker := NewChecker(tc.store, &mockBroadcaster{running: tc.running}, time.Minute)
+			checker.now = func() time.Time { return now }
+			checker.startedAt = tc.startedAt
+
+			w := httptest.NewRecorder()
+			checker.ReadinessHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			var report Report
+			if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+				t.Fatalf("Invalid JSON body: %v", err)
+			}
+			if len(report.Components) != 3 {
+				t.Errorf("Expected 3 components, got %v", report.Components)
+			}
+			for name, component := range report.Components {
+				shouldFail := name == tc.failing
+				if (component.Status == StatusFail) != shouldFail {
+					t.Errorf("Component %s: unexpected status %q (%s)", name, component.Status, component.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestChecker_LivenessHandler(t *testing.T) {
+	checker := NewChecker(store.NewMemoryStore(10), &mockBroadcaster{}, time.Minute)
+
+	w := httptest.NewRecorder()
+	checker.LivenessHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+type heartbeatFunc func() int64
+
+func (f heartbeatFunc) LastHeartbeat() int64 { return f() }
+
+func TestChecker_Heartbeats(t *testing.T) {
+	now := time.Unix(10_000, 0)
+
+	stale := store.NewMemoryStore(10)
+	stale.Store(domain.PriceUpdateEvent{Timestamp: now.Unix() - 120, Price: domain.NewPrice(50000)})
+
+	checker := NewChecker(stale, &mockBroadcaster{running: true}, time.Minute)
+	checker.now = func() time.Time { return now }
+	checker.UseHeartbeats(heartbeatFunc(func() int64 { return now.Unix() - 5 }))
+
+	// Unchanged prices that were not stored still count as fresh
+	if status := checker.checkProvider(); status.Status != StatusOK {
+		t.Errorf("Expected a recent heartbeat to keep the provider healthy, got %+v", status)
+	}
+}
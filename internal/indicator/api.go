@@ -0,0 +1,110 @@
+package indicator
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+)
+
+// defaultRange is the span of history returned when from is not given
+const defaultRange = time.Hour
+
+// seriesResponse is the body returned for a history query
+type seriesResponse struct {
+	Indicators []string        `json:"indicators"`
+	Points     []PointResponse `json:"points"`
+}
+
+// RegisterRoutes adds the indicator API to mux. Indicators are selected with
+// the indicators query parameter, e.g. ?indicators=sma:20,rsi:14,bb:20:2.
+func (s *Service) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/indicators", s.getSeries)
+	mux.HandleFunc("GET /api/v1/indicators/latest", s.getLatest)
+}
+
+// getSeries returns indicator values for each stored event between the
+// from and to query parameters, in Unix seconds. It defaults to the last hour
+// and rejects ranges or results above the configured maximum.
+func (s *Service) getSeries(w http.ResponseWriter, r *http.Request) {
+	specs, ok := s.querySpecs(w, r)
+	if !ok {
+		return
+	}
+
+	to, ok := queryTimestamp(w, r, "to", time.Now().Unix())
+	if !ok {
+		return
+	}
+	from, ok := queryTimestamp(w, r, "from", to-int64(defaultRange.Seconds()))
+	if !ok {
+		return
+	}
+	if from > to {
+		api.WriteError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+	if to-from > int64(s.cfg.MaxRange.Seconds()) {
+		api.WriteError(w, http.StatusBadRequest, "range must not exceed %s", s.cfg.MaxRange)
+		return
+	}
+
+	points, err := s.Series(specs, from, to)
+	if errors.Is(err, ErrTooManyPoints) {
+		api.WriteError(w, http.StatusBadRequest, "range holds more than %d points, narrow it", s.cfg.MaxPoints)
+		return
+	}
+	if err != nil {
+		api.WriteError(w, http.StatusInternalServerError, "reading history: %v", err)
+		return
+	}
+
+	response := seriesResponse{Points: make([]PointResponse, len(points))}
+	for i, point := range points {
+		response.Points[i] = point.Response(s.priceFormat)
+	}
+	for _, spec := range specs {
+		response.Indicators = append(response.Indicators, spec.Name())
+	}
+	api.WriteJSON(w, http.StatusOK, response)
+}
+
+// getLatest returns the indicator values after the latest stored event
+func (s *Service) getLatest(w http.ResponseWriter, r *http.Request) {
+	specs, ok := s.querySpecs(w, r)
+	if !ok {
+		return
+	}
+
+	latest, exists := s.store.GetLatestEvent()
+	if !exists {
+		api.WriteError(w, http.StatusNotFound, "no price data yet")
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, s.NewSet(specs, latest.Timestamp).Point().Response(s.priceFormat))
+}
+
+func (s *Service) querySpecs(w http.ResponseWriter, r *http.Request) ([]Spec, bool) {
+	specs, err := s.ParseSpecs(r.URL.Query().Get("indicators"))
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "%v", err)
+		return nil, false
+	}
+	return specs, true
+}
+
+func queryTimestamp(w http.ResponseWriter, r *http.Request, name string, fallback int64) (int64, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, true
+	}
+
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "%s must be a Unix timestamp in seconds", name)
+		return 0, false
+	}
+	return timestamp, true
+}
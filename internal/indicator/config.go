@@ -0,0 +1,49 @@
+package indicator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config controls indicator computation
+type Config struct {
+	// Warmup is how much history before the first reported point is replayed
+	// so indicators are ready immediately
+	Warmup time.Duration `yaml:"warmup"`
+	// MaxPeriod bounds the period clients may request
+	MaxPeriod int `yaml:"max_period"`
+	// MaxRange and MaxPoints bound the span and size of one history query
+	MaxRange  time.Duration `yaml:"max_range"`
+	MaxPoints int           `yaml:"max_points"`
+}
+
+// DefaultConfig returns the indicator defaults
+func DefaultConfig() Config {
+	return Config{
+		Warmup:    24 * time.Hour,
+		MaxPeriod: 500,
+		MaxRange:  7 * 24 * time.Hour,
+		MaxPoints: 10000,
+	}
+}
+
+// Validate checks the indicator settings
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Warmup < 0 {
+		errs = append(errs, fmt.Errorf("indicators.warmup must not be negative, got %v", c.Warmup))
+	}
+	if c.MaxPeriod < 1 {
+		errs = append(errs, fmt.Errorf("indicators.max_period must be at least 1, got %d", c.MaxPeriod))
+	}
+	if c.MaxRange < time.Second {
+		errs = append(errs, fmt.Errorf("indicators.max_range must be at least 1s, got %v", c.MaxRange))
+	}
+	if c.MaxPoints < 1 {
+		errs = append(errs, fmt.Errorf("indicators.max_points must be at least 1, got %d", c.MaxPoints))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,295 @@
+// Package indicator computes technical indicators over the price stream,
+// either incrementally as events arrive or over stored history.
+package indicator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Indicator kinds
+const (
+	KindSMA       = "sma"
+	KindEMA       = "ema"
+	KindRSI       = "rsi"
+	KindBollinger = "bb"
+)
+
+// defaultBollingerWidth is the number of standard deviations between the
+// middle and outer Bollinger bands when none is given
+const defaultBollingerWidth = 2
+
+// Spec selects an indicator and its parameters, written as "sma:20",
+// "ema:50", "rsi:14" or "bb:20:2"
+type Spec struct {
+	Kind   string
+	Period int
+	// Width is the Bollinger band width in standard deviations
+	Width float64
+}
+
+// Name identifies the indicator in responses, e.g. "sma_20" or "bb_20_2"
+func (s Spec) Name() string {
+	if s.Kind == KindBollinger {
+		return fmt.Sprintf("%s_%d_%s", s.Kind, s.Period, strconv.FormatFloat(s.Width, 'f', -1, 64))
+	}
+	return fmt.Sprintf("%s_%d", s.Kind, s.Period)
+}
+
+// ParseSpecs parses a comma-separated list of indicator specs. Periods are
+// limited to maxPeriod.
+func ParseSpecs(value string, maxPeriod int) ([]Spec, error) {
+	var specs []Spec
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		spec, err := parseSpec(item, maxPeriod)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[spec.Name()] {
+			seen[spec.Name()] = true
+			specs = append(specs, spec)
+		}
+	}
+
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no indicators given")
+	}
+	return specs, nil
+}
+
+func parseSpec(item string, maxPeriod int) (Spec, error) {
+	parts := strings.Split(strings.ToLower(item), ":")
+
+	spec := Spec{Kind: parts[0]}
+	switch spec.Kind {
+	case KindSMA, KindEMA, KindRSI:
+		if len(parts) != 2 {
+			return Spec{}, fmt.Errorf("indicator %q: expected %s:<period>", item, spec.Kind)
+		}
+	case KindBollinger:
+		if len(parts) != 2 && len(parts) != 3 {
+			return Spec{}, fmt.Errorf("indicator %q: expected bb:<period>[:<width>]", item)
+		}
+		spec.Width = defaultBollingerWidth
+		if len(parts) == 3 {
+			width, err := strconv.ParseFloat(parts[2], 64)
+			if err != nil || width <= 0 || math.IsInf(width, 0) {
+				return Spec{}, fmt.Errorf("indicator %q: width must be a positive number", item)
+			}
+			spec.Width = width
+		}
+	default:
+		return Spec{}, fmt.Errorf("indicator %q: kind must be sma, ema, rsi or bb", item)
+	}
+
+	period, err := strconv.Atoi(parts[1])
+	if err != nil || period < 1 || period > maxPeriod {
+		return Spec{}, fmt.Errorf("indicator %q: period must be between 1 and %d", item, maxPeriod)
+	}
+	spec.Period = period
+
+	return spec, nil
+}
+
+// Value is the current reading of an indicator. Upper and Lower are only
+// set for Bollinger bands, whose Value is the middle band.
+type Value struct {
+	Value float64 `json:"value"`
+	Upper float64 `json:"upper,omitempty"`
+	Lower float64 `json:"lower,omitempty"`
+}
+
+// Indicator is updated with one price at a time
+type Indicator interface {
+	Update(price float64)
+	// Value returns the current reading, or false until enough prices have
+	// been seen
+	Value() (Value, bool)
+}
+
+// New creates an empty indicator for spec
+func New(spec Spec) Indicator {
+	switch spec.Kind {
+	case KindEMA:
+		return newEMA(spec.Period)
+	case KindRSI:
+		return newRSI(spec.Period)
+	case KindBollinger:
+		return newBollinger(spec.Period, spec.Width)
+	default:
+		return newSMA(spec.Period)
+	}
+}
+
+// window is a fixed-size ring of the most recent prices with running sums
+type window struct {
+	prices []float64
+	next   int
+	full   bool
+	sum    float64
+	sumSq  float64
+}
+
+func newWindow(size int) *window {
+	return &window{prices: make([]float64, size)}
+}
+
+func (w *window) push(price float64) {
+	old := w.prices[w.next]
+	if w.full {
+		w.sum -= old
+		w.sumSq -= old * old
+	}
+	w.prices[w.next] = price
+	w.sum += price
+	w.sumSq += price * price
+
+	w.next = (w.next + 1) % len(w.prices)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+func (w *window) mean() float64 {
+	return w.sum / float64(len(w.prices))
+}
+
+// stddev is the population standard deviation of the window
+func (w *window) stddev() float64 {
+	n := float64(len(w.prices))
+	variance := w.sumSq/n - (w.sum/n)*(w.sum/n)
+	return math.Sqrt(math.Max(variance, 0))
+}
+
+// sma is the simple moving average
+type sma struct {
+	window *window
+}
+
+func newSMA(period int) *sma {
+	return &sma{window: newWindow(period)}
+}
+
+func (s *sma) Update(price float64) {
+	s.window.push(price)
+}
+
+func (s *sma) Value() (Value, bool) {
+	if !s.window.full {
+		return Value{}, false
+	}
+	return Value{Value: s.window.mean()}, true
+}
+
+// ema is the exponential moving average, seeded with the SMA of the first
+// period prices
+type ema struct {
+	period int
+	alpha  float64
+	count  int
+	sum    float64
+	value  float64
+}
+
+func newEMA(period int) *ema {
+	return &ema{period: period, alpha: 2 / float64(period+1)}
+}
+
+func (e *ema) Update(price float64) {
+	e.count++
+	switch {
+	case e.count < e.period:
+		e.sum += price
+	case e.count == e.period:
+		e.value = (e.sum + price) / float64(e.period)
+	default:
+		e.value += e.alpha * (price - e.value)
+	}
+}
+
+func (e *ema) Value() (Value, bool) {
+	if e.count < e.period {
+		return Value{}, false
+	}
+	return Value{Value: e.value}, true
+}
+
+// rsi is Wilder's relative strength index
+type rsi struct {
+	period  int
+	count   int
+	last    float64
+	avgGain float64
+	avgLoss float64
+}
+
+func newRSI(period int) *rsi {
+	return &rsi{period: period}
+}
+
+func (r *rsi) Update(price float64) {
+	r.count++
+	if r.count == 1 {
+		r.last = price
+		return
+	}
+
+	change := price - r.last
+	r.last = price
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+
+	changes := r.count - 1
+	if changes <= r.period {
+		// Plain average over the first period changes
+		r.avgGain += (gain - r.avgGain) / float64(changes)
+		r.avgLoss += (loss - r.avgLoss) / float64(changes)
+		return
+	}
+
+	n := float64(r.period)
+	r.avgGain = (r.avgGain*(n-1) + gain) / n
+	r.avgLoss = (r.avgLoss*(n-1) + loss) / n
+}
+
+func (r *rsi) Value() (Value, bool) {
+	if r.count <= r.period {
+		return Value{}, false
+	}
+	if r.avgLoss == 0 {
+		return Value{Value: 100}, true
+	}
+	rs := r.avgGain / r.avgLoss
+	return Value{Value: 100 - 100/(1+rs)}, true
+}
+
+// bollinger bands are the SMA plus and minus width standard deviations
+type bollinger struct {
+	window *window
+	width  float64
+}
+
+func newBollinger(period int, width float64) *bollinger {
+	return &bollinger{window: newWindow(period), width: width}
+}
+
+func (b *bollinger) Update(price float64) {
+	b.window.push(price)
+}
+
+func (b *bollinger) Value() (Value, bool) {
+	if !b.window.full {
+		return Value{}, false
+	}
+	middle := b.window.mean()
+	offset := b.width * b.window.stddev()
+	return Value{Value: middle, Upper: middle + offset, Lower: middle - offset}, true
+}
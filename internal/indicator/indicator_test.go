@@ -0,0 +1,260 @@
+package indicator
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func feed(indicator Indicator, prices ...float64) (Value, bool) {
+	for _, price := range prices {
+		indicator.Update(price)
+	}
+	return indicator.Value()
+}
+
+func TestParseSpecs(t *testing.T) {
+	specs, err := ParseSpecs("sma:20, EMA:50,rsi:14,bb:20,bb:20:2,bb:10:1.5", 100)
+	if err != nil {
+		t.Fatalf("ParseSpecs failed: %v", err)
+	}
+
+	var names []string
+	for _, spec := range specs {
+		names = append(names, spec.Name())
+	}
+	expected := []string{"sma_20", "ema_50", "rsi_14", "bb_20_2", "bb_10_1.5"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, names)
+		}
+	}
+
+	for _, invalid := range []string{"", "macd:12", "sma", "sma:0", "sma:101", "rsi:x", "bb:20:-1"} {
+		if _, err := ParseSpecs(invalid, 100); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestSMA(t *testing.T) {
+	sma := New(Spec{Kind: KindSMA, Period: 3})
+
+	if _, ok := feed(sma, 1, 2); ok {
+		t.Error("Expected SMA to be unavailable before the window is full")
+	}
+	if value, _ := feed(sma, 3); !approxEqual(value.Value, 2) {
+		t.Errorf("Expected SMA 2, got %v", value.Value)
+	}
+	if value, _ := feed(sma, 10); !approxEqual(value.Value, 5) {
+		t.Errorf("Expected SMA 5 after the window rolls, got %v", value.Value)
+	}
+}
+
+func TestEMA(t *testing.T) {
+	ema := New(Spec{Kind: KindEMA, Period: 3})
+
+	// Seeded with the SMA of the first three prices, then alpha = 0.5
+	if value, _ := feed(ema, 1, 2, 3); !approxEqual(value.Value, 2) {
+		t.Errorf("Expected seed EMA 2, got %v", value.Value)
+	}
+	if value, _ := feed(ema, 6); !approxEqual(value.Value, 4) {
+		t.Errorf("Expected EMA 4, got %v", value.Value)
+	}
+}
+
+func TestRSI(t *testing.T) {
+	rsi := New(Spec{Kind: KindRSI, Period: 2})
+
+	if _, ok := feed(rsi, 10, 11); ok {
+		t.Error("Expected RSI to need period changes")
+	}
+	// Gains 1 and 0, loss 1: average gain 0.5, loss 0.5
+	if value, _ := feed(rsi, 10); !approxEqual(value.Value, 50) {
+		t.Errorf("Expected RSI 50, got %v", value.Value)
+	}
+	// Wilder smoothing: gain (0.5+2)/2 = 1.25, loss 0.5/2 = 0.25
+	if value, _ := feed(rsi, 12); !approxEqual(value.Value, 100-100/(1+5.0)) {
+		t.Errorf("Expected RSI %v, got %v", 100-100/(1+5.0), value.Value)
+	}
+
+	rising := New(Spec{Kind: KindRSI, Period: 2})
+	if value, _ := feed(rising, 1, 2, 3); value.Value != 100 {
+		t.Errorf("Expected RSI 100 without losses, got %v", value.Value)
+	}
+}
+
+func TestBollinger(t *testing.T) {
+	bb := New(Spec{Kind: KindBollinger, Period: 4, Width: 2})
+
+	value, ok := feed(bb, 2, 4, 4, 6)
+	if !ok {
+		t.Fatal("Expected bands once the window is full")
+	}
+	// Mean 4, population stddev sqrt(2)
+	if !approxEqual(value.Value, 4) || !approxEqual(value.Upper, 4+2*math.Sqrt2) || !approxEqual(value.Lower, 4-2*math.Sqrt2) {
+		t.Errorf("Unexpected bands %+v", value)
+	}
+}
+
+func TestService_Series(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	for i := int64(1); i <= 10; i++ {
+		memStore.Store(domain.PriceUpdateEvent{Timestamp: i * 60, Price: domain.NewPrice(float64(i))})
+	}
+	service := NewService(memStore, Config{Warmup: 5 * time.Minute, MaxPeriod: 10, MaxRange: time.Hour, MaxPoints: 3})
+	specs := []Spec{{Kind: KindSMA, Period: 3}}
+
+	// Events from 5m before from are replayed so the first point is ready
+	points, err := service.Series(specs, 360, 480)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 3 || points[0].Timestamp != 360 || points[2].Timestamp != 480 {
+		t.Fatalf("Expected points for 360..480, got %+v", points)
+	}
+	if value, ok := points[0].Values["sma_3"]; !ok || !approxEqual(value.Value, 5) {
+		t.Errorf("Expected warmed-up SMA 5 at the first point, got %+v", points[0].Values)
+	}
+
+	if _, err := service.Series(specs, 360, 540); !errors.Is(err, ErrTooManyPoints) {
+		t.Errorf("Expected ErrTooManyPoints for 4 points, got %v", err)
+	}
+
+	set := service.NewSet(specs, 600)
+	if point := set.Update(domain.PriceUpdateEvent{Timestamp: 660, Price: domain.NewPrice(20)}); !approxEqual(point.Values["sma_3"].Value, 13) {
+		t.Errorf("Expected live SMA 13, got %+v", point.Values)
+	}
+}
+
+func TestService_SkipsBackfilledEvents(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 60, Price: domain.NewPrice(1)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 120, Price: domain.NewPrice(100), Backfilled: true})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 180, Price: domain.NewPrice(3)})
+	service := NewService(memStore, DefaultConfig())
+
+	points, err := service.Series([]Spec{{Kind: KindSMA, Period: 2}}, 0, 180)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || !approxEqual(points[1].Values["sma_2"].Value, 2) {
+		t.Errorf("Expected the backfilled event to be left out, got %+v", points)
+	}
+}
+
+func TestLive(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	for i := int64(1); i <= 3; i++ {
+		memStore.Store(domain.PriceUpdateEvent{Timestamp: i * 60, Price: domain.NewPrice(float64(i))})
+	}
+	live := NewService(memStore, DefaultConfig()).NewLive(2)
+	sma := []Spec{{Kind: KindSMA, Period: 2}}
+
+	// Warmed from the store on first use
+	if point := live.Watch(sma); point.Timestamp != 180 || !approxEqual(point.Values["sma_2"].Value, 2.5) {
+		t.Fatalf("Expected warmed SMA 2.5 at 180, got %+v", point)
+	}
+
+	// Events already seen while warming up are not counted twice
+	live.Update(domain.PriceUpdateEvent{Timestamp: 180, Price: domain.NewPrice(3)})
+	live.Update(domain.PriceUpdateEvent{Timestamp: 240, Price: domain.NewPrice(5)})
+
+	// Later streams share the warmed indicator and only get their own specs
+	rsi := []Spec{{Kind: KindRSI, Period: 14}}
+	if point := live.Watch(append(rsi, sma...)); !approxEqual(point.Values["sma_2"].Value, 4) {
+		t.Errorf("Expected the shared SMA 4, got %+v", point)
+	}
+	live.Update(domain.PriceUpdateEvent{Timestamp: 300, Price: domain.NewPrice(7)})
+
+	if point, ok := live.Point(240, sma); !ok || !approxEqual(point.Values["sma_2"].Value, 4) || len(point.Values) != 1 {
+		t.Errorf("Expected SMA 4 after 240, got %+v %v", point, ok)
+	}
+	if point, ok := live.Point(300, sma); !ok || !approxEqual(point.Values["sma_2"].Value, 6) {
+		t.Errorf("Expected SMA 6 after 300, got %+v %v", point, ok)
+	}
+	if _, ok := live.Point(180, sma); ok {
+		t.Error("Expected values before the last 2 events to be dropped")
+	}
+
+	live.Release(sma)
+	live.Release(append(rsi, sma...))
+	if len(live.indicators) != 0 {
+		t.Errorf("Expected released indicators to be dropped, got %d", len(live.indicators))
+	}
+}
+
+func TestService_API(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	service := NewService(memStore, DefaultConfig())
+	mux := http.NewServeMux()
+	service.RegisterRoutes(mux)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	if w := get("/api/v1/indicators/latest?indicators=sma:2"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without data, got %d", w.Code)
+	}
+
+	now := time.Now().Unix()
+	for i := int64(0); i < 3; i++ {
+		memStore.Store(domain.PriceUpdateEvent{Timestamp: now - 120 + i*60, Price: domain.NewPrice(float64(100 + i))})
+	}
+
+	w := get("/api/v1/indicators?indicators=sma:2,rsi:2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var series struct {
+		Indicators []string `json:"indicators"`
+		Points     []Point  `json:"points"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &series); err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Indicators) != 2 || len(series.Points) != 3 {
+		t.Errorf("Expected 2 indicators over 3 points, got %+v", series)
+	}
+
+	if w := get("/api/v1/indicators?indicators=sma:2&from=0"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a range above indicators.max_range, got %d", w.Code)
+	}
+
+	w = get("/api/v1/indicators/latest?indicators=sma:2")
+	var latest Point
+	if err := json.Unmarshal(w.Body.Bytes(), &latest); err != nil {
+		t.Fatal(err)
+	}
+	if latest.Timestamp != now || !approxEqual(latest.Values["sma_2"].Value, 101.5) {
+		t.Errorf("Unexpected latest point %+v", latest)
+	}
+
+	for _, url := range []string{
+		"/api/v1/indicators",
+		"/api/v1/indicators?indicators=sma:1000",
+		"/api/v1/indicators?indicators=sma:2&from=yesterday",
+		"/api/v1/indicators?indicators=sma:2&from=200&to=100",
+	} {
+		if w := get(url); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", url, w.Code)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package indicator
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// Live keeps one warmed indicator per spec up to date with the live price
+// stream, shared by every stream that asks for it, so opening a stream does
+// not replay the warmup window again. Values after recent events are kept
+// so streams that lag behind still get the values for the event they write.
+type Live struct {
+	service *Service
+	// history is how many recent events values are kept for
+	history int
+
+	mutex      sync.Mutex
+	indicators map[string]*liveIndicator
+	last       domain.PriceUpdateEvent
+	// recent holds the points after the last history events, oldest first
+	recent []Point
+}
+
+// liveIndicator is an indicator shared by refs streams
+type liveIndicator struct {
+	indicator Indicator
+	// last is the timestamp of the last event the indicator has seen
+	last int64
+	refs int
+}
+
+// NewLive creates shared indicators over the service's store that keep the
+// values after the last history events
+func (s *Service) NewLive(history int) *Live {
+	return &Live{
+		service:    s,
+		history:    history,
+		indicators: make(map[string]*liveIndicator),
+	}
+}
+
+// Watch makes the indicators for specs follow the live stream until they
+// are released, warming up those no other stream uses from the store. It
+// returns the values after the latest event.
+func (l *Live) Watch(specs []Spec) Point {
+	for _, spec := range specs {
+		l.mutex.Lock()
+		shared, ok := l.indicators[spec.Name()]
+		if ok {
+			shared.refs++
+		}
+		l.mutex.Unlock()
+		if ok {
+			continue
+		}
+
+		// Warm up without holding up live updates, then catch up on the
+		// events that arrived meanwhile
+		warmed, last := l.warm(spec)
+
+		l.mutex.Lock()
+		if shared, ok := l.indicators[spec.Name()]; ok {
+			shared.refs++
+		} else {
+			if l.last.Timestamp > last.Timestamp {
+				warmed, last = l.catchUp(warmed, last, l.last.Timestamp)
+			}
+			l.indicators[spec.Name()] = &liveIndicator{indicator: warmed, last: last.Timestamp, refs: 1}
+			if last.Timestamp > l.last.Timestamp {
+				l.last = last
+			}
+		}
+		l.mutex.Unlock()
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.point(l.last, specs)
+}
+
+// Release stops following specs for a stream that called Watch with them.
+// Indicators no stream uses any more are dropped.
+func (l *Live) Release(specs []Spec) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, spec := range specs {
+		if shared, ok := l.indicators[spec.Name()]; ok {
+			if shared.refs--; shared.refs <= 0 {
+				delete(l.indicators, spec.Name())
+			}
+		}
+	}
+}
+
+// Update feeds a live event to every watched indicator that has not seen
+// it yet and keeps the resulting values
+func (l *Live) Update(event domain.PriceUpdateEvent) {
+	if event.Backfilled {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.indicators) == 0 {
+		l.last = event
+		l.recent = l.recent[:0]
+		return
+	}
+
+	for _, shared := range l.indicators {
+		if event.Timestamp > shared.last {
+			shared.indicator.Update(event.Price.Float64())
+			shared.last = event.Timestamp
+		}
+	}
+	if event.Timestamp > l.last.Timestamp {
+		l.last = event
+	}
+
+	point := l.point(event, nil)
+	l.recent = append(l.recent, point)
+	if len(l.recent) > l.history {
+		l.recent = l.recent[len(l.recent)-l.history:]
+	}
+}
+
+// Point returns the values of specs after the event at timestamp, or false
+// when the event is no longer among the recent ones
+func (l *Live) Point(timestamp int64, specs []Spec) (Point, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i := len(l.recent) - 1; i >= 0; i-- {
+		if l.recent[i].Timestamp == timestamp {
+			return selectValues(l.recent[i], specs), true
+		}
+	}
+	return Point{}, false
+}
+
+// point returns the current values of specs, or of every watched indicator
+// when specs is nil, after event. Callers hold the mutex.
+func (l *Live) point(event domain.PriceUpdateEvent, specs []Spec) Point {
+	point := Point{Timestamp: event.Timestamp, Price: event.Price, Values: make(map[string]Value, len(l.indicators))}
+	for name, shared := range l.indicators {
+		if value, ok := shared.indicator.Value(); ok {
+			point.Values[name] = value
+		}
+	}
+	if specs == nil {
+		return point
+	}
+	return selectValues(point, specs)
+}
+
+// warm creates an indicator for spec from the warmup window before the
+// latest stored event and returns it with the last event it has seen
+func (l *Live) warm(spec Spec) (Indicator, domain.PriceUpdateEvent) {
+	latest, ok := l.service.store.GetLatestEvent()
+	if !ok {
+		return New(spec), domain.PriceUpdateEvent{}
+	}
+	return l.catchUp(New(spec), domain.PriceUpdateEvent{}, latest.Timestamp)
+}
+
+// catchUp feeds indicator the stored events after last up to until and
+// returns the last event it has seen
+func (l *Live) catchUp(indicator Indicator, last domain.PriceUpdateEvent, until int64) (Indicator, domain.PriceUpdateEvent) {
+	from := max(last.Timestamp+1, until-int64(l.service.cfg.Warmup.Seconds()))
+	err := l.service.replay(from, until, func(event domain.PriceUpdateEvent) error {
+		indicator.Update(event.Price.Float64())
+		last = event
+		return nil
+	})
+	if err != nil {
+		slog.Warn("Error replaying history for indicators", "error", err)
+	}
+	return indicator, last
+}
+
+// selectValues returns point with only the values of specs
+func selectValues(point Point, specs []Spec) Point {
+	selected := Point{Timestamp: point.Timestamp, Price: point.Price, Values: make(map[string]Value, len(specs))}
+	for _, spec := range specs {
+		if value, ok := point.Values[spec.Name()]; ok {
+			selected.Values[spec.Name()] = value
+		}
+	}
+	return selected
+}
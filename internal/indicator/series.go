@@ -0,0 +1,149 @@
+package indicator
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// ErrTooManyPoints is returned by Series when the range holds more events
+// than the configured maximum
+var ErrTooManyPoints = errors.New("too many points")
+
+// Point holds the indicator values after a price event. Indicators that are
+// still warming up are left out of Values.
+type Point struct {
+	Timestamp int64            `json:"timestamp"`
+	Price     domain.Price     `json:"price"`
+	Values    map[string]Value `json:"values"`
+}
+
+// PointResponse is a Point in a response, with its price in the response's
+// format
+type PointResponse struct {
+	Timestamp int64            `json:"timestamp"`
+	Price     api.Price        `json:"price"`
+	Values    map[string]Value `json:"values"`
+}
+
+// Response returns p for a response in format
+func (p Point) Response(format api.PriceFormat) PointResponse {
+	return PointResponse{Timestamp: p.Timestamp, Price: format.Price(p.Price), Values: p.Values}
+}
+
+// Set updates several indicators from the same price stream
+type Set struct {
+	specs      []Spec
+	indicators []Indicator
+	last       domain.PriceUpdateEvent
+}
+
+// NewSet creates empty indicators for specs
+func NewSet(specs []Spec) *Set {
+	set := &Set{specs: specs}
+	for _, spec := range specs {
+		set.indicators = append(set.indicators, New(spec))
+	}
+	return set
+}
+
+// Update feeds event to every indicator and returns the resulting point
+func (s *Set) Update(event domain.PriceUpdateEvent) Point {
+	for _, indicator := range s.indicators {
+		indicator.Update(event.Price.Float64())
+	}
+	s.last = event
+	return s.Point()
+}
+
+// Point returns the values after the last event
+func (s *Set) Point() Point {
+	point := Point{
+		Timestamp: s.last.Timestamp,
+		Price:     s.last.Price,
+		Values:    make(map[string]Value, len(s.specs)),
+	}
+	for i, indicator := range s.indicators {
+		if value, ok := indicator.Value(); ok {
+			point.Values[s.specs[i].Name()] = value
+		}
+	}
+	return point
+}
+
+// Service computes indicators over the events in a store
+type Service struct {
+	store store.EventStore
+	cfg   Config
+
+	// priceFormat encodes prices in responses
+	priceFormat api.PriceFormat
+}
+
+func NewService(store store.EventStore, cfg Config) *Service {
+	return &Service{store: store, cfg: cfg}
+}
+
+// SetPriceFormat selects how responses encode prices
+func (s *Service) SetPriceFormat(format api.PriceFormat) {
+	s.priceFormat = format
+}
+
+// ParseSpecs parses a comma-separated list of specs within the configured
+// period limit
+func (s *Service) ParseSpecs(value string) ([]Spec, error) {
+	return ParseSpecs(value, s.cfg.MaxPeriod)
+}
+
+// Series computes indicators for the stored events between from and to,
+// replaying the warmup window before from first. It stops with
+// ErrTooManyPoints once more than the configured maximum would be returned.
+func (s *Service) Series(specs []Spec, from, to int64) ([]Point, error) {
+	set := NewSet(specs)
+
+	points := []Point{}
+	err := s.replay(from-int64(s.cfg.Warmup.Seconds()), to, func(event domain.PriceUpdateEvent) error {
+		point := set.Update(event)
+		if event.Timestamp < from {
+			return nil
+		}
+		if len(points) == s.cfg.MaxPoints {
+			return ErrTooManyPoints
+		}
+		points = append(points, point)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// NewSet creates indicators for specs primed with the stored events up to
+// timestamp until, ready to be updated with live events
+func (s *Service) NewSet(specs []Spec, until int64) *Set {
+	set := NewSet(specs)
+	err := s.replay(until-int64(s.cfg.Warmup.Seconds()), until, func(event domain.PriceUpdateEvent) error {
+		set.Update(event)
+		return nil
+	})
+	if err != nil {
+		slog.Warn("Error replaying history for indicators", "error", err)
+	}
+	return set
+}
+
+// replay calls fn for every stored event between from and to, inclusive.
+// Backfilled events are left out: periods count events, and backfilled
+// history is spaced by the exchange's candles rather than the poll interval.
+func (s *Service) replay(from, to int64, fn func(domain.PriceUpdateEvent) error) error {
+	return store.EachEvent(s.store, from, to, func(event domain.PriceUpdateEvent) error {
+		if event.Backfilled {
+			return nil
+		}
+		return fn(event)
+	})
+}
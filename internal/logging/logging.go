@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+type contextKey struct{}
+
+// level is shared by every handler installed by Setup so it can be changed
+// at runtime with SetLevel
+var level = new(slog.LevelVar)
+
+// Setup installs the default slog logger. Format is "json" or "text" and
+// level is one of debug, info, warn or error.
+func Setup(w io.Writer, format string, logLevel string) error {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json", "":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q: expected json or text", format)
+	}
+
+	if err := SetLevel(logLevel); err != nil {
+		return err
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// SetLevel changes the minimum level of the logger installed by Setup
+func SetLevel(logLevel string) error {
+	var slogLevel slog.Level
+	if err := slogLevel.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", logLevel, err)
+	}
+	level.Set(slogLevel)
+	return nil
+}
+
+// Fatal logs an error and exits, mirroring log.Fatal for slog
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// credentialsPattern matches the userinfo section of a URI, e.g. "user:pass@"
+var credentialsPattern = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*://)([^@/]*)@`)
+
+// RedactURI hides the password in a connection string so it can be logged.
+// It handles multi-host MongoDB URIs that net/url cannot parse.
+func RedactURI(uri string) string {
+	return credentialsPattern.ReplaceAllStringFunc(uri, func(match string) string {
+		parts := credentialsPattern.FindStringSubmatch(match)
+		scheme, userinfo := parts[1], parts[2]
+
+		user, _, hasPassword := strings.Cut(userinfo, ":")
+		if !hasPassword {
+			return scheme + "xxxxx@"
+		}
+		return scheme + user + ":xxxxx@"
+	})
+}
+
+// RequestID assigns every request an ID, taken from the X-Request-ID header
+// when present, and attaches a logger carrying it to the request context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, requestID)
+
+		logger := slog.Default().With("request_id", requestID)
+		ctx := context.WithValue(r.Context(), contextKey{}, logger)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromContext returns the request-scoped logger, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "btc_price_tracker"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	SSEClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sse_clients",
+		Help:      "Number of connected SSE clients.",
+	})
+
+	BroadcastDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "broadcast_dropped_updates_total",
+		Help:      "Updates not delivered to a client because its buffer was full.",
+	})
+
+	UpdatesSkipped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "update_channel_skipped_total",
+		Help:      "Updates not forwarded to the broadcaster because the update channel was full.",
+	})
+
+	ProviderFetches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_fetches_total",
+		Help:      "Price provider fetches by provider and result.",
+	}, []string{"provider", "result"})
+
+	ProviderFetchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_fetch_duration_seconds",
+		Help:      "Latency of price provider fetches.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	StoreOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_operation_duration_seconds",
+		Help:      "Latency of event store operations by store and operation.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"store", "operation"})
+
+	LastPrice = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_price_usd",
+		Help:      "Most recent BTC/USD price.",
+	})
+
+	UpdatesDeduplicated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_deduplicated_total",
+		Help:      "Fetched prices suppressed as unchanged and replaced by heartbeats.",
+	})
+
+	UpdatesSameSecond = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_same_second_total",
+		Help:      "Fetched prices dropped because an event was already stored for the same second.",
+	})
+
+	TicksQuarantined = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ticks_quarantined_total",
+		Help:      "Fetched prices rejected before storing, by reason: invalid or deviation.",
+	}, []string{"reason"})
+
+	AlertsFired = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_fired_total",
+		Help:      "Fired price alerts by rule type.",
+	}, []string{"type"})
+
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_deliveries_total",
+		Help:      "Webhook delivery attempts by result: success, retry or dead_letter.",
+	}, []string{"result"})
+
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests refused by connection caps and rate limits, by reason: streams, streams_per_ip or requests.",
+	}, []string{"reason"})
+
+	TLSCertificateExpiry = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tls_certificate_expiry_timestamp_seconds",
+		Help:      "Unix time at which the served TLS certificate expires.",
+	})
+
+	AuthRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_rejections_total",
+		Help:      "Requests refused by API key authentication, by reason: unauthorized, forbidden, quota or connections.",
+	}, []string{"reason"})
+)
+
+// latestEventTimestamp holds the Unix time of the most recent event
+var latestEventTimestamp atomic.Int64
+
+// mongoWriterStats is the source for MongoDB write pipeline metrics
+var mongoWriterStats atomic.Pointer[func() MongoWriterStats]
+
+// MongoWriterStats mirrors the MongoDB write pipeline counters
+type MongoWriterStats struct {
+	QueueDepth    float64
+	WriteFailures float64
+	Dropped       float64
+	Spilled       float64
+}
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		SSEClients,
+		BroadcastDropped,
+		UpdatesSkipped,
+		ProviderFetches,
+		ProviderFetchDuration,
+		StoreOperationDuration,
+		LastPrice,
+		UpdatesDeduplicated,
+		UpdatesSameSecond,
+		TicksQuarantined,
+		AlertsFired,
+		WebhookDeliveries,
+		AuthRejections,
+		TLSCertificateExpiry,
+		RateLimited,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "latest_event_age_seconds",
+			Help:      "Seconds since the most recent price update event.",
+		}, latestEventAge),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "mongo_write_queue_depth",
+			Help:      "Events waiting in the MongoDB write queue.",
+		}, func() float64 { return readMongoWriterStats().QueueDepth }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mongo_write_failures_total",
+			Help:      "Failed MongoDB batch write attempts.",
+		}, func() float64 { return readMongoWriterStats().WriteFailures }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mongo_write_dropped_total",
+			Help:      "Events dropped by the MongoDB write pipeline.",
+		}, func() float64 { return readMongoWriterStats().Dropped }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mongo_write_spilled_total",
+			Help:      "Events spilled to disk by the MongoDB write pipeline.",
+		}, func() float64 { return readMongoWriterStats().Spilled }),
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// ObserveFetch records the outcome and latency of a provider fetch
+func ObserveFetch(provider string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	ProviderFetches.WithLabelValues(provider, result).Inc()
+	ProviderFetchDuration.WithLabelValues(provider).Observe(time.Since(start).Seconds())
+}
+
+// ObserveStoreOperation records the latency of a store operation. It is
+// meant to be deferred: defer metrics.ObserveStoreOperation("memory", "store", time.Now())
+func ObserveStoreOperation(store string, operation string, start time.Time) {
+	StoreOperationDuration.WithLabelValues(store, operation).Observe(time.Since(start).Seconds())
+}
+
+// RecordEvent updates the last price and latest event gauges
+func RecordEvent(timestamp int64, price float64) {
+	LastPrice.Set(price)
+	latestEventTimestamp.Store(timestamp)
+}
+
+func latestEventAge() float64 {
+	timestamp := latestEventTimestamp.Load()
+	if timestamp == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(timestamp, 0)).Seconds()
+}
+
+// SetMongoWriterSource registers the function used to read MongoDB write
+// pipeline metrics at scrape time
+func SetMongoWriterSource(source func() MongoWriterStats) {
+	mongoWriterStats.Store(&source)
+}
+
+func readMongoWriterStats() MongoWriterStats {
+	source := mongoWriterStats.Load()
+	if source == nil {
+		return MongoWriterStats{}
+	}
+	return (*source)()
+}
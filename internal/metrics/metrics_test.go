@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_ExposesMetrics(t *testing.T) {
+	SSEClients.Set(3)
+	RecordEvent(time.Now().Add(-30*time.Second).Unix(), 65000.5)
+	ObserveFetch("binance", time.Now(), nil)
+	ObserveFetch("binance", time.Now(), errors.New("timeout"))
+	SetMongoWriterSource(func() MongoWriterStats {
+		return MongoWriterStats{QueueDepth: 7, WriteFailures: 2}
+	})
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	expected := []string{
+		"btc_price_tracker_sse_clients 3",
+		"btc_price_tracker_last_price_usd 65000.5",
+		`btc_price_tracker_provider_fetches_total{provider="binance",result="success"} 1`,
+		`btc_price_tracker_provider_fetches_total{provider="binance",result="error"} 1`,
+		"btc_price_tracker_mongo_write_queue_depth 7",
+		"btc_price_tracker_mongo_write_failures_total 2",
+		"btc_price_tracker_latest_event_age_seconds 3",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected metrics output to contain %q", line)
+		}
+	}
+}
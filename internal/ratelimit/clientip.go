@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIP returns the address of the client that made r. The
+// X-Forwarded-For header is only used when the request came from a trusted
+// proxy; it is read from the right, skipping trusted proxies, so clients
+// cannot pick their address by sending the header themselves.
+func clientIP(r *http.Request, trusted []netip.Prefix) netip.Addr {
+	remote := parseAddr(r.RemoteAddr)
+	if !remote.IsValid() || !isTrusted(remote, trusted) {
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr := parseAddr(strings.TrimSpace(hops[i]))
+		if !addr.IsValid() {
+			break
+		}
+		client = addr
+		if !isTrusted(addr, trusted) {
+			break
+		}
+	}
+	return client
+}
+
+// clientKey returns the address limits are counted against. IPv6 clients
+// are grouped by their /64, since a single host usually holds a whole /64
+// and could otherwise pick a fresh address for every request.
+func clientKey(addr netip.Addr) netip.Addr {
+	if !addr.Is6() {
+		return addr
+	}
+	return netip.PrefixFrom(addr, 64).Masked().Addr()
+}
+
+// forwardedByUntrusted reports whether r carries X-Forwarded-For from a peer
+// that is not a trusted proxy, so the header is ignored
+func forwardedByUntrusted(r *http.Request, trusted []netip.Prefix) bool {
+	if r.Header.Get("X-Forwarded-For") == "" {
+		return false
+	}
+	remote := parseAddr(r.RemoteAddr)
+	return remote.IsValid() && !isTrusted(remote, trusted)
+}
+
+// parseAddr parses an address with or without a port
+func parseAddr(value string) netip.Addr {
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// Config controls connection caps on the stream and request rate limits on
+// the REST API. Limits of 0 are disabled.
+type Config struct {
+	// MaxStreams caps concurrent streams across all clients
+	MaxStreams int `yaml:"max_streams"`
+	// MaxStreamsPerIP caps concurrent streams from one client address
+	MaxStreamsPerIP int `yaml:"max_streams_per_ip"`
+	// RequestsPerSecond and Burst rate limit REST requests per client address
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	Burst             int     `yaml:"burst"`
+	// TrustedProxies lists the addresses or CIDR ranges of proxies whose
+	// X-Forwarded-For header is trusted to name the client
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// DefaultConfig returns limits generous enough for dashboards and scripts
+// that still stop a single client from exhausting the server
+func DefaultConfig() Config {
+	return Config{
+		MaxStreams:        10000,
+		MaxStreamsPerIP:   20,
+		RequestsPerSecond: 10,
+		Burst:             20,
+	}
+}
+
+// Validate checks the limits and trusted proxy ranges
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.MaxStreams < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.max_streams must not be negative, got %d", c.MaxStreams))
+	}
+	if c.MaxStreamsPerIP < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.max_streams_per_ip must not be negative, got %d", c.MaxStreamsPerIP))
+	}
+	if c.RequestsPerSecond < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit.requests_per_second must not be negative, got %v", c.RequestsPerSecond))
+	}
+	if c.RequestsPerSecond > 0 && c.Burst < 1 {
+		errs = append(errs, fmt.Errorf("rate_limit.burst must be at least 1, got %d", c.Burst))
+	}
+	if _, err := parsePrefixes(c.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit.trusted_proxies: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+// parsePrefixes parses addresses and CIDR ranges; a bare address is a
+// single-address range
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address or range %q", value)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
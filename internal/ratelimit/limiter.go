@@ -0,0 +1,192 @@
+// Package ratelimit caps concurrent streams globally and per client address
+// and rate limits REST requests per client address.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+)
+
+const (
+	// streamRetryAfter is the Retry-After sent when a stream cap is reached.
+	// Slots free up as other streams close, which cannot be predicted.
+	streamRetryAfter = 10 * time.Second
+	// idleBucketTTL is how long an unused client bucket is kept
+	idleBucketTTL = 10 * time.Minute
+)
+
+// bucket is a token bucket refilled at the configured rate
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter enforces the stream caps and request rate limits
+type Limiter struct {
+	config  Config
+	trusted []netip.Prefix
+	now     func() time.Time
+
+	// untrustedForward warns once about X-Forwarded-For from untrusted peers
+	untrustedForward sync.Once
+
+	mutex         sync.Mutex
+	streams       int
+	streamsByAddr map[netip.Addr]int
+	buckets       map[netip.Addr]*bucket
+}
+
+// NewLimiter creates a limiter; config must have been validated
+func NewLimiter(config Config) *Limiter {
+	trusted, _ := parsePrefixes(config.TrustedProxies)
+	return &Limiter{
+		config:        config,
+		trusted:       trusted,
+		now:           time.Now,
+		streamsByAddr: make(map[netip.Addr]int),
+		buckets:       make(map[netip.Addr]*bucket),
+	}
+}
+
+// Start drops the buckets of idle clients every minute until ctx is cancelled
+func (l *Limiter) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				l.sweep()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// LimitStreams caps the concurrent streams served by next
+func (l *Limiter) LimitStreams(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := l.client(r)
+		if reason := l.acquireStream(addr); reason != "" {
+			reject(w, r, reason, streamRetryAfter, "too many open streams")
+			return
+		}
+		defer l.releaseStream(addr)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitRequests rate limits requests to next per client address
+func (l *Limiter) LimitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait := l.take(l.client(r)); wait > 0 {
+			reject(w, r, "requests", wait, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// client returns the key that limits r. Requests forwarded by a proxy that
+// is not trusted all count against the proxy, which is logged once since the
+// limits then throttle every client behind it together.
+func (l *Limiter) client(r *http.Request) netip.Addr {
+	if forwardedByUntrusted(r, l.trusted) {
+		l.untrustedForward.Do(func() {
+			logging.FromContext(r.Context()).Warn(
+				"Ignoring X-Forwarded-For from a peer that is not a trusted proxy; all of its clients share one rate limit. "+
+					"List the proxy in rate_limit.trusted_proxies if the server is behind a load balancer",
+				"peer", r.RemoteAddr)
+		})
+	}
+	return clientKey(clientIP(r, l.trusted))
+}
+
+// acquireStream takes a stream slot for addr, or returns which cap refused it
+func (l *Limiter) acquireStream(addr netip.Addr) string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.config.MaxStreams > 0 && l.streams >= l.config.MaxStreams {
+		return "streams"
+	}
+	if l.config.MaxStreamsPerIP > 0 && l.streamsByAddr[addr] >= l.config.MaxStreamsPerIP {
+		return "streams_per_ip"
+	}
+	l.streams++
+	l.streamsByAddr[addr]++
+	return ""
+}
+
+func (l *Limiter) releaseStream(addr netip.Addr) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.streams--
+	l.streamsByAddr[addr]--
+	if l.streamsByAddr[addr] <= 0 {
+		delete(l.streamsByAddr, addr)
+	}
+}
+
+// take removes a token from the bucket of addr. It returns 0 when the
+// request is allowed, otherwise how long until a token is available.
+func (l *Limiter) take(addr netip.Addr) time.Duration {
+	if l.config.RequestsPerSecond <= 0 {
+		return 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[addr]
+	if !ok {
+		b = &bucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[addr] = b
+	}
+
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(l.config.Burst), b.tokens+elapsed*l.config.RequestsPerSecond)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.config.RequestsPerSecond * float64(time.Second))
+}
+
+// sweep drops the buckets of clients idle long enough to be full again
+func (l *Limiter) sweep() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	cutoff := l.now().Add(-idleBucketTTL)
+	for addr, b := range l.buckets {
+		if b.last.Before(cutoff) {
+			delete(l.buckets, addr)
+		}
+	}
+}
+
+func reject(w http.ResponseWriter, r *http.Request, reason string, retryAfter time.Duration, message string) {
+	metrics.RateLimited.WithLabelValues(reason).Inc()
+	logging.FromContext(r.Context()).Debug("Request rate limited", "reason", reason)
+
+	seconds := int64(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(max(seconds, 1), 10))
+	api.WriteError(w, http.StatusTooManyRequests, "%s", message)
+}
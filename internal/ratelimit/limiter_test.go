@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func request(remoteAddr string, forwardedFor ...string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/stats", nil)
+	req.RemoteAddr = remoteAddr
+	for _, value := range forwardedFor {
+		req.Header.Add("X-Forwarded-For", value)
+	}
+	return req
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parsePrefixes([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		expected string
+	}{
+		{"Direct client", request("203.0.113.7:5123"), "203.0.113.7"},
+		{"Untrusted proxy is ignored", request("203.0.113.7:5123", "198.51.100.1"), "203.0.113.7"},
+		{"Trusted proxy", request("10.1.2.3:80", "198.51.100.1"), "198.51.100.1"},
+		{"Spoofed hops are skipped", request("10.1.2.3:80", "1.2.3.4, 198.51.100.1"), "198.51.100.1"},
+		{"Chain of trusted proxies", request("10.1.2.3:80", "198.51.100.1, 192.168.1.1", "10.9.9.9"), "198.51.100.1"},
+		{"Trusted proxy without header", request("10.1.2.3:80"), "10.1.2.3"},
+		{"IPv6 client", request("10.1.2.3:80", "2001:db8::1"), "2001:db8::1"},
+		{"Invalid hop", request("10.1.2.3:80", "unknown"), "10.1.2.3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := clientIP(tc.req, trusted); got.String() != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+	}{
+		{"203.0.113.7", "203.0.113.7"},
+		{"2001:db8:1:2:aaaa::1", "2001:db8:1:2::"},
+		{"2001:db8:1:2:ffff:ffff:ffff:ffff", "2001:db8:1:2::"},
+		{"2001:db8:1:3::1", "2001:db8:1:3::"},
+	}
+
+	for _, tc := range tests {
+		if got := clientKey(netip.MustParseAddr(tc.addr)); got.String() != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.addr, tc.expected, got)
+		}
+	}
+}
+
+func TestForwardedByUntrusted(t *testing.T) {
+	trusted, err := parsePrefixes([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		expected bool
+	}{
+		{"Direct client", request("203.0.113.7:5123"), false},
+		{"Untrusted proxy", request("203.0.113.7:5123", "198.51.100.1"), true},
+		{"Trusted proxy", request("10.1.2.3:80", "198.51.100.1"), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := forwardedByUntrusted(tc.req, trusted); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestLimitStreams(t *testing.T) {
+	limiter := NewLimiter(Config{MaxStreams: 3, MaxStreamsPerIP: 2})
+
+	opened := make(chan struct{})
+	closeStreams := make(chan struct{})
+	handler := limiter.LimitStreams(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opened <- struct{}{}
+		<-closeStreams
+	}))
+
+	done := make(chan struct{})
+	open := func(remoteAddr string) {
+		go func() {
+			handler.ServeHTTP(httptest.NewRecorder(), request(remoteAddr))
+			done <- struct{}{}
+		}()
+		<-opened
+	}
+
+	open("203.0.113.7:1")
+	open("203.0.113.7:2")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, request("203.0.113.7:3"))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "10" {
+		t.Errorf("Expected per-IP cap to refuse a third stream, got %d (Retry-After %q)", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	open("198.51.100.1:1")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, request("198.51.100.2:1"))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected global cap to refuse a fourth stream, got %d", rec.Code)
+	}
+
+	close(closeStreams)
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if limiter.streams != 0 || len(limiter.streamsByAddr) != 0 {
+		t.Errorf("Expected every slot to be released, got %d streams %v", limiter.streams, limiter.streamsByAddr)
+	}
+}
+
+func TestLimitRequests(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewLimiter(Config{RequestsPerSecond: 2, Burst: 3, TrustedProxies: []string{"10.0.0.1"}})
+	limiter.now = func() time.Time { return now }
+	handler := limiter.LimitRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := serve(request("10.0.0.1:80", "203.0.113.7")); rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected the burst to be allowed, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := serve(request("10.0.0.1:80", "203.0.113.7"))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected 429 with Retry-After 1, got %d (%q)", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(rec.Body.String(), "rate limit") {
+		t.Errorf("Unexpected body %s", rec.Body)
+	}
+
+	// Other clients behind the same proxy have their own budget
+	if rec := serve(request("10.0.0.1:80", "198.51.100.1")); rec.Code != http.StatusOK {
+		t.Errorf("Expected another client to be allowed, got %d", rec.Code)
+	}
+
+	// IPv6 clients share a budget per /64
+	for i := 0; i < 3; i++ {
+		serve(request(fmt.Sprintf("[2001:db8::%x]:80", i+1)))
+	}
+	if rec := serve(request("[2001:db8::ffff]:80")); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected addresses in one /64 to share a budget, got %d", rec.Code)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if rec := serve(request("10.0.0.1:80", "203.0.113.7")); rec.Code != http.StatusOK {
+		t.Errorf("Expected a refilled token to be allowed, got %d", rec.Code)
+	}
+
+	now = now.Add(time.Hour)
+	limiter.sweep()
+	if _, ok := limiter.buckets[netip.MustParseAddr("203.0.113.7")]; ok {
+		t.Error("Expected idle buckets to be dropped")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Expected defaults to be valid, got %v", err)
+	}
+
+	config := DefaultConfig()
+	config.TrustedProxies = []string{"10.0.0.0/8", "proxy.internal"}
+	config.Burst = 0
+	err := config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "trusted_proxies") || !strings.Contains(err.Error(), "burst") {
+		t.Errorf("Expected trusted_proxies and burst errors, got %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// Compactor periodically downsamples raw price updates into candles and
+// enforces the retention policy on a store.
+type Compactor struct {
+	store    store.RetentionStore
+	policy   Policy
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewCompactor(store store.RetentionStore, policy Policy, interval time.Duration) *Compactor {
+	return &Compactor{
+		store:    store,
+		policy:   policy,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+func (c *Compactor) Start(ctx context.Context) {
+	go c.run(ctx)
+}
+
+func (c *Compactor) run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.Compact()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Compact()
+		case <-ctx.Done():
+			slog.Info("Stopping retention compactor")
+			return
+		}
+	}
+}
+
+// Compact builds any newly completed candles for every tier and then
+// deletes raw events and candles that fall outside their retention. Buckets
+// that received events out of order, e.g. from a backfill or import, are
+// rebuilt.
+func (c *Compactor) Compact() {
+	now := c.now().Unix()
+	lateFrom, late := c.store.TakeLateWrites()
+
+	// Tiers are processed finest first so each coarser tier can be built
+	// from the candles of the tier below it.
+	for i, tier := range c.policy.Tiers {
+		resolution := int64(tier.Resolution / time.Second)
+		from := c.nextBucket(resolution)
+		if late {
+			from = min(from, bucketStart(lateFrom, resolution))
+		}
+
+		var candles []domain.Candle
+		if i == 0 {
+			candles = c.candlesFromEvents(resolution, from, now)
+		} else {
+			source := int64(c.policy.Tiers[i-1].Resolution / time.Second)
+			candles = c.candlesFromCandles(source, resolution, from, now)
+		}
+
+		if len(candles) > 0 {
+			c.store.StoreCandles(candles)
+			slog.Info("Compacted candles", "count", len(candles), "resolution", tier.Resolution.String())
+		}
+	}
+
+	if c.policy.RawRetention > 0 {
+		cutoff := now - int64(c.policy.RawRetention/time.Second)
+		if deleted := c.store.DeleteEventsBefore(cutoff); deleted > 0 {
+			slog.Info("Expired raw events", "count", deleted)
+		}
+	}
+
+	for _, tier := range c.policy.Tiers {
+		if tier.Retention == 0 {
+			continue
+		}
+		resolution := int64(tier.Resolution / time.Second)
+		cutoff := now - int64(tier.Retention/time.Second)
+		if deleted := c.store.DeleteCandlesBefore(resolution, cutoff); deleted > 0 {
+			slog.Info("Expired candles", "count", deleted, "resolution", tier.Resolution.String())
+		}
+	}
+}
+
+// nextBucket returns the start of the first bucket that has not been compacted yet
+func (c *Compactor) nextBucket(resolution int64) int64 {
+	if latest, exists := c.store.GetLatestCandle(resolution); exists {
+		return latest.Timestamp + resolution
+	}
+	return 0
+}
+
+// candlesFromEvents builds the candles of the completed buckets from from
+// on, reading only the events in those buckets
+func (c *Compactor) candlesFromEvents(resolution int64, from int64, now int64) []domain.Candle {
+	until := bucketStart(now, resolution)
+	if from >= until {
+		return nil
+	}
+
+	var candles []domain.Candle
+	err := store.EachEvent(c.store, from, until-1, func(event domain.PriceUpdateEvent) error {
+		candles = mergeInto(candles, domain.Candle{
+			Timestamp:  bucketStart(event.Timestamp, resolution),
+			Resolution: resolution,
+			Open:       event.Price,
+			High:       event.Price,
+			Low:        event.Price,
+			Close:      event.Price,
+			Count:      1,
+		})
+		return nil
+	})
+	if err != nil {
+		// Partial candles would be taken as complete, so retry next time
+		slog.Error("Error reading events for candles", "resolution", resolution, "error", err)
+		return nil
+	}
+
+	return c.keepStoredCandles(candles, resolution, now)
+}
+
+// keepStoredCandles leaves out rebuilt candles for buckets that already have
+// a candle and whose raw events may have expired, since rebuilding them from
+// the remaining events would lose the expired ones
+func (c *Compactor) keepStoredCandles(candles []domain.Candle, resolution int64, now int64) []domain.Candle {
+	if c.policy.RawRetention == 0 || len(candles) == 0 {
+		return candles
+	}
+
+	cutoff := now - int64(c.policy.RawRetention/time.Second)
+	stored := make(map[int64]bool)
+	for _, candle := range c.store.GetCandlesSince(resolution, candles[0].Timestamp) {
+		if candle.Timestamp >= cutoff {
+			break
+		}
+		stored[candle.Timestamp] = true
+	}
+
+	kept := candles[:0]
+	for _, candle := range candles {
+		if !stored[candle.Timestamp] {
+			kept = append(kept, candle)
+		}
+	}
+	return kept
+}
+
+func (c *Compactor) candlesFromCandles(source int64, resolution int64, from int64, now int64) []domain.Candle {
+	until := bucketStart(now, resolution)
+
+	var candles []domain.Candle
+	for _, candle := range c.store.GetCandlesSince(source, from) {
+		if candle.Timestamp >= until {
+			break
+		}
+		candle.Timestamp = bucketStart(candle.Timestamp, resolution)
+		candle.Resolution = resolution
+		candles = mergeInto(candles, candle)
+	}
+
+	return candles
+}
+
+// mergeInto folds next into the last candle when they share a bucket,
+// otherwise appends it. Input must be in timestamp order.
+func mergeInto(candles []domain.Candle, next domain.Candle) []domain.Candle {
+	if len(candles) == 0 || candles[len(candles)-1].Timestamp != next.Timestamp {
+		return append(candles, next)
+	}
+
+	last := &candles[len(candles)-1]
+	if next.High.Cmp(last.High) > 0 {
+		last.High = next.High
+	}
+	if next.Low.Cmp(last.Low) < 0 {
+		last.Low = next.Low
+	}
+	last.Close = next.Close
+	last.Count += next.Count
+	return candles
+}
+
+func bucketStart(timestamp int64, resolution int64) int64 {
+	return timestamp - timestamp%resolution
+}
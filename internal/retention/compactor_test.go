@@ -0,0 +1,152 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+func newTestCompactor(memStore *store.MemoryStore, now int64) *Compactor {
+	policy := Policy{
+		RawRetention: 5 * time.Minute,
+		Tiers: []Tier{
+			{Resolution: time.Minute, Retention: time.Hour},
+			{Resolution: 5 * time.Minute},
+		},
+	}
+	compactor := NewCompactor(memStore, policy, time.Minute)
+	compactor.now = func() time.Time { return time.Unix(now, 0) }
+	return compactor
+}
+
+func TestCompactor_BuildsCandles(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+
+	// Two ticks per minute for ten minutes, starting at t=0
+	for minute := int64(0); minute < 10; minute++ {
+		memStore.Store(domain.PriceUpdateEvent{Timestamp: minute * 60, Price: domain.NewPrice(float64(100 + minute))})
+		memStore.Store(domain.PriceUpdateEvent{Timestamp: minute*60 + 30, Price: domain.NewPrice(float64(200 + minute))})
+	}
+
+	// At 10:15 only minutes 0..9 are complete
+	newTestCompactor(memStore, 615).Compact()
+
+	minuteCandles := memStore.GetCandlesSince(60, 0)
+	if len(minuteCandles) != 10 {
+		t.Fatalf("Expected 10 minute candles, got %d", len(minuteCandles))
+	}
+
+	first := minuteCandles[0]
+	if first.Open.String() != "100" || first.Close.String() != "200" || first.High.String() != "200" || first.Low.String() != "100" || first.Count != 2 {
+		t.Errorf("Unexpected first minute candle: %+v", first)
+	}
+
+	fiveMinuteCandles := memStore.GetCandlesSince(300, 0)
+	if len(fiveMinuteCandles) != 2 {
+		t.Fatalf("Expected 2 five-minute candles, got %d", len(fiveMinuteCandles))
+	}
+
+	second := fiveMinuteCandles[1]
+	if second.Timestamp != 300 || second.Open.String() != "105" || second.Close.String() != "209" || second.Low.String() != "105" || second.High.String() != "209" || second.Count != 10 {
+		t.Errorf("Unexpected second five-minute candle: %+v", second)
+	}
+
+	// Raw events older than five minutes are expired
+	if events := memStore.GetEventsSince(0); events[0].Timestamp != 330 {
+		t.Errorf("Expected oldest remaining event at 330, got %d", events[0].Timestamp)
+	}
+}
+
+func TestCompactor_IsIncremental(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 0, Price: domain.NewPrice(100)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 60, Price: domain.NewPrice(110)})
+
+	newTestCompactor(memStore, 90).Compact()
+	if candles := memStore.GetCandlesSince(60, 0); len(candles) != 1 {
+		t.Fatalf("Expected 1 minute candle, got %d", len(candles))
+	}
+
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 120, Price: domain.NewPrice(120)})
+	newTestCompactor(memStore, 150).Compact()
+
+	candles := memStore.GetCandlesSince(60, 0)
+	if len(candles) != 2 {
+		t.Fatalf("Expected 2 minute candles, got %d", len(candles))
+	}
+	if candles[0].Count != 1 || candles[1].Open.String() != "110" {
+		t.Errorf("Expected candles not to be double counted, got %+v", candles)
+	}
+}
+
+func TestCompactor_ExpiresCandles(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	memStore.StoreCandles([]domain.Candle{
+		{Timestamp: 0, Resolution: 60, Open: domain.NewPrice(1), High: domain.NewPrice(1), Low: domain.NewPrice(1), Close: domain.NewPrice(1), Count: 1},
+		{Timestamp: 3600, Resolution: 60, Open: domain.NewPrice(2), High: domain.NewPrice(2), Low: domain.NewPrice(2), Close: domain.NewPrice(2), Count: 1},
+	})
+
+	// One hour retention on minute candles; five-minute candles are kept forever
+	newTestCompactor(memStore, 3700).Compact()
+
+	candles := memStore.GetCandlesSince(60, 0)
+	if len(candles) != 1 || candles[0].Timestamp != 3600 {
+		t.Errorf("Expected only the recent minute candle to remain, got %+v", candles)
+	}
+	if fiveMinute := memStore.GetCandlesSince(300, 0); len(fiveMinute) == 0 {
+		t.Error("Expected five-minute candles to be built before minute candles expired")
+	}
+}
+
+func TestCompactor_RebuildsLateWrites(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 0, Price: domain.NewPrice(100)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 300, Price: domain.NewPrice(130)})
+	newTestCompactor(memStore, 330).Compact()
+
+	// A backfill fills minutes 1 and 2 and adds a tick to the compacted minute 5
+	memStore.InsertEvents([]domain.PriceUpdateEvent{
+		{Timestamp: 60, Price: domain.NewPrice(110), Backfilled: true},
+		{Timestamp: 120, Price: domain.NewPrice(120), Backfilled: true},
+		{Timestamp: 310, Price: domain.NewPrice(90), Backfilled: true},
+	})
+	newTestCompactor(memStore, 390).Compact()
+
+	candles := memStore.GetCandlesSince(60, 0)
+	if len(candles) != 4 {
+		t.Fatalf("Expected candles for minutes 0, 1, 2 and 5, got %+v", candles)
+	}
+	if candles[1].Timestamp != 60 || candles[2].Timestamp != 120 {
+		t.Errorf("Expected the backfilled minutes to be compacted, got %+v", candles)
+	}
+	if latest := candles[3]; latest.Count != 2 || latest.Low.String() != "90" {
+		t.Errorf("Expected minute 5 to be rebuilt with the late tick, got %+v", latest)
+	}
+
+	fiveMinute := memStore.GetCandlesSince(300, 0)
+	if len(fiveMinute) != 1 || fiveMinute[0].Count != 3 || fiveMinute[0].High.String() != "120" {
+		t.Errorf("Expected the five-minute candle to be rebuilt, got %+v", fiveMinute)
+	}
+
+	if _, late := memStore.TakeLateWrites(); late {
+		t.Error("Expected the late writes to be taken by the compactor")
+	}
+}
+
+func TestCompactor_KeepsCandlesOfExpiredEvents(t *testing.T) {
+	memStore := store.NewMemoryStore(100)
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 0, Price: domain.NewPrice(100)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 30, Price: domain.NewPrice(200)})
+	// Raw events of minute 0 expire at 10:00
+	newTestCompactor(memStore, 600).Compact()
+
+	memStore.InsertEvents([]domain.PriceUpdateEvent{{Timestamp: 40, Price: domain.NewPrice(150), Backfilled: true}})
+	newTestCompactor(memStore, 610).Compact()
+
+	candles := memStore.GetCandlesSince(60, 0)
+	if len(candles) == 0 || candles[0].Count != 2 || candles[0].High.String() != "200" {
+		t.Errorf("Expected the minute 0 candle to be kept, got %+v", candles)
+	}
+}
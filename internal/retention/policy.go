@@ -0,0 +1,123 @@
+package retention
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tier keeps candles of a given resolution for a given duration.
+// A zero Retention keeps the candles forever.
+type Tier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// Policy describes how long raw ticks are kept and how they are
+// downsampled into progressively coarser candles.
+type Policy struct {
+	// RawRetention is how long raw price updates are kept. Zero keeps them forever.
+	RawRetention time.Duration
+	// Tiers are ordered from finest to coarsest resolution.
+	Tiers []Tier
+}
+
+// ParsePolicy parses a policy of the form "raw:24h,1m:720h,1h:forever".
+// Each entry is <resolution>:<retention>, where the special resolution
+// "raw" refers to unaggregated ticks and "forever" (or "0") disables expiry.
+func ParsePolicy(spec string) (Policy, error) {
+	var policy Policy
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, retentionStr, ok := strings.Cut(entry, ":")
+		if !ok {
+			return Policy{}, fmt.Errorf("invalid retention entry %q: expected <resolution>:<retention>", entry)
+		}
+
+		retention, err := parseRetention(retentionStr)
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid retention entry %q: %w", entry, err)
+		}
+
+		if name == "raw" {
+			policy.RawRetention = retention
+			continue
+		}
+
+		resolution, err := time.ParseDuration(name)
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid retention entry %q: %w", entry, err)
+		}
+		policy.Tiers = append(policy.Tiers, Tier{Resolution: resolution, Retention: retention})
+	}
+
+	sort.Slice(policy.Tiers, func(i, j int) bool {
+		return policy.Tiers[i].Resolution < policy.Tiers[j].Resolution
+	})
+
+	return policy, policy.Validate()
+}
+
+func parseRetention(s string) (time.Duration, error) {
+	if s == "forever" || s == "0" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Validate checks that tiers are whole seconds, each a multiple of the
+// previous one, so coarser candles can be built from finer ones.
+func (p Policy) Validate() error {
+	if p.RawRetention < 0 {
+		return errors.New("raw retention must not be negative")
+	}
+
+	for i, tier := range p.Tiers {
+		if tier.Resolution < time.Second || tier.Resolution%time.Second != 0 {
+			return fmt.Errorf("resolution %v must be a whole number of seconds", tier.Resolution)
+		}
+		if tier.Retention < 0 {
+			return fmt.Errorf("retention for %v must not be negative", tier.Resolution)
+		}
+		if i > 0 {
+			prev := p.Tiers[i-1].Resolution
+			if tier.Resolution == prev || tier.Resolution%prev != 0 {
+				return fmt.Errorf("resolution %v must be a multiple of %v", tier.Resolution, prev)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Resolutions returns the candle resolutions of the tiers, finest first
+func (p Policy) Resolutions() []time.Duration {
+	resolutions := make([]time.Duration, len(p.Tiers))
+	for i, tier := range p.Tiers {
+		resolutions[i] = tier.Resolution
+	}
+	return resolutions
+}
+
+// String formats the policy in the same form accepted by ParsePolicy
+func (p Policy) String() string {
+	entries := []string{"raw:" + formatRetention(p.RawRetention)}
+	for _, tier := range p.Tiers {
+		entries = append(entries, tier.Resolution.String()+":"+formatRetention(tier.Retention))
+	}
+	return strings.Join(entries, ",")
+}
+
+func formatRetention(d time.Duration) string {
+	if d == 0 {
+		return "forever"
+	}
+	return d.String()
+}
@@ -0,0 +1,50 @@
+package retention
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("1h:forever, raw:24h, 1m:720h")
+	if err != nil {
+		t.Fatalf("ParsePolicy failed: %v", err)
+	}
+
+	if policy.RawRetention != 24*time.Hour {
+		t.Errorf("Expected raw retention 24h, got %v", policy.RawRetention)
+	}
+	if len(policy.Tiers) != 2 {
+		t.Fatalf("Expected 2 tiers, got %d", len(policy.Tiers))
+	}
+	if policy.Tiers[0].Resolution != time.Minute || policy.Tiers[0].Retention != 720*time.Hour {
+		t.Errorf("Unexpected first tier: %+v", policy.Tiers[0])
+	}
+	if policy.Tiers[1].Resolution != time.Hour || policy.Tiers[1].Retention != 0 {
+		t.Errorf("Unexpected second tier: %+v", policy.Tiers[1])
+	}
+	if policy.String() != "raw:24h0m0s,1m0s:720h0m0s,1h0m0s:forever" {
+		t.Errorf("Unexpected string form: %s", policy.String())
+	}
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"Missing retention", "raw"},
+		{"Bad duration", "raw:soon"},
+		{"Sub-second resolution", "500ms:1h"},
+		{"Not a multiple", "1m:1h,90s:1h"},
+		{"Negative retention", "1m:-1h"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParsePolicy(tc.spec); err == nil {
+				t.Errorf("Expected error for %q", tc.spec)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// AnyOrigin allows cross-origin requests from every origin
+const AnyOrigin = "*"
+
+// Config controls the CORS and security headers sent with every response
+type Config struct {
+	CORS CORSConfig `yaml:"cors"`
+	// ContentSecurityPolicy is sent as Content-Security-Policy; empty
+	// disables the header
+	ContentSecurityPolicy string `yaml:"content_security_policy"`
+	// HSTSMaxAge enables Strict-Transport-Security when positive. Only
+	// enable it when the service is reached over HTTPS.
+	HSTSMaxAge            time.Duration `yaml:"hsts_max_age"`
+	HSTSIncludeSubdomains bool          `yaml:"hsts_include_subdomains"`
+	// ContentTypeOptions sends X-Content-Type-Options: nosniff
+	ContentTypeOptions bool `yaml:"content_type_options"`
+}
+
+// CORSConfig controls which browser origins may call the API and the stream.
+// Origins are given as e.g. https://example.com, or "*" for any origin.
+type CORSConfig struct {
+	// AllowedOrigins may make every request, including those that change
+	// alert rules, webhooks or keys
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	// ReadOrigins may only make GET and HEAD requests, such as opening the
+	// stream or reading history
+	ReadOrigins      []string      `yaml:"read_origins"`
+	AllowCredentials bool          `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// DefaultConfig keeps the stream and other reads open to every origin, as
+// before CORS was configurable, while requests that change data need an
+// explicitly allowed origin. The content security policy fits the bundled
+// web UI.
+func DefaultConfig() Config {
+	return Config{
+		CORS: CORSConfig{
+			ReadOrigins: []string{AnyOrigin},
+			MaxAge:      10 * time.Minute,
+		},
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; frame-ancestors 'none'",
+		ContentTypeOptions:    true,
+	}
+}
+
+// Validate checks the CORS and security header settings
+func (c Config) Validate() error {
+	var errs []error
+
+	for key, origins := range map[string][]string{"allowed_origins": c.CORS.AllowedOrigins, "read_origins": c.CORS.ReadOrigins} {
+		for _, origin := range origins {
+			if origin == AnyOrigin {
+				continue
+			}
+			if err := validateOrigin(origin); err != nil {
+				errs = append(errs, fmt.Errorf("security.cors.%s: %w", key, err))
+			}
+		}
+	}
+	if c.CORS.AllowCredentials && (slices.Contains(c.CORS.AllowedOrigins, AnyOrigin) || slices.Contains(c.CORS.ReadOrigins, AnyOrigin)) {
+		// Browsers refuse credentialed responses for a wildcard origin
+		errs = append(errs, errors.New("security.cors.allow_credentials requires explicit allowed_origins and read_origins, not \"*\""))
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("security.cors.max_age must not be negative, got %v", c.CORS.MaxAge))
+	}
+	if c.HSTSMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("security.hsts_max_age must not be negative, got %v", c.HSTSMaxAge))
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateOrigin accepts a bare scheme, host and optional port
+func validateOrigin(origin string) error {
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("origin %q must be an http or https URL", origin)
+	}
+	if parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+		return fmt.Errorf("origin %q must only have a scheme, host and port", origin)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Package security sets CORS and browser security headers on every HTTP
+// response.
+package security
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Methods and headers that cross-origin requests may use. They cover the
+// REST API, API key authentication and resuming streams.
+const (
+	allowedMethods = "GET, HEAD, POST, PUT, DELETE"
+	readMethods    = "GET, HEAD"
+	allowedHeaders = "Authorization, Content-Type, Last-Event-ID, X-API-Key, X-Request-ID"
+	exposedHeaders = "Retry-After, X-Request-ID"
+)
+
+// Headers sets the configured CORS and security headers before passing
+// requests to next. CORS preflight requests are answered directly so they
+// never reach authentication.
+func Headers(config Config, next http.Handler) http.Handler {
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(config.HSTSMaxAge.Seconds()), 10)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		if config.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", config.ContentSecurityPolicy)
+		}
+		if hsts != "" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		if config.ContentTypeOptions {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header.Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		method := r.Method
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			method = r.Header.Get("Access-Control-Request-Method")
+		}
+		allowed, value, methods := config.CORS.allow(origin, method)
+		if allowed {
+			header.Set("Access-Control-Allow-Origin", value)
+			if config.CORS.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if preflight {
+			if allowed {
+				header.Set("Access-Control-Allow-Methods", methods)
+				header.Set("Access-Control-Allow-Headers", allowedHeaders)
+				if config.CORS.MaxAge > 0 {
+					header.Set("Access-Control-Max-Age", strconv.FormatInt(int64(config.CORS.MaxAge.Seconds()), 10))
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			header.Set("Access-Control-Expose-Headers", exposedHeaders)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow reports whether origin may make a cross-origin request with method,
+// the value for Access-Control-Allow-Origin and the methods it may use
+func (c CORSConfig) allow(origin string, method string) (bool, string, string) {
+	if value, ok := matchOrigin(c.AllowedOrigins, origin); ok {
+		return true, value, allowedMethods
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		if value, ok := matchOrigin(c.ReadOrigins, origin); ok {
+			return true, value, readMethods
+		}
+	}
+	return false, "", ""
+}
+
+// matchOrigin returns the Access-Control-Allow-Origin value when origin is
+// in origins
+func matchOrigin(origins []string, origin string) (string, bool) {
+	for _, allowed := range origins {
+		if allowed == AnyOrigin {
+			return AnyOrigin, true
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+	return "", false
+}
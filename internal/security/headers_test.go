@@ -0,0 +1,162 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serve(config Config, method string, header http.Header) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(method, "/api/v1/stats", nil)
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	Headers(config, next).ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHeaders_SecurityHeaders(t *testing.T) {
+	config := DefaultConfig()
+	config.HSTSMaxAge = 365 * 24 * time.Hour
+	config.HSTSIncludeSubdomains = true
+
+	rec := serve(config, http.MethodGet, nil)
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("Expected request to reach the handler, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=31536000; includeSubDomains" {
+		t.Errorf("Unexpected Strict-Transport-Security %q", got)
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("Expected X-Content-Type-Options: nosniff")
+	}
+	if !strings.Contains(rec.Header().Get("Content-Security-Policy"), "frame-ancestors 'none'") {
+		t.Errorf("Unexpected Content-Security-Policy %q", rec.Header().Get("Content-Security-Policy"))
+	}
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("Expected no CORS headers without an Origin")
+	}
+
+	rec = serve(Config{}, http.MethodGet, nil)
+	for _, name := range []string{"Strict-Transport-Security", "X-Content-Type-Options", "Content-Security-Policy"} {
+		if rec.Header().Get(name) != "" {
+			t.Errorf("Expected %s to be disabled", name)
+		}
+	}
+}
+
+func TestHeaders_CORS(t *testing.T) {
+	config := DefaultConfig()
+	config.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	config.CORS.ReadOrigins = nil
+	config.CORS.AllowCredentials = true
+
+	tests := []struct {
+		name        string
+		config      Config
+		origin      string
+		allowOrigin string
+		credentials string
+	}{
+		{"Wildcard", DefaultConfig(), "https://any.example.org", "*", ""},
+		{"Listed origin", config, "https://APP.example.com", "https://APP.example.com", "true"},
+		{"Other origin", config, "https://evil.example.net", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serve(tc.config, http.MethodGet, http.Header{"Origin": {tc.origin}})
+			if rec.Code != http.StatusTeapot {
+				t.Fatalf("Expected request to reach the handler, got %d", rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tc.allowOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tc.allowOrigin, got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tc.credentials {
+				t.Errorf("Expected Access-Control-Allow-Credentials %q, got %q", tc.credentials, got)
+			}
+			if rec.Header().Get("Vary") != "Origin" {
+				t.Error("Expected Vary: Origin")
+			}
+		})
+	}
+}
+
+func TestHeaders_Preflight(t *testing.T) {
+	config := DefaultConfig()
+	config.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	preflight := http.Header{
+		"Origin":                         {"https://app.example.com"},
+		"Access-Control-Request-Method":  {"POST"},
+		"Access-Control-Request-Headers": {"authorization"},
+	}
+
+	rec := serve(config, http.MethodOptions, preflight)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected preflight to be answered with 204, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Header().Get("Access-Control-Allow-Methods"), "POST") ||
+		!strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("Unexpected preflight headers: %v", rec.Header())
+	}
+	if rec.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Expected max age of 600s, got %q", rec.Header().Get("Access-Control-Max-Age"))
+	}
+
+	// Other origins may only read, as the default wildcard applies to GET
+	preflight.Set("Origin", "https://evil.example.net")
+	rec = serve(config, http.MethodOptions, preflight)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("Expected preflight from another origin to get no CORS headers, got %d %v", rec.Code, rec.Header())
+	}
+	preflight.Set("Access-Control-Request-Method", "GET")
+	rec = serve(config, http.MethodOptions, preflight)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Methods") != "GET, HEAD" {
+		t.Errorf("Expected a read-only preflight for another origin, got %v", rec.Header())
+	}
+}
+
+func TestHeaders_DefaultsOnlyAllowReads(t *testing.T) {
+	origin := http.Header{"Origin": {"https://any.example.org"}}
+
+	if rec := serve(DefaultConfig(), http.MethodGet, origin); rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected GET to be open to every origin, got %v", rec.Header())
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		if rec := serve(DefaultConfig(), method, origin); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected no CORS headers for %s by default, got %v", method, rec.Header())
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Expected defaults to be valid, got %v", err)
+	}
+
+	tests := map[string]func(c *Config){
+		"allowed_origins": func(c *Config) { c.CORS.AllowedOrigins = []string{"https://example.com/app"} },
+		"read_origins":    func(c *Config) { c.CORS.ReadOrigins = []string{"example.com"} },
+		"allow_credentials": func(c *Config) {
+			c.CORS.AllowCredentials = true
+		},
+		"hsts_max_age": func(c *Config) { c.HSTSMaxAge = -time.Second },
+	}
+
+	for fragment, mutate := range tests {
+		config := DefaultConfig()
+		mutate(&config)
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), fragment) {
+			t.Errorf("Expected error mentioning %s, got %v", fragment, err)
+		}
+	}
+}
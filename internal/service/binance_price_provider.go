@@ -3,52 +3,159 @@ package service
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
-	"strconv"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+)
+
+const (
+	binanceBaseURL     = "https://api.binance.com"
+	binanceKlinesLimit = 1000
 )
 
-type BinancePriceProvider struct{}
+type BinancePriceProvider struct {
+	baseURL string
+}
 
 func NewBinancePriceProvider() *BinancePriceProvider {
-	return &BinancePriceProvider{}
+	return &BinancePriceProvider{baseURL: binanceBaseURL}
 }
 
 // FetchPrice retrieves the current Bitcoin price in USD
-func (p *BinancePriceProvider) FetchPrice() (float64, error) {
+func (p *BinancePriceProvider) FetchPrice() (price domain.Price, err error) {
+	defer func(start time.Time) { metrics.ObserveFetch("binance", start, err) }(time.Now())
+
 	// Use Binance API
-	response, err := http.Get("https://api.binance.com/api/v3/ticker/price?symbol=BTCUSDT")
+	response, err := http.Get(p.baseURL + "/api/v3/ticker/price?symbol=BTCUSDT")
 	if err != nil {
-		return 0, err
+		return domain.Price{}, err
 	}
 	defer response.Body.Close()
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		return 0, err
+		return domain.Price{}, err
 	}
 
 	// Parse the JSON response
 	var result binancePriceResult
 	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
+		return domain.Price{}, err
 	}
 
 	// Check if we got data
 	if result.Price == "" {
-		return 0, errors.New("no price data available")
+		return domain.Price{}, errors.New("no price data available")
 	}
 
-	// Convert string price to float64
-	price, err := strconv.ParseFloat(result.Price, 64)
+	// Binance quotes prices as strings, which are kept exact
+	return domain.ParsePrice(result.Price)
+}
+
+// FetchHistory retrieves one-minute closing prices between from and to
+// using the Binance klines API, paging through the 1000 candle limit
+func (p *BinancePriceProvider) FetchHistory(from, to time.Time) ([]domain.PriceUpdateEvent, error) {
+	var events []domain.PriceUpdateEvent
+
+	start := from.UnixMilli()
+	end := to.UnixMilli()
+
+	for start < end {
+		url := fmt.Sprintf("%s/api/v3/klines?symbol=BTCUSDT&interval=1m&startTime=%d&endTime=%d&limit=%d",
+			p.baseURL, start, end, binanceKlinesLimit)
+
+		klines, err := p.fetchKlines(url)
+		if err != nil {
+			return nil, err
+		}
+		if len(klines) == 0 {
+			break
+		}
+
+		for _, kline := range klines {
+			event, err := kline.toEvent()
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, event)
+		}
+
+		lastOpen, _ := klines[len(klines)-1].openTime()
+		start = lastOpen + time.Minute.Milliseconds()
+
+		if len(klines) < binanceKlinesLimit {
+			break
+		}
+	}
+
+	return events, nil
+}
+
+func (p *BinancePriceProvider) fetchKlines(url string) ([]binanceKline, error) {
+	response, err := http.Get(url) // #nosec G107 -- URL is built from the configured base URL
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	defer response.Body.Close()
 
-	return price, nil
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("binance klines request failed: %s: %s", response.Status, body)
+	}
+
+	var klines []binanceKline
+	if err := json.Unmarshal(body, &klines); err != nil {
+		return nil, err
+	}
+
+	return klines, nil
 }
 
 type binancePriceResult struct {
 	Symbol string `json:"symbol"`
 	Price  string `json:"price"`
 }
+
+// binanceKline is a single kline row: [openTime, open, high, low, close, ...]
+type binanceKline []json.RawMessage
+
+func (k binanceKline) openTime() (int64, error) {
+	if len(k) < 5 {
+		return 0, errors.New("malformed kline")
+	}
+
+	var openTime int64
+	err := json.Unmarshal(k[0], &openTime)
+	return openTime, err
+}
+
+func (k binanceKline) toEvent() (domain.PriceUpdateEvent, error) {
+	openTime, err := k.openTime()
+	if err != nil {
+		return domain.PriceUpdateEvent{}, err
+	}
+
+	var closeStr string
+	if err := json.Unmarshal(k[4], &closeStr); err != nil {
+		return domain.PriceUpdateEvent{}, err
+	}
+
+	price, err := domain.ParsePrice(closeStr)
+	if err != nil {
+		return domain.PriceUpdateEvent{}, err
+	}
+
+	return domain.PriceUpdateEvent{
+		Timestamp:  openTime / 1000,
+		Price:      price,
+		Backfilled: true,
+	}, nil
+}
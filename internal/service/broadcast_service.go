@@ -1,22 +1,55 @@
 package service
 
 import (
-	"btc-price-tracker/internal/domain"
-	"btc-price-tracker/internal/store"
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/indicator"
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+	"github.com/tstojkovski/btc-price-tracker/internal/tracing"
 )
 
+// clientBufferSize is how many price events a stream may fall behind
+// before events are dropped for it
+const clientBufferSize = 10
+
 type BroadcastService struct {
 	store      store.EventStore
 	clients    map[chan domain.PriceUpdateEvent]bool
 	mutex      sync.RWMutex
 	updateChan <-chan domain.PriceUpdateEvent
+	running    atomic.Bool
+
+	// alertClients maps each stream's alert channel to its user
+	alertClients map[chan domain.Alert]string
+	alertChan    <-chan domain.Alert
+
+	// statsClients holds the streams that asked for statistics
+	statsClients map[chan domain.PriceStats]bool
+	statsChan    <-chan domain.PriceStats
+
+	heartbeatClients map[chan domain.Heartbeat]bool
+	heartbeatChan    <-chan domain.Heartbeat
+
+	// indicators serves streams that ask for indicators; nil disables them
+	indicators *indicator.Service
+	// liveIndicators shares warmed indicators between streams
+	liveIndicators *indicator.Live
+
+	// priceFormat encodes prices in stream events
+	priceFormat api.PriceFormat
 }
 
 func NewBroadcastService(store store.EventStore, updateChan <-chan domain.PriceUpdateEvent) *BroadcastService {
@@ -24,45 +57,163 @@ func NewBroadcastService(store store.EventStore, updateChan <-chan domain.PriceU
 		store:      store,
 		clients:    make(map[chan domain.PriceUpdateEvent]bool),
 		updateChan: updateChan,
+
+		alertClients: make(map[chan domain.Alert]string),
+		statsClients: make(map[chan domain.PriceStats]bool),
+
+		heartbeatClients: make(map[chan domain.Heartbeat]bool),
 	}
 }
 
+// BroadcastAlerts forwards fired alerts to stream clients as named "alert"
+// events. It must be called before Start.
+func (bs *BroadcastService) BroadcastAlerts(alerts <-chan domain.Alert) {
+	bs.alertChan = alerts
+}
+
+// BroadcastStats forwards rolling statistics as named "stats" events to
+// streams opened with stats=true. It must be called before Start.
+func (bs *BroadcastService) BroadcastStats(stats <-chan domain.PriceStats) {
+	bs.statsChan = stats
+}
+
+// BroadcastHeartbeats forwards heartbeats for suppressed unchanged prices to
+// every stream as named "heartbeat" events. It must be called before Start.
+func (bs *BroadcastService) BroadcastHeartbeats(heartbeats <-chan domain.Heartbeat) {
+	bs.heartbeatChan = heartbeats
+}
+
+// SetPriceFormat selects how stream events encode prices. It must be called
+// before Start.
+func (bs *BroadcastService) SetPriceFormat(format api.PriceFormat) {
+	bs.priceFormat = format
+}
+
+// StreamIndicators lets stream clients request indicator events with the
+// indicators query parameter. It must be called before Start.
+func (bs *BroadcastService) StreamIndicators(indicators *indicator.Service) {
+	bs.indicators = indicators
+	bs.liveIndicators = indicators.NewLive(clientBufferSize)
+}
+
 func (bs *BroadcastService) Start(ctx context.Context) {
 	go bs.broadcastUpdates(ctx)
 }
 
 func (bs *BroadcastService) broadcastUpdates(ctx context.Context) {
+	bs.running.Store(true)
+	defer bs.running.Store(false)
+
 	for {
 		select {
 		case update := <-bs.updateChan:
 			bs.broadcastToAllClients(update)
+		case alert := <-bs.alertChan:
+			bs.broadcastAlert(alert)
+		case stats := <-bs.statsChan:
+			bs.broadcastStats(stats)
+		case heartbeat := <-bs.heartbeatChan:
+			bs.broadcastHeartbeat(heartbeat)
 		case <-ctx.Done():
-			log.Println("Stopping broadcast service")
+			slog.Info("Stopping broadcast service")
 			return
 		}
 	}
 }
 
 func (bs *BroadcastService) broadcastToAllClients(update domain.PriceUpdateEvent) {
+	_, span := tracing.Start(tracing.Extract(update.TraceParent), "broadcast")
+	defer span.End()
+
+	// Indicators are updated first so streams find their values
+	if bs.liveIndicators != nil {
+		bs.liveIndicators.Update(update)
+	}
+
 	bs.mutex.RLock()
 	defer bs.mutex.RUnlock()
 
+	dropped := 0
 	for client := range bs.clients {
 		select {
 		case client <- update:
 			// Successfully sent update
 		default:
 			// Client channel buffer full, skip this client
+			metrics.BroadcastDropped.Inc()
+			dropped++
+		}
+	}
+
+	span.SetAttributes(
+		attribute.Int("clients", len(bs.clients)),
+		attribute.Int("dropped", dropped),
+	)
+}
+
+// broadcastAlert sends an alert to the streams of the rule's owner, or to
+// every stream for rules without an owner
+func (bs *BroadcastService) broadcastAlert(alert domain.Alert) {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+
+	for client, user := range bs.alertClients {
+		if alert.Owner != "" && alert.Owner != user {
+			continue
+		}
+		select {
+		case client <- alert:
+		default:
+			metrics.BroadcastDropped.Inc()
+		}
+	}
+}
+
+func (bs *BroadcastService) broadcastStats(stats domain.PriceStats) {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+
+	for client := range bs.statsClients {
+		select {
+		case client <- stats:
+		default:
+			metrics.BroadcastDropped.Inc()
+		}
+	}
+}
+
+func (bs *BroadcastService) broadcastHeartbeat(heartbeat domain.Heartbeat) {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+
+	for client := range bs.heartbeatClients {
+		select {
+		case client <- heartbeat:
+		default:
+			// A stream that is behind on heartbeats is still receiving data
 		}
 	}
 }
 
+// IsRunning reports whether the broadcast loop is active
+func (bs *BroadcastService) IsRunning() bool {
+	return bs.running.Load()
+}
+
+// ClientCount returns the number of subscribed clients
+func (bs *BroadcastService) ClientCount() int {
+	bs.mutex.RLock()
+	defer bs.mutex.RUnlock()
+	return len(bs.clients)
+}
+
 func (bs *BroadcastService) SubscribeClient() chan domain.PriceUpdateEvent {
-	clientChan := make(chan domain.PriceUpdateEvent, 10) // Buffer to handle some backpressure
+	clientChan := make(chan domain.PriceUpdateEvent, clientBufferSize)
 
 	bs.mutex.Lock()
 	bs.clients[clientChan] = true
 	bs.mutex.Unlock()
+	metrics.SSEClients.Inc()
 
 	return clientChan
 }
@@ -72,13 +223,87 @@ func (bs *BroadcastService) UnsubscribeClient(clientChan chan domain.PriceUpdate
 	delete(bs.clients, clientChan)
 	close(clientChan)
 	bs.mutex.Unlock()
+	metrics.SSEClients.Dec()
+}
+
+// SubscribeAlerts registers a stream of user for alert events
+func (bs *BroadcastService) SubscribeAlerts(user string) chan domain.Alert {
+	alertChan := make(chan domain.Alert, 10)
+
+	bs.mutex.Lock()
+	bs.alertClients[alertChan] = user
+	bs.mutex.Unlock()
+
+	return alertChan
+}
+
+func (bs *BroadcastService) UnsubscribeAlerts(alertChan chan domain.Alert) {
+	bs.mutex.Lock()
+	delete(bs.alertClients, alertChan)
+	close(alertChan)
+	bs.mutex.Unlock()
+}
+
+// SubscribeStats registers a stream for statistics events
+func (bs *BroadcastService) SubscribeStats() chan domain.PriceStats {
+	statsChan := make(chan domain.PriceStats, 10)
+
+	bs.mutex.Lock()
+	bs.statsClients[statsChan] = true
+	bs.mutex.Unlock()
+
+	return statsChan
+}
+
+func (bs *BroadcastService) UnsubscribeStats(statsChan chan domain.PriceStats) {
+	bs.mutex.Lock()
+	delete(bs.statsClients, statsChan)
+	close(statsChan)
+	bs.mutex.Unlock()
+}
+
+// SubscribeHeartbeats registers a stream for heartbeat events
+func (bs *BroadcastService) SubscribeHeartbeats() chan domain.Heartbeat {
+	heartbeatChan := make(chan domain.Heartbeat, 1)
+
+	bs.mutex.Lock()
+	bs.heartbeatClients[heartbeatChan] = true
+	bs.mutex.Unlock()
+
+	return heartbeatChan
+}
+
+func (bs *BroadcastService) UnsubscribeHeartbeats(heartbeatChan chan domain.Heartbeat) {
+	bs.mutex.Lock()
+	delete(bs.heartbeatClients, heartbeatChan)
+	close(heartbeatChan)
+	bs.mutex.Unlock()
 }
 
 func (bs *BroadcastService) SSEHandler(w http.ResponseWriter, r *http.Request) {
+	wantStats, _ := strconv.ParseBool(r.URL.Query().Get("stats"))
+
+	var specs []indicator.Spec
+	if value := r.URL.Query().Get("indicators"); value != "" {
+		if bs.indicators == nil {
+			http.Error(w, "Indicators unsupported", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if specs, err = bs.indicators.ParseSpecs(value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.ProtoMajor == 1 {
+		// Connection-specific headers are not allowed over HTTP/2
+		w.Header().Set("Connection", "keep-alive")
+	}
+
+	logger := logging.FromContext(r.Context())
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
@@ -89,6 +314,12 @@ func (bs *BroadcastService) SSEHandler(w http.ResponseWriter, r *http.Request) {
 	sinceStr := r.URL.Query().Get("since")
 	var lastTimestamp int64 = 0
 
+	// A reconnecting client resumes after the last event it received, which
+	// takes precedence over the since parameter of the original URL
+	if lastEventID, err := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		sinceStr = strconv.FormatInt(lastEventID+1, 10)
+	}
+
 	if sinceStr != "" {
 		since, err := strconv.ParseInt(sinceStr, 10, 64)
 		if err == nil {
@@ -97,14 +328,14 @@ func (bs *BroadcastService) SSEHandler(w http.ResponseWriter, r *http.Request) {
 			// Send historical updates
 			events := bs.store.GetEventsSince(since)
 
-			log.Println("Loaded historical events: ", len(events))
+			logger.Debug("Loaded historical events", "count", len(events), "since", since)
 			for _, event := range events {
-				data, err := json.Marshal(event)
+				data, err := json.Marshal(bs.priceFormat.Event(event))
 				if err != nil {
-					log.Printf("Error marshaling event: %v", err)
+					logger.Error("Error marshaling event", "error", err)
 					continue
 				}
-				fmt.Fprintf(w, "data: %s\n\n", data)
+				fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.Timestamp, data)
 				flusher.Flush()
 				lastTimestamp = event.Timestamp
 			}
@@ -112,35 +343,115 @@ func (bs *BroadcastService) SSEHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		// If no since parameter, send the latest event if available
 		if latestEvent, exists := bs.store.GetLatestEvent(); exists {
-			data, err := json.Marshal(latestEvent)
+			data, err := json.Marshal(bs.priceFormat.Event(latestEvent))
 			if err == nil {
-				fmt.Fprintf(w, "data: %s\n\n", data)
+				fmt.Fprintf(w, "id: %d\ndata: %s\n\n", latestEvent.Timestamp, data)
 				flusher.Flush()
 				lastTimestamp = latestEvent.Timestamp
 			}
 		}
 	}
 
+	if specs != nil {
+		point := bs.liveIndicators.Watch(specs)
+		defer bs.liveIndicators.Release(specs)
+		if lastTimestamp > 0 {
+			writeSSENamedEvent(w, flusher, logger, "indicators", point.Response(bs.priceFormat))
+		}
+	}
+
 	clientChan := bs.SubscribeClient()
+	alertChan := bs.SubscribeAlerts(api.User(r))
+	heartbeatChan := bs.SubscribeHeartbeats()
+	// A nil channel never receives, leaving stats out of the loop below
+	var statsChan chan domain.PriceStats
+	if wantStats {
+		statsChan = bs.SubscribeStats()
+	}
+	logger.Info("SSE client connected", "remote_addr", r.RemoteAddr)
 
 	ctx := r.Context()
 
 	go func() {
 		<-ctx.Done()
 		bs.UnsubscribeClient(clientChan)
+		bs.UnsubscribeAlerts(alertChan)
+		bs.UnsubscribeHeartbeats(heartbeatChan)
+		if statsChan != nil {
+			bs.UnsubscribeStats(statsChan)
+		}
+		logger.Info("SSE client disconnected", "remote_addr", r.RemoteAddr)
 	}()
 
-	for event := range clientChan {
-		if event.Timestamp > lastTimestamp {
-			data, err := json.Marshal(event)
-			if err != nil {
-				log.Printf("Error marshaling event: %v", err)
-				continue
+	for {
+		select {
+		case event, ok := <-clientChan:
+			if !ok {
+				return
 			}
-
-			fmt.Fprintf(w, "data: %s\n\n", data)
-			flusher.Flush()
-			lastTimestamp = event.Timestamp
+			if event.Timestamp > lastTimestamp {
+				writeSSEEvent(w, flusher, logger, bs.priceFormat, event)
+				lastTimestamp = event.Timestamp
+				if point, ok := bs.indicatorsAt(event.Timestamp, specs); ok {
+					writeSSENamedEvent(w, flusher, logger, "indicators", point.Response(bs.priceFormat))
+				}
+			}
+		case alert, ok := <-alertChan:
+			if !ok {
+				return
+			}
+			writeSSENamedEvent(w, flusher, logger, "alert", bs.priceFormat.Alert(alert))
+		case heartbeat, ok := <-heartbeatChan:
+			if !ok {
+				return
+			}
+			writeSSENamedEvent(w, flusher, logger, "heartbeat", heartbeat)
+		case stats, ok := <-statsChan:
+			if !ok {
+				return
+			}
+			writeSSENamedEvent(w, flusher, logger, "stats", stats)
 		}
 	}
 }
+
+// indicatorsAt returns the indicators of specs after the live event at
+// timestamp, or false when the stream asked for none or fell too far behind
+func (bs *BroadcastService) indicatorsAt(timestamp int64, specs []indicator.Spec) (indicator.Point, bool) {
+	if specs == nil {
+		return indicator.Point{}, false
+	}
+	return bs.liveIndicators.Point(timestamp, specs)
+}
+
+// writeSSEEvent writes a live event to a stream in a span joined to the
+// event's trace. Price events carry their timestamp as the event ID, which
+// clients send back in Last-Event-ID to resume after a reconnect. It is
+// unique since the store keeps at most one event per second.
+func writeSSEEvent(w http.ResponseWriter, flusher http.Flusher, logger *slog.Logger, format api.PriceFormat, event domain.PriceUpdateEvent) {
+	_, span := tracing.Start(tracing.Extract(event.TraceParent), "sse.write")
+
+	data, err := json.Marshal(format.Event(event))
+	if err != nil {
+		logger.Error("Error marshaling event", "error", err)
+		tracing.End(span, err)
+		return
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.Timestamp, data)
+	flusher.Flush()
+	tracing.End(span, err)
+}
+
+// writeSSENamedEvent writes data as a named event such as "alert". Clients
+// that only handle the default message event ignore it.
+func writeSSENamedEvent(w http.ResponseWriter, flusher http.Flusher, logger *slog.Logger, name string, data any) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		logger.Error("Error marshaling event", "event", name, "error", err)
+		return
+	}
+
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, encoded)
+	flusher.Flush()
+}
@@ -1,13 +1,17 @@
 package service
 
 import (
-	"btc-price-tracker/internal/domain"
-	"btc-price-tracker/internal/store"
 	"context"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/indicator"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
 )
 
 func TestBroadcastService_SubscribeUnsubscribe(t *testing.T) {
@@ -64,7 +68,7 @@ func TestBroadcastService_BroadcastUpdates(t *testing.T) {
 	// Send an update
 	testUpdate := domain.PriceUpdateEvent{
 		Timestamp: time.Now().Unix(),
-		Price:     60000.0,
+		Price:     domain.NewPrice(60000.0),
 	}
 	updateChan <- testUpdate
 
@@ -74,8 +78,8 @@ func TestBroadcastService_BroadcastUpdates(t *testing.T) {
 	// Check if both clients received the update
 	select {
 	case update := <-client1:
-		if update.Price != 60000.0 {
-			t.Errorf("Client 1: Expected price 60000.0, got %.2f", update.Price)
+		if update.Price.String() != "60000" {
+			t.Errorf("Client 1: Expected price 60000.0, got %s", update.Price)
 		}
 	case <-time.After(100 * time.Millisecond):
 		t.Error("Client 1: Timeout waiting for update")
@@ -83,8 +87,8 @@ func TestBroadcastService_BroadcastUpdates(t *testing.T) {
 
 	select {
 	case update := <-client2:
-		if update.Price != 60000.0 {
-			t.Errorf("Client 2: Expected price 60000.0, got %.2f", update.Price)
+		if update.Price.String() != "60000" {
+			t.Errorf("Client 2: Expected price 60000.0, got %s", update.Price)
 		}
 	case <-time.After(100 * time.Millisecond):
 		t.Error("Client 2: Timeout waiting for update")
@@ -103,9 +107,9 @@ func TestBroadcastService_SSEHandler(t *testing.T) {
 
 	// Add some test data to the store
 	testEvents := []domain.PriceUpdateEvent{
-		{Timestamp: 100, Price: 50000.0},
-		{Timestamp: 200, Price: 51000.0},
-		{Timestamp: 300, Price: 52000.0},
+		{Timestamp: 100, Price: domain.NewPrice(50000.0)},
+		{Timestamp: 200, Price: domain.NewPrice(51000.0)},
+		{Timestamp: 300, Price: domain.NewPrice(52000.0)},
 	}
 
 	for _, event := range testEvents {
@@ -130,7 +134,7 @@ func TestBroadcastService_SSEHandler(t *testing.T) {
 	// Send a new update that should be received
 	newUpdate := domain.PriceUpdateEvent{
 		Timestamp: 400,
-		Price:     53000.0,
+		Price:     domain.NewPrice(53000.0),
 	}
 
 	// Allow some time for initial history to be sent
@@ -172,3 +176,189 @@ func TestBroadcastService_SSEHandler(t *testing.T) {
 		t.Error("Response should not include event with timestamp 100")
 	}
 }
+
+func TestBroadcastService_SSEAlerts(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	broadcastService := NewBroadcastService(memStore, make(chan domain.PriceUpdateEvent))
+	alertChan := make(chan domain.Alert, 10)
+	broadcastService.BroadcastAlerts(alertChan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcastService.Start(ctx)
+
+	stream := func(user string) (*httptest.ResponseRecorder, context.CancelFunc, chan struct{}) {
+		req := httptest.NewRequest("GET", "/prices/stream", nil)
+		reqCtx, reqCancel := context.WithCancel(api.WithUser(req.Context(), user))
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			broadcastService.SSEHandler(w, req.WithContext(reqCtx))
+			close(done)
+		}()
+		return w, reqCancel, done
+	}
+
+	alice, cancelAlice, aliceDone := stream("alice")
+	bob, cancelBob, bobDone := stream("bob")
+	time.Sleep(50 * time.Millisecond)
+
+	alertChan <- domain.Alert{RuleID: "private", Owner: "alice"}
+	alertChan <- domain.Alert{RuleID: "global"}
+	time.Sleep(50 * time.Millisecond)
+
+	cancelAlice()
+	cancelBob()
+	<-aliceDone
+	<-bobDone
+
+	if !strings.Contains(alice.Body.String(), "event: alert\ndata: {\"rule_id\":\"private\"") {
+		t.Errorf("Expected alice to receive her alert as a named event, got %q", alice.Body.String())
+	}
+	if !strings.Contains(bob.Body.String(), `"rule_id":"global"`) || strings.Contains(bob.Body.String(), "private") {
+		t.Errorf("Expected bob to receive only the global alert, got %q", bob.Body.String())
+	}
+}
+
+func TestBroadcastService_SSEIndicators(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	updateChan := make(chan domain.PriceUpdateEvent, 10)
+	broadcastService := NewBroadcastService(memStore, updateChan)
+	broadcastService.StreamIndicators(indicator.NewService(memStore, indicator.DefaultConfig()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcastService.Start(ctx)
+
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(100)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 200, Price: domain.NewPrice(200)})
+
+	w := httptest.NewRecorder()
+	if broadcastService.SSEHandler(w, httptest.NewRequest("GET", "/prices/stream?indicators=macd:2", nil)); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown indicator, got %d", w.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/prices/stream?indicators=sma:2", nil)
+	reqCtx, reqCancel := context.WithCancel(req.Context())
+	w = httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		broadcastService.SSEHandler(w, req.WithContext(reqCtx))
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	updateChan <- domain.PriceUpdateEvent{Timestamp: 300, Price: domain.NewPrice(400)}
+	time.Sleep(50 * time.Millisecond)
+	reqCancel()
+	<-done
+
+	body := w.Body.String()
+	// Primed from history, then updated after the live price event
+	if !strings.Contains(body, `event: indicators`+"\n"+`data: {"timestamp":200,"price":"200","values":{"sma_2":{"value":150}}}`) {
+		t.Errorf("Expected primed indicators, got %q", body)
+	}
+	if !strings.Contains(body, `data: {"timestamp":300,"price":"400","values":{"sma_2":{"value":300}}}`) {
+		t.Errorf("Expected indicators for the live event, got %q", body)
+	}
+}
+
+func TestBroadcastService_SSEStats(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	broadcastService := NewBroadcastService(memStore, make(chan domain.PriceUpdateEvent))
+	statsChan := make(chan domain.PriceStats, 10)
+	broadcastService.BroadcastStats(statsChan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcastService.Start(ctx)
+
+	stream := func(url string) (*httptest.ResponseRecorder, context.CancelFunc, chan struct{}) {
+		req := httptest.NewRequest("GET", url, nil)
+		reqCtx, reqCancel := context.WithCancel(req.Context())
+		w := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			broadcastService.SSEHandler(w, req.WithContext(reqCtx))
+			close(done)
+		}()
+		return w, reqCancel, done
+	}
+
+	withStats, cancelWith, withDone := stream("/prices/stream?stats=true")
+	without, cancelWithout, withoutDone := stream("/prices/stream")
+	time.Sleep(50 * time.Millisecond)
+
+	statsChan <- domain.PriceStats{Timestamp: 100, Windows: []domain.WindowStats{{Window: "1h", Count: 1}}}
+	time.Sleep(50 * time.Millisecond)
+
+	cancelWith()
+	cancelWithout()
+	<-withDone
+	<-withoutDone
+
+	if !strings.Contains(withStats.Body.String(), "event: stats\ndata: {\"timestamp\":100,\"windows\":[{\"window\":\"1h\"") {
+		t.Errorf("Expected a named stats event, got %q", withStats.Body.String())
+	}
+	if strings.Contains(without.Body.String(), "event: stats") {
+		t.Errorf("Expected no stats without stats=true, got %q", without.Body.String())
+	}
+}
+
+func TestBroadcastService_SSEHeartbeats(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	broadcastService := NewBroadcastService(memStore, make(chan domain.PriceUpdateEvent))
+	heartbeatChan := make(chan domain.Heartbeat, 1)
+	broadcastService.BroadcastHeartbeats(heartbeatChan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broadcastService.Start(ctx)
+
+	req := httptest.NewRequest("GET", "/prices/stream", nil)
+	reqCtx, reqCancel := context.WithCancel(req.Context())
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		broadcastService.SSEHandler(w, req.WithContext(reqCtx))
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	heartbeatChan <- domain.Heartbeat{Timestamp: 500}
+	time.Sleep(50 * time.Millisecond)
+	reqCancel()
+	<-done
+
+	if !strings.Contains(w.Body.String(), "event: heartbeat\ndata: {\"timestamp\":500}\n\n") {
+		t.Errorf("Expected a named heartbeat event, got %q", w.Body.String())
+	}
+}
+
+func TestBroadcastService_SSELastEventID(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	for _, timestamp := range []int64{100, 200, 300} {
+		memStore.Store(domain.PriceUpdateEvent{Timestamp: timestamp, Price: domain.NewPrice(float64(timestamp))})
+	}
+	broadcastService := NewBroadcastService(memStore, make(chan domain.PriceUpdateEvent))
+
+	// The reconnect repeats the original since, which Last-Event-ID overrides
+	req := httptest.NewRequest("GET", "/prices/stream?since=100", nil)
+	req.Header.Set("Last-Event-ID", "200")
+	reqCtx, reqCancel := context.WithCancel(req.Context())
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		broadcastService.SSEHandler(w, req.WithContext(reqCtx))
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	reqCancel()
+	<-done
+
+	if body := w.Body.String(); body != "id: 300\ndata: {\"timestamp\":300,\"price\":\"300\"}\n\n" {
+		t.Errorf("Expected only the event after 200, got %q", body)
+	}
+}
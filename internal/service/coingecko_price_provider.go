@@ -3,48 +3,120 @@ package service
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
 )
 
-type CoinGeckoPriceProvider struct{}
+const coinGeckoBaseURL = "https://api.coingecko.com"
+
+type CoinGeckoPriceProvider struct {
+	baseURL string
+}
 
 func NewCoinGeckoPriceProvider() *CoinGeckoPriceProvider {
-	return &CoinGeckoPriceProvider{}
+	return &CoinGeckoPriceProvider{baseURL: coinGeckoBaseURL}
 }
 
-func (p *CoinGeckoPriceProvider) FetchPrice() (float64, error) {
+func (p *CoinGeckoPriceProvider) FetchPrice() (price domain.Price, err error) {
+	defer func(start time.Time) { metrics.ObserveFetch("coingecko", start, err) }(time.Now())
+
 	// Use coingecko API
-	response, err := http.Get("https://api.coingecko.com/api/v3/simple/price?ids=bitcoin&vs_currencies=usd")
+	response, err := http.Get(p.baseURL + "/api/v3/simple/price?ids=bitcoin&vs_currencies=usd")
 	if err != nil {
-		return 0, err
+		return domain.Price{}, err
 	}
 	defer response.Body.Close()
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		return 0, err
+		return domain.Price{}, err
 	}
 
 	// Parse the JSON response
 	var result coinGeckoPriceResult
 	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
+		return domain.Price{}, err
 	}
 
 	if result.Status != nil && result.Status.ErrorCode != 0 {
-		return 0, errors.New(result.Status.ErrorMessage)
+		return domain.Price{}, errors.New(result.Status.ErrorMessage)
 	}
 
+	if result.Bitcoin == nil {
+		return domain.Price{}, errors.New("response has no bitcoin price")
+	}
 	return result.Bitcoin.USD, nil
 }
 
+// FetchHistory retrieves prices between from and to using the CoinGecko
+// market_chart/range API. Granularity is chosen by CoinGecko based on the
+// length of the range.
+func (p *CoinGeckoPriceProvider) FetchHistory(from, to time.Time) ([]domain.PriceUpdateEvent, error) {
+	url := fmt.Sprintf("%s/api/v3/coins/bitcoin/market_chart/range?vs_currency=usd&from=%d&to=%d",
+		p.baseURL, from.Unix(), to.Unix())
+
+	response, err := http.Get(url) // #nosec G107 -- URL is built from the configured base URL
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result coinGeckoMarketChartResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	if result.Status != nil && result.Status.ErrorCode != 0 {
+		return nil, errors.New(result.Status.ErrorMessage)
+	}
+
+	events := make([]domain.PriceUpdateEvent, 0, len(result.Prices))
+	for _, point := range result.Prices {
+		timestamp, err := point[0].Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", point[0], err)
+		}
+		price, err := domain.ParsePrice(point[1].String())
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, domain.PriceUpdateEvent{
+			Timestamp:  int64(timestamp) / 1000,
+			Price:      price,
+			Backfilled: true,
+		})
+	}
+
+	return events, nil
+}
+
+type coinGeckoStatus struct {
+	ErrorCode    int    `json:"error_code"`
+	ErrorMessage string `json:"error_message"`
+}
+
 type coinGeckoPriceResult struct {
-	Bitcoin struct {
-		USD float64 `json:"usd"`
+	Bitcoin *struct {
+		// USD is decoded from the JSON number's exact digits
+		USD domain.Price `json:"usd"`
 	} `json:"bitcoin"`
-	Status *struct {
-		ErrorCode    int    `json:"error_code"`
-		ErrorMessage string `json:"error_message"`
-	} `json:"status"`
+	Status *coinGeckoStatus `json:"status"`
+}
+
+// coinGeckoMarketChartResult holds [timestampMillis, price] pairs, kept as
+// numbers so prices keep their exact digits
+type coinGeckoMarketChartResult struct {
+	Prices [][2]json.Number `json:"prices"`
+	Status *coinGeckoStatus `json:"status"`
 }
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// DedupConfig controls suppression of unchanged prices
+type DedupConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Epsilon is the largest price change in USD still treated as unchanged
+	Epsilon float64 `yaml:"epsilon"`
+	// MaxInterval is the longest time between stored events; an unchanged
+	// price is stored anyway once it elapses
+	MaxInterval time.Duration `yaml:"max_interval"`
+}
+
+// DefaultDedupConfig returns the deduplication defaults. Deduplication is
+// disabled by default.
+func DefaultDedupConfig() DedupConfig {
+	return DedupConfig{
+		MaxInterval: time.Minute,
+	}
+}
+
+// Validate checks the deduplication settings
+func (c DedupConfig) Validate() error {
+	var errs []error
+
+	if c.Epsilon < 0 || math.IsNaN(c.Epsilon) {
+		errs = append(errs, fmt.Errorf("dedup.epsilon must not be negative, got %v", c.Epsilon))
+	}
+	if c.MaxInterval <= 0 {
+		errs = append(errs, fmt.Errorf("dedup.max_interval must be positive, got %v", c.MaxInterval))
+	}
+
+	return errors.Join(errs...)
+}
+
+// duplicates reports whether update repeats last closely enough to be
+// replaced by a heartbeat. Prices are compared exactly.
+func (c DedupConfig) duplicates(last, update domain.Price, lastTimestamp, timestamp int64) bool {
+	if !c.Enabled || lastTimestamp == 0 {
+		return false
+	}
+	if time.Duration(timestamp-lastTimestamp)*time.Second >= c.MaxInterval {
+		return false
+	}
+	return update.Sub(last).Abs().Cmp(domain.NewPrice(c.Epsilon)) <= 0
+}
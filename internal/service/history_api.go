@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+const (
+	// defaultHistoryRange is the span of history returned when from is not given
+	defaultHistoryRange = time.Hour
+	// defaultHistoryLimit and maxHistoryLimit bound the events in one response
+	defaultHistoryLimit = 1000
+	maxHistoryLimit     = 10000
+)
+
+// errHistoryLimit stops iterating once a page is full
+var errHistoryLimit = errors.New("history limit reached")
+
+// historyResponse is a page of stored events. Next is set when events were
+// left out and is the from value that fetches the following page.
+type historyResponse struct {
+	Events []api.PriceEvent `json:"events"`
+	Next   int64            `json:"next,omitempty"`
+}
+
+// HistoryAPI serves stored price events over REST
+type HistoryAPI struct {
+	store store.EventStore
+
+	// priceFormat encodes prices in responses
+	priceFormat api.PriceFormat
+}
+
+func NewHistoryAPI(store store.EventStore) *HistoryAPI {
+	return &HistoryAPI{store: store}
+}
+
+// SetPriceFormat selects how responses encode prices
+func (h *HistoryAPI) SetPriceFormat(format api.PriceFormat) {
+	h.priceFormat = format
+}
+
+// RegisterRoutes adds the price history API to mux
+func (h *HistoryAPI) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/prices", h.getHistory)
+	mux.HandleFunc("GET /api/v1/prices/latest", h.getLatest)
+}
+
+// getHistory returns the events between the from and to query parameters, in
+// Unix seconds, oldest first. It defaults to the last hour and returns at
+// most limit events.
+func (h *HistoryAPI) getHistory(w http.ResponseWriter, r *http.Request) {
+	to, ok := queryInt(w, r, "to", time.Now().Unix())
+	if !ok {
+		return
+	}
+	from, ok := queryInt(w, r, "from", to-int64(defaultHistoryRange.Seconds()))
+	if !ok {
+		return
+	}
+	if from > to {
+		api.WriteError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+	limit, ok := queryInt(w, r, "limit", defaultHistoryLimit)
+	if !ok {
+		return
+	}
+	if limit < 1 || limit > maxHistoryLimit {
+		api.WriteError(w, http.StatusBadRequest, "limit must be between 1 and %d, got %d", maxHistoryLimit, limit)
+		return
+	}
+
+	var events []domain.PriceUpdateEvent
+	var next int64
+	collect := func(event domain.PriceUpdateEvent) error {
+		if len(events) == int(limit) {
+			next = event.Timestamp
+			return errHistoryLimit
+		}
+		events = append(events, event)
+		return nil
+	}
+
+	if iterable, ok := h.store.(store.IterableStore); ok {
+		if err := iterable.IterateEvents(from, to, collect); err != nil && !errors.Is(err, errHistoryLimit) {
+			api.WriteError(w, http.StatusInternalServerError, "reading history: %v", err)
+			return
+		}
+	} else {
+		for _, event := range h.store.GetEventsSince(from) {
+			if event.Timestamp > to || collect(event) != nil {
+				break
+			}
+		}
+	}
+
+	api.WriteJSON(w, http.StatusOK, historyResponse{Events: h.priceFormat.Events(events), Next: next})
+}
+
+// getLatest returns the latest stored event
+func (h *HistoryAPI) getLatest(w http.ResponseWriter, r *http.Request) {
+	latest, exists := h.store.GetLatestEvent()
+	if !exists {
+		api.WriteError(w, http.StatusNotFound, "no price data yet")
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, h.priceFormat.Event(latest))
+}
+
+func queryInt(w http.ResponseWriter, r *http.Request, name string, fallback int64) (int64, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, true
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "%s must be an integer, got %q", name, value)
+		return 0, false
+	}
+	return parsed, true
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+func TestHistoryAPI(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	mux := http.NewServeMux()
+	historyAPI := NewHistoryAPI(memStore)
+	historyAPI.RegisterRoutes(mux)
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	if w := get("/api/v1/prices/latest"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without data, got %d", w.Code)
+	}
+
+	for _, timestamp := range []int64{100, 200, 300, 400} {
+		memStore.Store(domain.PriceUpdateEvent{Timestamp: timestamp, Price: domain.NewPrice(float64(timestamp))})
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		status     int
+		timestamps []int64
+		next       int64
+	}{
+		{"Range", "/api/v1/prices?from=150&to=300", http.StatusOK, []int64{200, 300}, 0},
+		{"Paged", "/api/v1/prices?from=100&to=400&limit=3", http.StatusOK, []int64{100, 200, 300}, 400},
+		{"Empty", "/api/v1/prices?from=500&to=600", http.StatusOK, []int64{}, 0},
+		{"Invalid from", "/api/v1/prices?from=yesterday", http.StatusBadRequest, nil, 0},
+		{"Reversed range", "/api/v1/prices?from=300&to=200", http.StatusBadRequest, nil, 0},
+		{"Invalid limit", "/api/v1/prices?limit=0", http.StatusBadRequest, nil, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := get(tc.url)
+			if w.Code != tc.status {
+				t.Fatalf("Expected status %d, got %d: %s", tc.status, w.Code, w.Body)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Events []domain.PriceUpdateEvent `json:"events"`
+				Next   int64                     `json:"next"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Events) != len(tc.timestamps) {
+				t.Fatalf("Expected %d events, got %+v", len(tc.timestamps), response.Events)
+			}
+			for i, event := range response.Events {
+				if event.Timestamp != tc.timestamps[i] {
+					t.Errorf("Event %d: expected timestamp %d, got %d", i, tc.timestamps[i], event.Timestamp)
+				}
+			}
+			if response.Next != tc.next {
+				t.Errorf("Expected next %d, got %d", tc.next, response.Next)
+			}
+		})
+	}
+
+	w := get("/api/v1/prices/latest")
+	if w.Code != http.StatusOK || w.Body.String() != "{\"timestamp\":400,\"price\":\"400\"}\n" {
+		t.Errorf("Unexpected latest response %d %s", w.Code, w.Body)
+	}
+
+	historyAPI.SetPriceFormat(api.PriceFormatNumber)
+	if w := get("/api/v1/prices?from=300&to=400"); w.Body.String() != "{\"events\":[{\"timestamp\":300,\"price\":300},{\"timestamp\":400,\"price\":400}]}\n" {
+		t.Errorf("Expected numeric prices, got %s", w.Body)
+	}
+}
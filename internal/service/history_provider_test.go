@@ -0,0 +1,59 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBinancePriceProvider_FetchHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/klines" || r.URL.Query().Get("interval") != "1m" {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[
+			[1700000000000,"37000.00","37010.00","36990.00","37005.50","1.0",1700000059999,"0",1,"0","0","0"],
+			[1700000060000,"37005.50","37020.00","37000.00","37012.25","1.0",1700000119999,"0",1,"0","0","0"]
+		]`))
+	}))
+	defer server.Close()
+
+	provider := &BinancePriceProvider{baseURL: server.URL}
+	events, err := provider.FetchHistory(time.Unix(1700000000, 0), time.Unix(1700000120, 0))
+	if err != nil {
+		t.Fatalf("FetchHistory failed: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[1].Timestamp != 1700000060 || events[1].Price.String() != "37012.25" || !events[1].Backfilled {
+		t.Errorf("Unexpected event: %+v", events[1])
+	}
+}
+
+func TestCoinGeckoPriceProvider_FetchHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/coins/bitcoin/market_chart/range" || r.URL.Query().Get("from") != "1700000000" {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"prices": [[1700000000000, 37000.5], [1700000300000, 37100.25]]}`))
+	}))
+	defer server.Close()
+
+	provider := &CoinGeckoPriceProvider{baseURL: server.URL}
+	events, err := provider.FetchHistory(time.Unix(1700000000, 0), time.Unix(1700000600, 0))
+	if err != nil {
+		t.Fatalf("FetchHistory failed: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[1].Timestamp != 1700000300 || events[1].Price.String() != "37100.25" || !events[1].Backfilled {
+		t.Errorf("Unexpected event: %+v", events[1])
+	}
+}
@@ -1,24 +1,55 @@
 package service
 
 import (
-	"btc-price-tracker/internal/domain"
-	"btc-price-tracker/internal/store"
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+	"github.com/tstojkovski/btc-price-tracker/internal/tracing"
+	"github.com/tstojkovski/btc-price-tracker/internal/validation"
 )
 
 type PriceService struct {
 	store         store.EventStore
 	updateChan    chan domain.PriceUpdateEvent
+	subscribers   []chan domain.PriceUpdateEvent
+	mutex         sync.RWMutex
 	priceProvider PriceProvider
+	pollInterval  time.Duration
+	intervalChan  chan time.Duration
+	validator     *validation.Validator
+
+	// dedup suppresses unchanged prices, which are announced on
+	// heartbeatChan instead
+	dedup         DedupConfig
+	heartbeatChan chan domain.Heartbeat
+	lastStored    domain.PriceUpdateEvent
+	lastHeartbeat atomic.Int64
+
+	now func() time.Time
 }
 
-func NewPriceService(store store.EventStore, priceProvider PriceProvider) *PriceService {
+func NewPriceService(store store.EventStore, priceProvider PriceProvider, pollInterval time.Duration) *PriceService {
 	return &PriceService{
 		store:         store,
 		priceProvider: priceProvider,
+		pollInterval:  pollInterval,
 		updateChan:    make(chan domain.PriceUpdateEvent, 1),
+		intervalChan:  make(chan time.Duration, 1),
+		validator:     validation.NewValidator(validation.DefaultConfig()),
+		dedup:         DefaultDedupConfig(),
+		heartbeatChan: make(chan domain.Heartbeat, 1),
+		now:           time.Now,
 	}
 }
 
@@ -30,39 +61,210 @@ func (ps *PriceService) GetUpdateChannel() <-chan domain.PriceUpdateEvent {
 	return ps.updateChan
 }
 
+// GetHeartbeatChannel returns the channel announcing fetches whose price was
+// suppressed as unchanged
+func (ps *PriceService) GetHeartbeatChannel() <-chan domain.Heartbeat {
+	return ps.heartbeatChan
+}
+
+// LastHeartbeat returns the Unix time of the latest accepted fetch, stored
+// or suppressed, or zero before the first one
+func (ps *PriceService) LastHeartbeat() int64 {
+	return ps.lastHeartbeat.Load()
+}
+
+// Subscribe returns an additional channel receiving every update, for
+// consumers other than the broadcaster. It must be called before Start.
+// Updates are dropped for a subscriber that falls behind.
+func (ps *PriceService) Subscribe() <-chan domain.PriceUpdateEvent {
+	subscriber := make(chan domain.PriceUpdateEvent, 10)
+	ps.subscribers = append(ps.subscribers, subscriber)
+	return subscriber
+}
+
+// SetValidator replaces the default tick validation. It must be called
+// before Start.
+func (ps *PriceService) SetValidator(validator *validation.Validator) {
+	ps.validator = validator
+}
+
+// SetDedup changes how unchanged prices are suppressed, taking effect from
+// the next fetch
+func (ps *PriceService) SetDedup(dedup DedupConfig) {
+	ps.mutex.Lock()
+	ps.dedup = dedup
+	ps.mutex.Unlock()
+}
+
+// SetProvider swaps the price provider. The next fetch uses the new provider.
+func (ps *PriceService) SetProvider(priceProvider PriceProvider) {
+	ps.mutex.Lock()
+	ps.priceProvider = priceProvider
+	ps.mutex.Unlock()
+}
+
+// SetPollInterval changes how often prices are fetched without restarting the fetch loop
+func (ps *PriceService) SetPollInterval(interval time.Duration) {
+	ps.mutex.Lock()
+	ps.pollInterval = interval
+	ps.mutex.Unlock()
+
+	// Replace any pending change so the loop only sees the latest interval
+	select {
+	case <-ps.intervalChan:
+	default:
+	}
+	ps.intervalChan <- interval
+}
+
+func (ps *PriceService) provider() PriceProvider {
+	ps.mutex.RLock()
+	defer ps.mutex.RUnlock()
+	return ps.priceProvider
+}
+
+// poll fetches one price, stores it and hands it to the broadcaster. The
+// event carries the poll's trace context so store and broadcast spans join
+// the same trace.
+func (ps *PriceService) poll(ctx context.Context) {
+	ctx, span := tracing.Start(ctx, "price.poll")
+	defer span.End()
+
+	price, err := ps.fetchPrice(ctx)
+	if err != nil {
+		slog.Warn("Error fetching price", "error", err)
+		return
+	}
+
+	update := domain.PriceUpdateEvent{
+		Timestamp:   ps.now().Unix(),
+		Price:       price,
+		TraceParent: tracing.Inject(ctx),
+	}
+	span.SetAttributes(attribute.String("price", update.Price.String()))
+
+	if err := ps.validator.Check(update); err != nil {
+		ps.quarantine(update, err)
+		span.AddEvent("tick quarantined")
+		return
+	}
+
+	ps.lastHeartbeat.Store(update.Timestamp)
+	if ps.sameSecond(update) {
+		// Timestamps identify events in the store and on the stream, so a
+		// second fetch within the same second is dropped
+		metrics.UpdatesSameSecond.Inc()
+		span.AddEvent("same second dropped")
+		slog.Debug("Price dropped, an event is already stored for this second", "timestamp", update.Timestamp)
+		return
+	}
+	if ps.duplicates(update) {
+		ps.sendHeartbeat(update)
+		span.AddEvent("unchanged price suppressed")
+		return
+	}
+	ps.lastStored = update
+
+	ps.store.Store(update)
+	metrics.RecordEvent(update.Timestamp, update.Price.Float64())
+
+	select {
+	case ps.updateChan <- update:
+		// Successfully sent update
+	default:
+		// Channel buffer is full, log and move on
+		metrics.UpdatesSkipped.Inc()
+		span.AddEvent("update skipped")
+		slog.Warn("Update channel buffer full, notification skipped")
+	}
+
+	for _, subscriber := range ps.subscribers {
+		select {
+		case subscriber <- update:
+		default:
+			metrics.UpdatesSkipped.Inc()
+		}
+	}
+
+	slog.Debug("New price", "price", update.Price, "timestamp", update.Timestamp)
+}
+
+// sameSecond reports whether update is not newer than the last stored event
+func (ps *PriceService) sameSecond(update domain.PriceUpdateEvent) bool {
+	if ps.lastStored.Timestamp == 0 {
+		// Compare the first fetch against history from before a restart
+		ps.lastStored, _ = ps.store.GetLatestEvent()
+	}
+	return update.Timestamp <= ps.lastStored.Timestamp
+}
+
+// duplicates reports whether update repeats the last stored price
+func (ps *PriceService) duplicates(update domain.PriceUpdateEvent) bool {
+	ps.mutex.RLock()
+	dedup := ps.dedup
+	ps.mutex.RUnlock()
+
+	return dedup.duplicates(ps.lastStored.Price, update.Price, ps.lastStored.Timestamp, update.Timestamp)
+}
+
+// sendHeartbeat announces a suppressed update. The price metrics still
+// follow every fetch so the feed does not look stale.
+func (ps *PriceService) sendHeartbeat(update domain.PriceUpdateEvent) {
+	metrics.RecordEvent(update.Timestamp, update.Price.Float64())
+	metrics.UpdatesDeduplicated.Inc()
+
+	select {
+	case ps.heartbeatChan <- domain.Heartbeat{Timestamp: update.Timestamp}:
+	default:
+		// An undelivered heartbeat is superseded by the next one
+	}
+
+	slog.Debug("Unchanged price suppressed", "price", update.Price, "timestamp", update.Timestamp)
+}
+
+// quarantine logs and counts a tick that failed validation. It is neither
+// stored nor broadcast.
+func (ps *PriceService) quarantine(update domain.PriceUpdateEvent, err error) {
+	reason := validation.ReasonInvalid
+	var rejection *validation.Rejection
+	if errors.As(err, &rejection) {
+		reason = rejection.Reason
+	}
+
+	metrics.TicksQuarantined.WithLabelValues(reason).Inc()
+	slog.Warn("Price quarantined", "price", update.Price, "timestamp", update.Timestamp, "reason", reason, "error", err)
+}
+
+func (ps *PriceService) fetchPrice(ctx context.Context) (price domain.Price, err error) {
+	provider := ps.provider()
+
+	_, span := tracing.Start(ctx, "provider.FetchPrice",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("provider", fmt.Sprintf("%T", provider))),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return provider.FetchPrice()
+}
+
 // fetchPrices periodically fetches BTC/USD prices
 func (ps *PriceService) fetchPrices(ctx context.Context) {
-	ticker := time.NewTicker(10 * time.Second)
+	ps.mutex.RLock()
+	ticker := time.NewTicker(ps.pollInterval)
+	ps.mutex.RUnlock()
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			price, err := ps.priceProvider.FetchPrice()
-			if err != nil {
-				log.Printf("Error fetching price: %v", err)
-				continue
-			}
-
-			update := domain.PriceUpdateEvent{
-				Timestamp: time.Now().Unix(),
-				Price:     price,
-			}
-
-			ps.store.Store(update)
-
-			select {
-			case ps.updateChan <- update:
-				// Successfully sent update
-			default:
-				// Channel buffer is full, log and move on
-				log.Println("Update channel buffer full, notification skipped")
-			}
+			ps.poll(ctx)
 
-			log.Printf("New price: $%.2f at %v", update.Price, time.Unix(update.Timestamp, 0))
+		case interval := <-ps.intervalChan:
+			ticker.Reset(interval)
+			slog.Info("Poll interval changed", "interval", interval.String())
 
 		case <-ctx.Done():
-			log.Println("Stopping price fetcher")
+			slog.Info("Stopping price fetcher")
 			return
 		}
 	}
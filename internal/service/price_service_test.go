@@ -1,13 +1,20 @@
 package service
 
 import (
-	"btc-price-tracker/internal/domain"
-	"btc-price-tracker/internal/store"
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
 )
 
 // Mock implementation for the CoinGecko API
@@ -25,8 +32,8 @@ func setupMockAPI(price float64) *httptest.Server {
 
 type MockPriceProvider struct{}
 
-func (m *MockPriceProvider) FetchPrice() (float64, error) {
-	return 0, nil
+func (m *MockPriceProvider) FetchPrice() (domain.Price, error) {
+	return domain.Price{}, nil
 }
 
 func TestPriceService_FetchPrice(t *testing.T) {
@@ -37,7 +44,7 @@ func TestPriceService_FetchPrice(t *testing.T) {
 	// Replace the original URL with our mock server URL in the fetchBTCPrice function
 
 	memStore := store.NewMemoryStore(10)
-	priceService := NewPriceService(memStore, &MockPriceProvider{})
+	priceService := NewPriceService(memStore, &MockPriceProvider{}, 10*time.Second)
 
 	// Get the update channel
 	updateChan := priceService.GetUpdateChannel()
@@ -45,7 +52,7 @@ func TestPriceService_FetchPrice(t *testing.T) {
 	// Send a mock price update to test the channel
 	mockUpdate := domain.PriceUpdateEvent{
 		Timestamp: time.Now().Unix(),
-		Price:     55000.0,
+		Price:     domain.NewPrice(55000.0),
 	}
 
 	// Store it manually
@@ -59,10 +66,213 @@ func TestPriceService_FetchPrice(t *testing.T) {
 	// Try to receive from the channel
 	select {
 	case update := <-updateChan:
-		if update.Price != 55000.0 {
-			t.Errorf("Expected price 55000.0, got %.2f", update.Price)
+		if update.Price.String() != "55000" {
+			t.Errorf("Expected price 55000.0, got %s", update.Price)
 		}
 	case <-time.After(100 * time.Millisecond):
 		t.Fatal("Timeout waiting for update")
 	}
 }
+
+type staticPriceProvider struct {
+	price float64
+}
+
+func (s *staticPriceProvider) FetchPrice() (domain.Price, error) {
+	return domain.NewPrice(s.price), nil
+}
+
+func TestPriceService_ReconfigureWhileRunning(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	priceService := NewPriceService(memStore, &staticPriceProvider{price: 100}, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	priceService.Start(ctx)
+
+	// Nothing is fetched with the initial one hour interval
+	time.Sleep(50 * time.Millisecond)
+	if _, exists := memStore.GetLatestEvent(); exists {
+		t.Fatal("Expected no fetch before the interval elapses")
+	}
+
+	priceService.SetProvider(&staticPriceProvider{price: 200})
+	priceService.SetPollInterval(20 * time.Millisecond)
+
+	select {
+	case update := <-priceService.GetUpdateChannel():
+		if update.Price.String() != "200" {
+			t.Errorf("Expected price from the new provider, got %s", update.Price)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for update after changing the poll interval")
+	}
+}
+
+func TestPriceService_TracesPipeline(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	memStore := store.NewMemoryStore(10)
+	priceService := NewPriceService(memStore, &staticPriceProvider{price: 100}, time.Hour)
+	broadcastService := NewBroadcastService(memStore, priceService.GetUpdateChannel())
+
+	priceService.poll(context.Background())
+	update := <-priceService.GetUpdateChannel()
+	broadcastService.broadcastToAllClients(update)
+
+	if update.TraceParent == "" {
+		t.Fatal("Expected the event to carry trace context")
+	}
+	if latest, _ := memStore.GetLatestEvent(); latest.TraceParent != "" {
+		t.Error("Expected trace context not to be stored")
+	}
+
+	traces := make(map[string]string)
+	for _, span := range recorder.Ended() {
+		traces[span.Name()] = span.SpanContext().TraceID().String()
+	}
+	for _, name := range []string{"provider.FetchPrice", "store.store", "broadcast"} {
+		if traces[name] != traces["price.poll"] {
+			t.Errorf("Expected %s span in the poll trace, got %v", name, traces)
+		}
+	}
+}
+
+func TestPriceService_QuarantinesBadTicks(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	provider := &staticPriceProvider{price: 0}
+	priceService := NewPriceService(memStore, provider, time.Hour)
+
+	priceService.poll(context.Background())
+	if _, exists := memStore.GetLatestEvent(); exists {
+		t.Error("Expected a zero price not to be stored")
+	}
+	select {
+	case update := <-priceService.GetUpdateChannel():
+		t.Errorf("Expected a zero price not to be broadcast, got %+v", update)
+	default:
+	}
+
+	provider.price = 100
+	priceService.poll(context.Background())
+	if latest, exists := memStore.GetLatestEvent(); !exists || latest.Price.String() != "100" {
+		t.Errorf("Expected a valid price to be stored, got %+v", latest)
+	}
+}
+
+func TestCoinGeckoPriceProvider_MissingPrice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	provider := &CoinGeckoPriceProvider{baseURL: server.URL}
+	if price, err := provider.FetchPrice(); err == nil {
+		t.Errorf("Expected an error for a response without a bitcoin price, got %v", price)
+	}
+}
+
+func TestPriceService_DedupUnchangedPrices(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	provider := &staticPriceProvider{price: 100}
+	priceService := NewPriceService(memStore, provider, time.Hour)
+	priceService.SetDedup(DedupConfig{Enabled: true, Epsilon: 0.5, MaxInterval: time.Hour})
+	now := time.Unix(1700000000, 0)
+	priceService.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	priceService.poll(context.Background())
+	<-priceService.GetUpdateChannel()
+
+	// Within epsilon: a heartbeat replaces the update
+	provider.price = 100.4
+	priceService.poll(context.Background())
+	select {
+	case update := <-priceService.GetUpdateChannel():
+		t.Errorf("Expected an unchanged price not to be broadcast, got %+v", update)
+	case heartbeat := <-priceService.GetHeartbeatChannel():
+		if heartbeat.Timestamp != priceService.LastHeartbeat() {
+			t.Errorf("Expected heartbeat at %d, got %+v", priceService.LastHeartbeat(), heartbeat)
+		}
+	}
+	if events := memStore.GetEventsSince(0); len(events) != 1 {
+		t.Errorf("Expected only the first price to be stored, got %+v", events)
+	}
+
+	// Beyond epsilon, compared against the last stored price
+	provider.price = 100.6
+	priceService.poll(context.Background())
+	if update := <-priceService.GetUpdateChannel(); update.Price.String() != "100.6" {
+		t.Errorf("Expected a changed price to be broadcast, got %+v", update)
+	}
+}
+
+func TestPriceService_OneEventPerSecond(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	provider := &staticPriceProvider{price: 100}
+	priceService := NewPriceService(memStore, provider, time.Hour)
+	now := time.Unix(1700000000, 0)
+	priceService.now = func() time.Time { return now }
+
+	priceService.poll(context.Background())
+	<-priceService.GetUpdateChannel()
+
+	// A changed price in the same second would reuse the event ID
+	provider.price = 101
+	now = now.Add(900 * time.Millisecond)
+	priceService.poll(context.Background())
+	select {
+	case update := <-priceService.GetUpdateChannel():
+		t.Errorf("Expected a second event in the same second not to be broadcast, got %+v", update)
+	case heartbeat := <-priceService.GetHeartbeatChannel():
+		t.Errorf("Expected no heartbeat for a dropped event, got %+v", heartbeat)
+	default:
+	}
+
+	now = now.Add(100 * time.Millisecond)
+	priceService.poll(context.Background())
+	if update := <-priceService.GetUpdateChannel(); update.Timestamp != 1700000001 || update.Price.String() != "101" {
+		t.Errorf("Expected the next second to be broadcast, got %+v", update)
+	}
+	if events := memStore.GetEventsSince(0); len(events) != 2 {
+		t.Errorf("Expected one event per second to be stored, got %+v", events)
+	}
+}
+
+func TestDedupConfig_MaxInterval(t *testing.T) {
+	dedup := DedupConfig{Enabled: true, MaxInterval: time.Minute}
+	price := domain.NewPrice(100)
+
+	if !dedup.duplicates(price, price, 1000, 1059) {
+		t.Error("Expected an identical price within the interval to be a duplicate")
+	}
+	if dedup.duplicates(price, price, 1000, 1060) {
+		t.Error("Expected an identical price to be stored once the interval elapses")
+	}
+	if (DedupConfig{MaxInterval: time.Minute}).duplicates(price, price, 1000, 1001) {
+		t.Error("Expected nothing to be suppressed when disabled")
+	}
+}
+
+func TestDedupConfig_ComparesExactly(t *testing.T) {
+	dedup := DedupConfig{Enabled: true, Epsilon: 0.1, MaxInterval: time.Minute}
+	parse := func(s string) domain.Price {
+		price, err := domain.ParsePrice(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return price
+	}
+
+	// As floats 0.8 - 0.7 exceeds 0.1, and 1e15+0.01 rounds away the cent
+	if !dedup.duplicates(parse("0.7"), parse("0.8"), 1000, 1001) {
+		t.Error("Expected a change of exactly epsilon to be a duplicate")
+	}
+	if (DedupConfig{Enabled: true, MaxInterval: time.Minute}).duplicates(parse("1000000000000000"), parse("1000000000000000.01"), 1000, 1001) {
+		t.Error("Expected a one cent change to be kept with a zero epsilon")
+	}
+}
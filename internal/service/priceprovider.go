@@ -1,5 +1,17 @@
 package service
 
+import (
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
 type PriceProvider interface {
-	FetchPrice() (float64, error)
+	FetchPrice() (domain.Price, error)
+}
+
+// HistoricalPriceProvider is implemented by providers that can return past
+// prices for backfilling gaps in the store
+type HistoricalPriceProvider interface {
+	FetchHistory(from, to time.Time) ([]domain.PriceUpdateEvent, error)
 }
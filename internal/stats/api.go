@@ -0,0 +1,35 @@
+package stats
+
+import (
+	"net/http"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// RegisterRoutes adds the statistics API to mux
+func (t *Tracker) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/stats", t.getStats)
+}
+
+// getStats returns every window, or only the one named by the window query
+// parameter
+func (t *Tracker) getStats(w http.ResponseWriter, r *http.Request) {
+	stats := t.Stats()
+
+	if name := r.URL.Query().Get("window"); name != "" {
+		var selected []domain.WindowStats
+		for _, window := range stats.Windows {
+			if window.Window == name {
+				selected = append(selected, window)
+			}
+		}
+		if selected == nil {
+			api.WriteError(w, http.StatusBadRequest, "window must be 1h, 24h or 7d, got %q", name)
+			return
+		}
+		stats.Windows = selected
+	}
+
+	api.WriteJSON(w, http.StatusOK, stats)
+}
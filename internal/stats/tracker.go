@@ -0,0 +1,189 @@
+// Package stats maintains rolling price statistics over the update stream.
+package stats
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// Tracker keeps 1h, 24h and 7d rolling windows up to date as price events
+// arrive. Windows end at the current time, so events age out even while no
+// new ones arrive.
+type Tracker struct {
+	store store.EventStore
+	// resolutions are the stored candle resolutions, finest first
+	resolutions []time.Duration
+	now         func() time.Time
+
+	mutex   sync.Mutex
+	windows []*window
+	latest  int64
+
+	subscribers []chan domain.PriceStats
+}
+
+// NewTracker creates a tracker over store. resolutions lists the candle
+// resolutions kept by the retention policy, finest first, which seed the
+// windows where raw events have expired.
+func NewTracker(store store.EventStore, resolutions []time.Duration) *Tracker {
+	return &Tracker{
+		store:       store,
+		resolutions: resolutions,
+		now:         time.Now,
+		windows: []*window{
+			newWindow("1h", time.Hour),
+			newWindow("24h", 24*time.Hour),
+			newWindow("7d", 7*24*time.Hour),
+		},
+	}
+}
+
+// Load replays the stored events covering the longest window. The part of
+// the window older than the oldest raw event is replayed from candles.
+func (t *Tracker) Load() {
+	longest := t.windows[len(t.windows)-1].length
+	cutoff := t.now().Unix() - int64(longest.Seconds())
+
+	events := t.store.GetEventsSince(cutoff)
+	end := t.now().Unix()
+	if len(events) > 0 {
+		end = events[0].Timestamp
+	}
+
+	for _, event := range t.candleEvents(cutoff, end) {
+		t.Update(event)
+	}
+	for _, event := range events {
+		t.Update(event)
+	}
+}
+
+// candleEvents returns events standing in for the stored candles that end
+// between from and to, oldest first. Each tier covers the span before the
+// next finer one, so recent history keeps its finest resolution.
+func (t *Tracker) candleEvents(from, to int64) []domain.PriceUpdateEvent {
+	retentionStore, ok := t.store.(store.RetentionStore)
+	if !ok {
+		return nil
+	}
+
+	var tiers [][]domain.Candle
+	for _, resolution := range t.resolutions {
+		seconds := int64(resolution.Seconds())
+
+		var candles []domain.Candle
+		for _, candle := range retentionStore.GetCandlesSince(seconds, from) {
+			if candle.Timestamp+seconds > to {
+				break
+			}
+			candles = append(candles, candle)
+		}
+		if len(candles) > 0 {
+			tiers = append(tiers, candles)
+			to = candles[0].Timestamp
+		}
+	}
+
+	var events []domain.PriceUpdateEvent
+	for i := len(tiers) - 1; i >= 0; i-- {
+		for _, candle := range tiers[i] {
+			events = append(events, candlePrices(candle)...)
+		}
+	}
+	return events
+}
+
+// candlePrices spreads a candle's open, high, low and close over its bucket,
+// with the extremes in the order that matches its direction
+func candlePrices(candle domain.Candle) []domain.PriceUpdateEvent {
+	first, second := candle.Low, candle.High
+	if candle.Close.Cmp(candle.Open) < 0 {
+		first, second = candle.High, candle.Low
+	}
+
+	step := candle.Resolution / 4
+	return []domain.PriceUpdateEvent{
+		{Timestamp: candle.Timestamp, Price: candle.Open, Backfilled: true},
+		{Timestamp: candle.Timestamp + step, Price: first, Backfilled: true},
+		{Timestamp: candle.Timestamp + 2*step, Price: second, Backfilled: true},
+		{Timestamp: candle.Timestamp + candle.Resolution - 1, Price: candle.Close, Backfilled: true},
+	}
+}
+
+// Update adds an event to every window. Events at or before the latest one
+// seen, such as backfilled history arriving late, are ignored.
+func (t *Tracker) Update(event domain.PriceUpdateEvent) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if event.Timestamp <= t.latest || event.Price.Sign() <= 0 {
+		return false
+	}
+
+	t.latest = event.Timestamp
+	for _, w := range t.windows {
+		w.add(event.Timestamp, event.Price.Float64())
+	}
+	return true
+}
+
+// Stats returns the statistics of every window ending now
+func (t *Tracker) Stats() domain.PriceStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now().Unix()
+	stats := domain.PriceStats{Timestamp: t.latest}
+	for _, w := range t.windows {
+		w.evict(now - int64(w.length.Seconds()))
+		stats.Windows = append(stats.Windows, w.stats())
+	}
+	return stats
+}
+
+// Subscribe returns a channel receiving the statistics after every live
+// event. Updates are dropped for a subscriber that falls behind.
+func (t *Tracker) Subscribe() <-chan domain.PriceStats {
+	subscriber := make(chan domain.PriceStats, 10)
+
+	t.mutex.Lock()
+	t.subscribers = append(t.subscribers, subscriber)
+	t.mutex.Unlock()
+
+	return subscriber
+}
+
+// Start updates the windows from updates until ctx is cancelled
+func (t *Tracker) Start(ctx context.Context, updates <-chan domain.PriceUpdateEvent) {
+	go func() {
+		for {
+			select {
+			case event := <-updates:
+				if t.Update(event) {
+					t.publish(t.Stats())
+				}
+			case <-ctx.Done():
+				slog.Info("Stopping stats tracker")
+				return
+			}
+		}
+	}()
+}
+
+func (t *Tracker) publish(stats domain.PriceStats) {
+	t.mutex.Lock()
+	subscribers := t.subscribers
+	t.mutex.Unlock()
+
+	for _, subscriber := range subscribers {
+		select {
+		case subscriber <- stats:
+		default:
+		}
+	}
+}
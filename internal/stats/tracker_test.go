@@ -0,0 +1,175 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func windowStats(t *testing.T, stats domain.PriceStats, name string) domain.WindowStats {
+	t.Helper()
+	for _, window := range stats.Windows {
+		if window.Window == name {
+			return window
+		}
+	}
+	t.Fatalf("Window %s missing from %+v", name, stats)
+	return domain.WindowStats{}
+}
+
+// newTestTracker returns a tracker whose clock reads now
+func newTestTracker(memStore *store.MemoryStore, resolutions []time.Duration, now int64) *Tracker {
+	tracker := NewTracker(memStore, resolutions)
+	tracker.now = func() time.Time { return time.Unix(now, 0) }
+	return tracker
+}
+
+func TestTracker_RollingWindows(t *testing.T) {
+	tracker := newTestTracker(store.NewMemoryStore(10), nil, 9000)
+
+	// Two hours of prices, one every 30 minutes
+	for i, price := range []float64{100, 120, 90, 110, 105} {
+		tracker.Update(domain.PriceUpdateEvent{Timestamp: int64(i+1) * 1800, Price: domain.NewPrice(price)})
+	}
+
+	stats := tracker.Stats()
+	if stats.Timestamp != 9000 {
+		t.Errorf("Expected stats as of 9000, got %d", stats.Timestamp)
+	}
+
+	// The hour ending at 9000 excludes the event at 5400
+	hour := windowStats(t, stats, "1h")
+	if hour.Count != 2 || hour.Open != 110 || hour.Close != 105 || hour.High != 110 || hour.Low != 105 {
+		t.Errorf("Unexpected 1h window %+v", hour)
+	}
+	if !approxEqual(hour.Change, (105.0-110.0)/110.0*100) || !approxEqual(hour.StdDev, 2.5) {
+		t.Errorf("Unexpected 1h change or stddev %+v", hour)
+	}
+	if !approxEqual(hour.Volatility, math.Abs(math.Log(105.0/110.0))*100) {
+		t.Errorf("Expected volatility from the single in-window return, got %v", hour.Volatility)
+	}
+
+	day := windowStats(t, stats, "24h")
+	if day.Count != 5 || day.Open != 100 || day.High != 120 || day.Low != 90 || !approxEqual(day.Change, 5) {
+		t.Errorf("Unexpected 24h window %+v", day)
+	}
+
+	// Out-of-order events are ignored
+	if tracker.Update(domain.PriceUpdateEvent{Timestamp: 3000, Price: domain.NewPrice(1)}) {
+		t.Error("Expected an out-of-order event to be ignored")
+	}
+}
+
+func TestTracker_LoadAndPublish(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(100)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: 200, Price: domain.NewPrice(110)})
+
+	tracker := newTestTracker(memStore, nil, 300)
+	tracker.Load()
+	if day := windowStats(t, tracker.Stats(), "24h"); day.Count != 2 || day.Open != 100 {
+		t.Fatalf("Expected history to be loaded, got %+v", day)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan domain.PriceUpdateEvent, 1)
+	subscriber := tracker.Subscribe()
+	tracker.Start(ctx, updates)
+
+	updates <- domain.PriceUpdateEvent{Timestamp: 300, Price: domain.NewPrice(121)}
+	select {
+	case stats := <-subscriber:
+		if day := windowStats(t, stats, "24h"); day.Count != 3 || !approxEqual(day.Change, 21) {
+			t.Errorf("Unexpected published stats %+v", day)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected stats to be published")
+	}
+}
+
+func TestTracker_EvictsAgainstNow(t *testing.T) {
+	tracker := newTestTracker(store.NewMemoryStore(10), nil, 1000)
+	tracker.Update(domain.PriceUpdateEvent{Timestamp: 1000, Price: domain.NewPrice(100)})
+	tracker.Update(domain.PriceUpdateEvent{Timestamp: 2000, Price: domain.NewPrice(110)})
+
+	// No events for two hours: the hour window is empty, the day keeps both
+	tracker.now = func() time.Time { return time.Unix(2000+7200, 0) }
+	stats := tracker.Stats()
+	if hour := windowStats(t, stats, "1h"); hour.Count != 0 {
+		t.Errorf("Expected an empty 1h window, got %+v", hour)
+	}
+	if day := windowStats(t, stats, "24h"); day.Count != 2 || day.Close != 110 {
+		t.Errorf("Unexpected 24h window %+v", day)
+	}
+
+	// The window fills again with the next event
+	tracker.Update(domain.PriceUpdateEvent{Timestamp: 9200, Price: domain.NewPrice(120)})
+	if hour := windowStats(t, tracker.Stats(), "1h"); hour.Count != 1 || hour.Open != 120 {
+		t.Errorf("Unexpected 1h window after a new event %+v", hour)
+	}
+}
+
+func TestTracker_LoadSeedsFromCandles(t *testing.T) {
+	const now = 100000
+	memStore := store.NewMemoryStore(10)
+
+	// Raw events only cover the last hour; hourly candles cover the day before
+	memStore.StoreCandles([]domain.Candle{
+		{Timestamp: now - 3*3600, Resolution: 3600, Open: domain.NewPrice(90), High: domain.NewPrice(130), Low: domain.NewPrice(85), Close: domain.NewPrice(95), Count: 60},
+		{Timestamp: now - 2*3600, Resolution: 3600, Open: domain.NewPrice(95), High: domain.NewPrice(100), Low: domain.NewPrice(80), Close: domain.NewPrice(98), Count: 60},
+		// Overlaps the raw events and is ignored
+		{Timestamp: now - 3600, Resolution: 3600, Open: domain.NewPrice(1), High: domain.NewPrice(1), Low: domain.NewPrice(1), Close: domain.NewPrice(1), Count: 60},
+	})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: now - 1800, Price: domain.NewPrice(100)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: now - 60, Price: domain.NewPrice(105)})
+
+	tracker := newTestTracker(memStore, []time.Duration{time.Hour}, now)
+	tracker.Load()
+
+	stats := tracker.Stats()
+	if hour := windowStats(t, stats, "1h"); hour.Count != 2 || hour.Open != 100 {
+		t.Errorf("Expected the 1h window from raw events only, got %+v", hour)
+	}
+	day := windowStats(t, stats, "24h")
+	if day.Open != 90 || day.High != 130 || day.Low != 80 || day.Close != 105 || !approxEqual(day.Change, (105.0-90.0)/90.0*100) {
+		t.Errorf("Expected the 24h window seeded from candles, got %+v", day)
+	}
+}
+
+func TestTracker_API(t *testing.T) {
+	tracker := newTestTracker(store.NewMemoryStore(10), nil, 100)
+	tracker.Update(domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(100)})
+	mux := http.NewServeMux()
+	tracker.RegisterRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/stats?window=24h", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats domain.PriceStats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Windows) != 1 || stats.Windows[0].Window != "24h" || stats.Windows[0].Close != 100 {
+		t.Errorf("Expected only the 24h window, got %+v", stats)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/stats?window=30d", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown window, got %d", w.Code)
+	}
+}
@@ -0,0 +1,113 @@
+package stats
+
+import (
+	"math"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// sample is a price in a window with the log return from the price before it
+type sample struct {
+	timestamp int64
+	price     float64
+	ret       float64
+}
+
+// window keeps running aggregates over the events of the last length. High
+// and low come from monotonic queues so every update is amortized O(1).
+type window struct {
+	name    string
+	length  time.Duration
+	samples []sample
+	// highs and lows hold candidate extremes with decreasing and increasing
+	// prices respectively
+	highs []sample
+	lows  []sample
+
+	// Price sums are taken around shift to keep the variance numerically stable
+	shift float64
+	sum   float64
+	sumSq float64
+	retSq float64
+}
+
+func newWindow(name string, length time.Duration) *window {
+	return &window{name: name, length: length}
+}
+
+// add appends an event and evicts the events that fell out of the window
+func (w *window) add(timestamp int64, price float64) {
+	if len(w.samples) == 0 {
+		w.shift = price
+	}
+
+	s := sample{timestamp: timestamp, price: price}
+	if len(w.samples) > 0 {
+		s.ret = math.Log(price / w.samples[len(w.samples)-1].price)
+	}
+	w.samples = append(w.samples, s)
+	w.sum += price - w.shift
+	w.sumSq += (price - w.shift) * (price - w.shift)
+	w.retSq += s.ret * s.ret
+
+	for len(w.highs) > 0 && w.highs[len(w.highs)-1].price <= price {
+		w.highs = w.highs[:len(w.highs)-1]
+	}
+	w.highs = append(w.highs, s)
+	for len(w.lows) > 0 && w.lows[len(w.lows)-1].price >= price {
+		w.lows = w.lows[:len(w.lows)-1]
+	}
+	w.lows = append(w.lows, s)
+
+	w.evict(timestamp - int64(w.length.Seconds()))
+}
+
+// evict drops the events at or before cutoff
+func (w *window) evict(cutoff int64) {
+	for len(w.samples) > 0 && w.samples[0].timestamp <= cutoff {
+		old := w.samples[0]
+		w.samples = w.samples[1:]
+		if len(w.samples) == 0 {
+			*w = window{name: w.name, length: w.length}
+			return
+		}
+		w.sum -= old.price - w.shift
+		w.sumSq -= (old.price - w.shift) * (old.price - w.shift)
+
+		// The new first event's return starts outside the window
+		w.retSq -= w.samples[0].ret * w.samples[0].ret
+		w.samples[0].ret = 0
+	}
+	if len(w.samples) == 0 {
+		return
+	}
+
+	for w.highs[0].timestamp < w.samples[0].timestamp {
+		w.highs = w.highs[1:]
+	}
+	for w.lows[0].timestamp < w.samples[0].timestamp {
+		w.lows = w.lows[1:]
+	}
+}
+
+func (w *window) stats() domain.WindowStats {
+	stats := domain.WindowStats{Window: w.name, Count: len(w.samples)}
+	if len(w.samples) == 0 {
+		return stats
+	}
+
+	stats.Open = w.samples[0].price
+	stats.Close = w.samples[len(w.samples)-1].price
+	stats.High = w.highs[0].price
+	stats.Low = w.lows[0].price
+
+	if len(w.samples) > 1 {
+		n := float64(len(w.samples))
+		mean := w.sum / n
+		stats.Change = (stats.Close - stats.Open) / stats.Open * 100
+		stats.StdDev = math.Sqrt(math.Max(w.sumSq/n-mean*mean, 0))
+		stats.Volatility = math.Sqrt(math.Max(w.retSq, 0)) * 100
+	}
+	return stats
+}
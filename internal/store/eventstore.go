@@ -1,9 +1,110 @@
 package store
 
-import "btc-price-tracker/internal/domain"
+import (
+	"context"
 
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// EventStore holds price events in timestamp order, at most one per second,
+// which lets stream clients resume by timestamp
 type EventStore interface {
 	Store(event domain.PriceUpdateEvent)
 	GetEventsSince(timestamp int64) []domain.PriceUpdateEvent
 	GetLatestEvent() (domain.PriceUpdateEvent, bool)
 }
+
+// Pinger is implemented by stores backed by a remote service whose
+// reachability can be checked
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// BackgroundStore is implemented by stores that run background work
+// alongside the request path and need to flush state on shutdown.
+type BackgroundStore interface {
+	Start(ctx context.Context)
+	Close() error
+}
+
+// RetentionStore is implemented by stores that support time-based retention
+// and downsampled candles. Resolutions are expressed in seconds.
+type RetentionStore interface {
+	EventStore
+	DeleteEventsBefore(timestamp int64) int
+	StoreCandles(candles []domain.Candle)
+	GetCandlesSince(resolution int64, timestamp int64) []domain.Candle
+	GetLatestCandle(resolution int64) (domain.Candle, bool)
+	DeleteCandlesBefore(resolution int64, timestamp int64) int
+	// TakeLateWrites returns and clears the earliest timestamp inserted out
+	// of order since the last call, from which candles must be rebuilt
+	TakeLateWrites() (int64, bool)
+}
+
+// BackfillStore is implemented by stores that accept events out of
+// timestamp order, such as history recovered after an outage
+type BackfillStore interface {
+	EventStore
+	InsertEvents(events []domain.PriceUpdateEvent) error
+}
+
+// IterableStore is implemented by stores that can stream the events in a
+// time range without loading them all into memory. Bounds are inclusive.
+type IterableStore interface {
+	EventStore
+	IterateEvents(from, to int64, fn func(domain.PriceUpdateEvent) error) error
+}
+
+// EachEvent calls fn for every event of s between from and to, inclusive,
+// streaming them from stores that support it. It stops at the first error
+// fn returns.
+func EachEvent(s EventStore, from, to int64, fn func(domain.PriceUpdateEvent) error) error {
+	if iterable, ok := s.(IterableStore); ok {
+		return iterable.IterateEvents(from, to, fn)
+	}
+
+	for _, event := range s.GetEventsSince(from) {
+		if event.Timestamp > to {
+			break
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AlertStore is implemented by stores that persist alert rules and the
+// evaluation state that keeps them from re-firing after a restart
+type AlertStore interface {
+	EventStore
+	GetAlertRules() []domain.AlertRule
+	SaveAlertRule(rule domain.AlertRule)
+	DeleteAlertRule(id string) bool
+	GetAlertStates() []domain.AlertState
+	SaveAlertState(state domain.AlertState)
+	DeleteAlertState(ruleID string)
+}
+
+// WebhookStore is implemented by stores that persist webhook subscriptions
+// and deliveries that ended up in the dead-letter queue
+type WebhookStore interface {
+	GetWebhooks() []domain.Webhook
+	SaveWebhook(webhook domain.Webhook)
+	DeleteWebhook(id string) bool
+	GetDeadLetters() []domain.DeadLetter
+	SaveDeadLetter(letter domain.DeadLetter)
+	DeleteDeadLetter(id string) bool
+}
+
+// APIKeyStore is implemented by stores that persist API keys and their
+// daily usage
+type APIKeyStore interface {
+	GetAPIKeys() []domain.APIKey
+	SaveAPIKey(key domain.APIKey)
+	// GetAPIKeyUsage returns the usage of one key, oldest day first
+	GetAPIKeyUsage(keyID string) []domain.APIKeyUsage
+	// AddAPIKeyUsage adds the requests and rejections in usage to the counts
+	// of its key and day, so several servers can count the same key
+	AddAPIKeyUsage(usage domain.APIKeyUsage)
+}
@@ -1,74 +1,140 @@
 package store
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
-	"strconv"
 	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/logging"
 )
 
-// NewStoreFromConfig creates a store implementation based on configuration
-func NewStoreFromConfig() EventStore {
-	storeType := os.Getenv("STORE_TYPE")
-	log.Printf("Store type", storeType)
-	switch storeType {
-	case "mongo", "mongodb":
-		log.Printf("Using mongodb")
-		mongoURI := os.Getenv("MONGO_URI")
-		if mongoURI == "" {
-			mongoURI = "mongodb://localhost:27017"
-		}
+const (
+	TypeMemory = "memory"
+	TypeMongo  = "mongo"
+)
 
-		database := os.Getenv("MONGO_DATABASE")
-		if database == "" {
-			database = "btc_price_tracker"
-		}
+// Config selects and configures a store implementation
+type Config struct {
+	Type   string       `yaml:"type"`
+	Memory MemoryConfig `yaml:"memory"`
+	Mongo  MongoConfig  `yaml:"mongo"`
+}
 
-		collection := os.Getenv("MONGO_COLLECTION")
-		if collection == "" {
-			collection = "price_updates"
-		}
+// MemoryConfig configures MemoryStore
+type MemoryConfig struct {
+	Size             int           `yaml:"size"`
+	SnapshotPath     string        `yaml:"snapshot_path"`
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"`
+}
+
+// MongoConfig configures MongoDBStore
+type MongoConfig struct {
+	URI        string        `yaml:"uri"`
+	Database   string        `yaml:"database"`
+	Collection string        `yaml:"collection"`
+	TTL        time.Duration `yaml:"ttl"`
+	Writer     WriterConfig  `yaml:"writer"`
+}
+
+// DefaultConfig returns the store defaults
+func DefaultConfig() Config {
+	return Config{
+		Type: TypeMemory,
+		Memory: MemoryConfig{
+			Size:             100,
+			SnapshotInterval: time.Minute,
+		},
+		Mongo: MongoConfig{
+			URI:        "mongodb://localhost:27017",
+			Database:   "btc_price_tracker",
+			Collection: "price_updates",
+			TTL:        time.Hour,
+			Writer:     DefaultWriterConfig(),
+		},
+	}
+}
 
-		ttlStr := os.Getenv("MONGO_TTL")
-		ttl := 3600 // Default: 1 hour in seconds
-		if ttlStr != "" {
-			var err error
-			ttl, err = strconv.Atoi(ttlStr)
-			if err != nil {
-				log.Printf("Invalid MONGO_TTL value: %s, using default: %d\n", ttlStr, ttl)
-			}
+// Validate checks the configuration of the selected store
+func (c Config) Validate() error {
+	var errs []error
+
+	switch c.Type {
+	case TypeMemory:
+		if c.Memory.Size <= 0 {
+			errs = append(errs, fmt.Errorf("store.memory.size must be positive, got %d", c.Memory.Size))
+		}
+		if c.Memory.SnapshotPath != "" && c.Memory.SnapshotInterval <= 0 {
+			errs = append(errs, fmt.Errorf("store.memory.snapshot_interval must be positive, got %v", c.Memory.SnapshotInterval))
+		}
+	case TypeMongo:
+		if c.Mongo.URI == "" {
+			errs = append(errs, errors.New("store.mongo.uri is required"))
+		}
+		if c.Mongo.Database == "" || c.Mongo.Collection == "" {
+			errs = append(errs, errors.New("store.mongo.database and store.mongo.collection are required"))
 		}
+		if c.Mongo.TTL != 0 && c.Mongo.TTL < time.Second {
+			errs = append(errs, fmt.Errorf("store.mongo.ttl must be 0 or at least 1s, got %v", c.Mongo.TTL))
+		}
+		if c.Mongo.Writer.QueueSize <= 0 || c.Mongo.Writer.BatchSize <= 0 {
+			errs = append(errs, errors.New("store.mongo.writer queue_size and batch_size must be positive"))
+		}
+		if c.Mongo.Writer.FlushInterval <= 0 {
+			errs = append(errs, fmt.Errorf("store.mongo.writer.flush_interval must be positive, got %v", c.Mongo.Writer.FlushInterval))
+		}
+		if c.Mongo.Writer.MaxRetries < 0 || c.Mongo.Writer.RetryBackoff < 0 {
+			errs = append(errs, errors.New("store.mongo.writer max_retries and retry_backoff must not be negative"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("store.type must be %q or %q, got %q", TypeMemory, TypeMongo, c.Type))
+	}
+
+	return errors.Join(errs...)
+}
 
-		store, err := NewMongoDBStore(mongoURI, database, collection, time.Duration(ttl))
+// New creates the store selected by the configuration
+func New(config Config) (EventStore, error) {
+	switch config.Type {
+	case TypeMongo:
+		mongo := config.Mongo
+		store, err := NewMongoDBStore(mongo.URI, mongo.Database, mongo.Collection, mongo.TTL, mongo.Writer)
 		if err != nil {
-			log.Printf("Failed to create MongoDB store: %v, falling back to memory store\n", err)
-			return createMemoryStore()
+			return nil, fmt.Errorf("connecting to MongoDB at %s: %w", logging.RedactURI(mongo.URI), err)
 		}
 
-		log.Printf("Using MongoDB store: %s/%s/%s with TTL: %d seconds\n",
-			mongoURI, database, collection, ttl)
-		return store
+		slog.Info("Using MongoDB store", "uri", logging.RedactURI(mongo.URI),
+			"database", mongo.Database, "collection", mongo.Collection, "ttl", mongo.TTL.String())
+		return store, nil
+
+	case TypeMemory:
+		return createMemoryStore(config.Memory), nil
 
-	case "memory", "":
-		fallthrough
 	default:
-		return createMemoryStore()
+		return nil, fmt.Errorf("unknown store type %q", config.Type)
 	}
 }
 
-func createMemoryStore() EventStore {
-	storeSizeStr := os.Getenv("STORE_SIZE")
-	storeSize := 100 // Default value
+func createMemoryStore(config MemoryConfig) EventStore {
+	slog.Info("Using memory store", "size", config.Size)
+	store := NewMemoryStore(config.Size)
 
-	if storeSizeStr != "" {
-		size, err := strconv.Atoi(storeSizeStr)
-		if err != nil {
-			log.Printf("Invalid STORE_SIZE value: %s, using default: %d\n", storeSizeStr, storeSize)
-		} else {
-			storeSize = size
-		}
+	if config.SnapshotPath == "" {
+		return store
+	}
+
+	// A missing or corrupt snapshot is not fatal: the store starts empty
+	restored, err := store.RestoreSnapshot(config.SnapshotPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		slog.Info("No snapshot found, starting empty", "path", config.SnapshotPath)
+	case err != nil:
+		slog.Error("Skipping unreadable snapshot", "path", config.SnapshotPath, "error", err)
+	default:
+		slog.Info("Restored snapshot", "path", config.SnapshotPath, "count", restored)
 	}
 
-	log.Printf("Using memory store with size: %d\n", storeSize)
-	return NewMemoryStore(storeSize)
+	store.EnableSnapshots(config.SnapshotPath, config.SnapshotInterval)
+	return store
 }
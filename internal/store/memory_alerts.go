@@ -0,0 +1,64 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// GetAlertRules returns all alert rules ordered by ID
+func (ms *MemoryStore) GetAlertRules() []domain.AlertRule {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	rules := make([]domain.AlertRule, 0, len(ms.alertRules))
+	for _, rule := range ms.alertRules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// SaveAlertRule creates or replaces the rule with the same ID
+func (ms *MemoryStore) SaveAlertRule(rule domain.AlertRule) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.alertRules[rule.ID] = rule
+}
+
+// DeleteAlertRule removes a rule and reports whether it existed
+func (ms *MemoryStore) DeleteAlertRule(id string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	_, exists := ms.alertRules[id]
+	delete(ms.alertRules, id)
+	return exists
+}
+
+// GetAlertStates returns the evaluation state of every rule
+func (ms *MemoryStore) GetAlertStates() []domain.AlertState {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	states := make([]domain.AlertState, 0, len(ms.alertStates))
+	for _, state := range ms.alertStates {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].RuleID < states[j].RuleID })
+	return states
+}
+
+// SaveAlertState creates or replaces the state of a rule
+func (ms *MemoryStore) SaveAlertState(state domain.AlertState) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.alertStates[state.RuleID] = state
+}
+
+// DeleteAlertState forgets the evaluation state of a rule
+func (ms *MemoryStore) DeleteAlertState(ruleID string) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delete(ms.alertStates, ruleID)
+}
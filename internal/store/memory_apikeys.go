@@ -0,0 +1,73 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// GetAPIKeys returns all API keys, including revoked ones, ordered by ID
+func (ms *MemoryStore) GetAPIKeys() []domain.APIKey {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	keys := make([]domain.APIKey, 0, len(ms.apiKeys))
+	for _, key := range ms.apiKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// SaveAPIKey creates or replaces the API key with the same ID
+func (ms *MemoryStore) SaveAPIKey(key domain.APIKey) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.apiKeys[key.ID] = key
+}
+
+// GetAPIKeyUsage returns the usage of one key, oldest day first
+func (ms *MemoryStore) GetAPIKeyUsage(keyID string) []domain.APIKeyUsage {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var usage []domain.APIKeyUsage
+	for _, day := range ms.apiKeyUsage {
+		if day.KeyID == keyID {
+			usage = append(usage, day)
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Day < usage[j].Day })
+	return usage
+}
+
+// AddAPIKeyUsage adds usage to the counts of its key and day
+func (ms *MemoryStore) AddAPIKeyUsage(usage domain.APIKeyUsage) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	id := usageID(usage)
+	stored := ms.apiKeyUsage[id]
+	usage.Requests += stored.Requests
+	usage.Rejected += stored.Rejected
+	usage.LastUsedAt = max(usage.LastUsedAt, stored.LastUsedAt)
+	ms.apiKeyUsage[id] = usage
+}
+
+// allAPIKeyUsage returns the usage of every key for snapshots
+func (ms *MemoryStore) allAPIKeyUsage() []domain.APIKeyUsage {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	usage := make([]domain.APIKeyUsage, 0, len(ms.apiKeyUsage))
+	for _, day := range ms.apiKeyUsage {
+		usage = append(usage, day)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usageID(usage[i]) < usageID(usage[j]) })
+	return usage
+}
+
+// usageID identifies the usage of a key on one day
+func usageID(usage domain.APIKeyUsage) string {
+	return usage.KeyID + "/" + usage.Day
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// StoreCandles inserts candles, replacing any existing candle for the same bucket
+func (ms *MemoryStore) StoreCandles(candles []domain.Candle) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, candle := range candles {
+		series := ms.candles[candle.Resolution]
+		idx := sort.Search(len(series), func(i int) bool {
+			return series[i].Timestamp >= candle.Timestamp
+		})
+
+		if idx < len(series) && series[idx].Timestamp == candle.Timestamp {
+			series[idx] = candle
+			continue
+		}
+
+		series = append(series, domain.Candle{})
+		copy(series[idx+1:], series[idx:])
+		series[idx] = candle
+		ms.candles[candle.Resolution] = series
+	}
+}
+
+// GetCandlesSince returns candles of the given resolution starting at or after timestamp
+func (ms *MemoryStore) GetCandlesSince(resolution int64, timestamp int64) []domain.Candle {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	series := ms.candles[resolution]
+	idx := sort.Search(len(series), func(i int) bool {
+		return series[i].Timestamp >= timestamp
+	})
+
+	result := make([]domain.Candle, len(series)-idx)
+	copy(result, series[idx:])
+	return result
+}
+
+// GetLatestCandle returns the most recent candle of the given resolution
+func (ms *MemoryStore) GetLatestCandle(resolution int64) (domain.Candle, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	series := ms.candles[resolution]
+	if len(series) == 0 {
+		return domain.Candle{}, false
+	}
+	return series[len(series)-1], true
+}
+
+// DeleteCandlesBefore drops candles of the given resolution that start before timestamp
+func (ms *MemoryStore) DeleteCandlesBefore(resolution int64, timestamp int64) int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	series := ms.candles[resolution]
+	idx := sort.Search(len(series), func(i int) bool {
+		return series[i].Timestamp >= timestamp
+	})
+
+	ms.candles[resolution] = append([]domain.Candle(nil), series[idx:]...)
+	return idx
+}
+
+// TakeLateWrites returns and clears the earliest timestamp passed to
+// InsertEvents since the last call
+func (ms *MemoryStore) TakeLateWrites() (int64, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	from, ok := ms.lateFrom, ms.hasLateWrites
+	ms.lateFrom, ms.hasLateWrites = 0, false
+	return from, ok
+}
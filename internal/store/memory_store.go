@@ -1,8 +1,14 @@
 package store
 
 import (
-	"btc-price-tracker/internal/domain"
+	"context"
+	"sort"
 	"sync"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+	"github.com/tstojkovski/btc-price-tracker/internal/tracing"
 )
 
 type MemoryStore struct {
@@ -11,6 +17,21 @@ type MemoryStore struct {
 	capacity  int
 	nextIndex int
 	size      int
+	candles   map[int64][]domain.Candle
+	// lateFrom is the earliest inserted timestamp not yet taken by the
+	// compactor, valid when hasLateWrites is set
+	lateFrom      int64
+	hasLateWrites bool
+
+	alertRules  map[string]domain.AlertRule
+	alertStates map[string]domain.AlertState
+	webhooks    map[string]domain.Webhook
+	deadLetters map[string]domain.DeadLetter
+	apiKeys     map[string]domain.APIKey
+	apiKeyUsage map[string]domain.APIKeyUsage
+
+	snapshotPath     string
+	snapshotInterval time.Duration
 }
 
 func NewMemoryStore(capacity int) *MemoryStore {
@@ -19,13 +40,32 @@ func NewMemoryStore(capacity int) *MemoryStore {
 		capacity:  capacity,
 		nextIndex: 0,
 		size:      0,
+		candles:   make(map[int64][]domain.Candle),
+
+		alertRules:  make(map[string]domain.AlertRule),
+		alertStates: make(map[string]domain.AlertState),
+		webhooks:    make(map[string]domain.Webhook),
+		deadLetters: make(map[string]domain.DeadLetter),
+		apiKeys:     make(map[string]domain.APIKey),
+		apiKeyUsage: make(map[string]domain.APIKeyUsage),
 	}
 }
 
 func (ms *MemoryStore) Store(event domain.PriceUpdateEvent) {
+	defer metrics.ObserveStoreOperation("memory", "store", time.Now())
+	defer traceOperation(tracing.Extract(event.TraceParent), "memory", "store")()
+
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
+	// Timestamps identify events, so like the unique index in MongoDB only
+	// one event is kept per second
+	if ms.size > 0 && event.Timestamp <= ms.events[(ms.nextIndex-1+ms.capacity)%ms.capacity].Timestamp {
+		return
+	}
+
+	// Trace context only describes the live pipeline and is not kept
+	event.TraceParent = ""
 	ms.events[ms.nextIndex] = event
 
 	ms.nextIndex = (ms.nextIndex + 1) % ms.capacity
@@ -35,6 +75,9 @@ func (ms *MemoryStore) Store(event domain.PriceUpdateEvent) {
 }
 
 func (ms *MemoryStore) GetEventsSince(timestamp int64) []domain.PriceUpdateEvent {
+	defer metrics.ObserveStoreOperation("memory", "get_events_since", time.Now())
+	defer traceOperation(context.Background(), "memory", "get_events_since")()
+
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
@@ -60,6 +103,9 @@ func (ms *MemoryStore) GetEventsSince(timestamp int64) []domain.PriceUpdateEvent
 }
 
 func (ms *MemoryStore) GetLatestEvent() (domain.PriceUpdateEvent, bool) {
+	defer metrics.ObserveStoreOperation("memory", "get_latest_event", time.Now())
+	defer traceOperation(context.Background(), "memory", "get_latest_event")()
+
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
@@ -74,3 +120,104 @@ func (ms *MemoryStore) GetLatestEvent() (domain.PriceUpdateEvent, bool) {
 
 	return ms.events[latestIdx], true
 }
+
+// DeleteEventsBefore drops the oldest events with a timestamp before the given one
+func (ms *MemoryStore) DeleteEventsBefore(timestamp int64) int {
+	defer metrics.ObserveStoreOperation("memory", "delete_events_before", time.Now())
+	defer traceOperation(context.Background(), "memory", "delete_events_before")()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	startIdx := ms.nextIndex - ms.size
+	if startIdx < 0 {
+		startIdx += ms.capacity
+	}
+
+	deleted := 0
+	for deleted < ms.size {
+		idx := (startIdx + deleted) % ms.capacity
+		if ms.events[idx].Timestamp >= timestamp {
+			break
+		}
+		ms.events[idx] = domain.PriceUpdateEvent{}
+		deleted++
+	}
+
+	ms.size -= deleted
+	return deleted
+}
+
+// InsertEvents merges events into the buffer in timestamp order. Existing
+// events win over inserted ones with the same timestamp, and only the most
+// recent events up to capacity are kept.
+func (ms *MemoryStore) InsertEvents(events []domain.PriceUpdateEvent) error {
+	defer metrics.ObserveStoreOperation("memory", "insert_events", time.Now())
+	defer traceOperation(context.Background(), "memory", "insert_events")()
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	existing := make([]domain.PriceUpdateEvent, 0, ms.size)
+	startIdx := ms.nextIndex - ms.size
+	if startIdx < 0 {
+		startIdx += ms.capacity
+	}
+	for i := 0; i < ms.size; i++ {
+		existing = append(existing, ms.events[(startIdx+i)%ms.capacity])
+	}
+
+	inserted := append([]domain.PriceUpdateEvent(nil), events...)
+	sort.SliceStable(inserted, func(i, j int) bool {
+		return inserted[i].Timestamp < inserted[j].Timestamp
+	})
+	if len(inserted) > 0 && (!ms.hasLateWrites || inserted[0].Timestamp < ms.lateFrom) {
+		ms.lateFrom = inserted[0].Timestamp
+		ms.hasLateWrites = true
+	}
+
+	merged := make([]domain.PriceUpdateEvent, 0, len(existing)+len(inserted))
+	i, j := 0, 0
+	for i < len(existing) || j < len(inserted) {
+		switch {
+		case j == len(inserted):
+			merged = append(merged, existing[i])
+			i++
+		case i == len(existing):
+			merged = append(merged, inserted[j])
+			j++
+		case existing[i].Timestamp < inserted[j].Timestamp:
+			merged = append(merged, existing[i])
+			i++
+		case existing[i].Timestamp > inserted[j].Timestamp:
+			merged = append(merged, inserted[j])
+			j++
+		default:
+			// Same timestamp: keep the live event
+			j++
+		}
+	}
+
+	if len(merged) > ms.capacity {
+		merged = merged[len(merged)-ms.capacity:]
+	}
+
+	ms.events = make([]domain.PriceUpdateEvent, ms.capacity)
+	copy(ms.events, merged)
+	ms.size = len(merged)
+	ms.nextIndex = ms.size % ms.capacity
+	return nil
+}
+
+// IterateEvents calls fn for every event between from and to, inclusive
+func (ms *MemoryStore) IterateEvents(from, to int64, fn func(domain.PriceUpdateEvent) error) error {
+	for _, event := range ms.GetEventsSince(from) {
+		if event.Timestamp > to {
+			break
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,8 +1,9 @@
 package store
 
 import (
-	"btc-price-tracker/internal/domain"
 	"testing"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
 )
 
 func TestMemoryStore_Store(t *testing.T) {
@@ -10,10 +11,10 @@ func TestMemoryStore_Store(t *testing.T) {
 	store := NewMemoryStore(3)
 
 	// Create test events
-	event1 := domain.PriceUpdateEvent{Timestamp: 100, Price: 50000.0}
-	event2 := domain.PriceUpdateEvent{Timestamp: 101, Price: 51000.0}
-	event3 := domain.PriceUpdateEvent{Timestamp: 102, Price: 52000.0}
-	event4 := domain.PriceUpdateEvent{Timestamp: 103, Price: 53000.0}
+	event1 := domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(50000.0)}
+	event2 := domain.PriceUpdateEvent{Timestamp: 101, Price: domain.NewPrice(51000.0)}
+	event3 := domain.PriceUpdateEvent{Timestamp: 102, Price: domain.NewPrice(52000.0)}
+	event4 := domain.PriceUpdateEvent{Timestamp: 103, Price: domain.NewPrice(53000.0)}
 
 	// Store events
 	store.Store(event1)
@@ -45,15 +46,27 @@ func TestMemoryStore_Store(t *testing.T) {
 	}
 }
 
+func TestMemoryStore_OneEventPerSecond(t *testing.T) {
+	store := NewMemoryStore(3)
+	store.Store(domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(1)})
+	store.Store(domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(2)})
+	store.Store(domain.PriceUpdateEvent{Timestamp: 99, Price: domain.NewPrice(3)})
+
+	events := store.GetEventsSince(0)
+	if len(events) != 1 || events[0].Price.String() != "1" {
+		t.Errorf("Expected only the first event of the second, got %+v", events)
+	}
+}
+
 func TestMemoryStore_GetEventsSince(t *testing.T) {
 	store := NewMemoryStore(5)
 
 	// Add events with different timestamps
 	events := []domain.PriceUpdateEvent{
-		{Timestamp: 100, Price: 50000.0},
-		{Timestamp: 200, Price: 51000.0},
-		{Timestamp: 300, Price: 52000.0},
-		{Timestamp: 400, Price: 53000.0},
+		{Timestamp: 100, Price: domain.NewPrice(50000.0)},
+		{Timestamp: 200, Price: domain.NewPrice(51000.0)},
+		{Timestamp: 300, Price: domain.NewPrice(52000.0)},
+		{Timestamp: 400, Price: domain.NewPrice(53000.0)},
 	}
 
 	for _, e := range events {
@@ -92,7 +105,7 @@ func TestMemoryStore_GetLatestEvent(t *testing.T) {
 	}
 
 	// Add an event
-	event := domain.PriceUpdateEvent{Timestamp: 100, Price: 50000.0}
+	event := domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(50000.0)}
 	store.Store(event)
 
 	// Get latest event
@@ -100,12 +113,12 @@ func TestMemoryStore_GetLatestEvent(t *testing.T) {
 	if !exists {
 		t.Fatal("Expected latest event to exist")
 	}
-	if latest.Timestamp != 100 || latest.Price != 50000.0 {
-		t.Errorf("Expected event {100, 50000.0}, got {%d, %.2f}", latest.Timestamp, latest.Price)
+	if latest.Timestamp != 100 || latest.Price.String() != "50000" {
+		t.Errorf("Expected event {100, 50000.0}, got {%d, %s}", latest.Timestamp, latest.Price)
 	}
 
 	// Add another event
-	event2 := domain.PriceUpdateEvent{Timestamp: 200, Price: 51000.0}
+	event2 := domain.PriceUpdateEvent{Timestamp: 200, Price: domain.NewPrice(51000.0)}
 	store.Store(event2)
 
 	// Get latest event again
@@ -113,7 +126,7 @@ func TestMemoryStore_GetLatestEvent(t *testing.T) {
 	if !exists {
 		t.Fatal("Expected latest event to exist")
 	}
-	if latest.Timestamp != 200 || latest.Price != 51000.0 {
-		t.Errorf("Expected event {200, 51000.0}, got {%d, %.2f}", latest.Timestamp, latest.Price)
+	if latest.Timestamp != 200 || latest.Price.String() != "51000" {
+		t.Errorf("Expected event {200, 51000.0}, got {%d, %s}", latest.Timestamp, latest.Price)
 	}
 }
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"sort"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// GetWebhooks returns all webhooks ordered by ID
+func (ms *MemoryStore) GetWebhooks() []domain.Webhook {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	webhooks := make([]domain.Webhook, 0, len(ms.webhooks))
+	for _, webhook := range ms.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks
+}
+
+// SaveWebhook creates or replaces the webhook with the same ID
+func (ms *MemoryStore) SaveWebhook(webhook domain.Webhook) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.webhooks[webhook.ID] = webhook
+}
+
+// DeleteWebhook removes a webhook and reports whether it existed
+func (ms *MemoryStore) DeleteWebhook(id string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	_, exists := ms.webhooks[id]
+	delete(ms.webhooks, id)
+	return exists
+}
+
+// GetDeadLetters returns all dead letters, oldest first
+func (ms *MemoryStore) GetDeadLetters() []domain.DeadLetter {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	letters := make([]domain.DeadLetter, 0, len(ms.deadLetters))
+	for _, letter := range ms.deadLetters {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		if letters[i].FailedAt != letters[j].FailedAt {
+			return letters[i].FailedAt < letters[j].FailedAt
+		}
+		return letters[i].ID < letters[j].ID
+	})
+	return letters
+}
+
+// SaveDeadLetter creates or replaces the dead letter with the same ID
+func (ms *MemoryStore) SaveDeadLetter(letter domain.DeadLetter) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.deadLetters[letter.ID] = letter
+}
+
+// DeleteDeadLetter removes a dead letter and reports whether it existed
+func (ms *MemoryStore) DeleteDeadLetter(id string) bool {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	_, exists := ms.deadLetters[id]
+	delete(ms.deadLetters, id)
+	return exists
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+)
+
+// MongoDBAlertRule is the MongoDB document structure for alert rules
+type MongoDBAlertRule struct {
+	ID         string               `bson:"_id"`
+	Owner      string               `bson:"owner"`
+	Source     string               `bson:"source,omitempty"`
+	Type       domain.AlertRuleType `bson:"type"`
+	Threshold  float64              `bson:"threshold"`
+	Percent    float64              `bson:"percent"`
+	Window     int64                `bson:"window"`
+	Hysteresis float64              `bson:"hysteresis"`
+	Cooldown   int64                `bson:"cooldown"`
+}
+
+// MongoDBAlertState is the MongoDB document structure for alert rule state
+type MongoDBAlertState struct {
+	RuleID    string `bson:"_id"`
+	Armed     bool   `bson:"armed"`
+	Side      int    `bson:"side"`
+	LastFired int64  `bson:"lastFired"`
+}
+
+// GetAlertRules returns all alert rules ordered by ID
+func (ms *MongoDBStore) GetAlertRules() []domain.AlertRule {
+	defer metrics.ObserveStoreOperation("mongodb", "get_alert_rules", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "get_alert_rules")()
+
+	var rules []domain.AlertRule
+	err := findAll(ms.alertRules, func(doc MongoDBAlertRule) {
+		rules = append(rules, domain.AlertRule(doc))
+	})
+	if err != nil {
+		slog.Error("Error getting alert rules from MongoDB", "error", err)
+	}
+	return rules
+}
+
+// SaveAlertRule creates or replaces the rule with the same ID
+func (ms *MongoDBStore) SaveAlertRule(rule domain.AlertRule) {
+	defer metrics.ObserveStoreOperation("mongodb", "save_alert_rule", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "save_alert_rule")()
+
+	if err := replaceByID(ms.alertRules, rule.ID, MongoDBAlertRule(rule)); err != nil {
+		slog.Error("Error saving alert rule in MongoDB", "rule", rule.ID, "error", err)
+	}
+}
+
+// DeleteAlertRule removes a rule and reports whether it existed
+func (ms *MongoDBStore) DeleteAlertRule(id string) bool {
+	return deleteByID(ms.alertRules, id)
+}
+
+// GetAlertStates returns the evaluation state of every rule
+func (ms *MongoDBStore) GetAlertStates() []domain.AlertState {
+	defer metrics.ObserveStoreOperation("mongodb", "get_alert_states", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "get_alert_states")()
+
+	var states []domain.AlertState
+	err := findAll(ms.alertStates, func(doc MongoDBAlertState) {
+		states = append(states, domain.AlertState(doc))
+	})
+	if err != nil {
+		slog.Error("Error getting alert states from MongoDB", "error", err)
+	}
+	return states
+}
+
+// SaveAlertState creates or replaces the state of a rule
+func (ms *MongoDBStore) SaveAlertState(state domain.AlertState) {
+	defer metrics.ObserveStoreOperation("mongodb", "save_alert_state", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "save_alert_state")()
+
+	if err := replaceByID(ms.alertStates, state.RuleID, MongoDBAlertState(state)); err != nil {
+		slog.Error("Error saving alert state in MongoDB", "rule", state.RuleID, "error", err)
+	}
+}
+
+// DeleteAlertState forgets the evaluation state of a rule
+func (ms *MongoDBStore) DeleteAlertState(ruleID string) {
+	deleteByID(ms.alertStates, ruleID)
+}
+
+// findAll decodes every document in collection, ordered by _id
+func findAll[T any](collection *mongo.Collection, fn func(T)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc T
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		fn(doc)
+	}
+	return cursor.Err()
+}
+
+func replaceByID(collection *mongo.Collection, id string, doc any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := collection.ReplaceOne(ctx, bson.M{"_id": id}, doc, options.Replace().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+)
+
+// MongoDBAPIKey is the MongoDB document structure for API keys
+type MongoDBAPIKey struct {
+	ID             string `bson:"_id"`
+	Owner          string `bson:"owner"`
+	Prefix         string `bson:"prefix"`
+	Hash           string `bson:"hash"`
+	Admin          bool   `bson:"admin"`
+	MaxConnections int    `bson:"maxConnections"`
+	DailyQuota     int64  `bson:"dailyQuota"`
+	CreatedAt      int64  `bson:"createdAt"`
+	RevokedAt      int64  `bson:"revokedAt,omitempty"`
+}
+
+// MongoDBAPIKeyUsage is the MongoDB document structure for the usage of a
+// key on one day
+type MongoDBAPIKeyUsage struct {
+	ID         string `bson:"_id"`
+	KeyID      string `bson:"keyId"`
+	Day        string `bson:"day"`
+	Requests   int64  `bson:"requests"`
+	Rejected   int64  `bson:"rejected"`
+	LastUsedAt int64  `bson:"lastUsedAt"`
+}
+
+// GetAPIKeys returns all API keys, including revoked ones, ordered by ID
+func (ms *MongoDBStore) GetAPIKeys() []domain.APIKey {
+	defer metrics.ObserveStoreOperation("mongodb", "get_api_keys", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "get_api_keys")()
+
+	var keys []domain.APIKey
+	err := findAll(ms.apiKeys, func(doc MongoDBAPIKey) {
+		keys = append(keys, domain.APIKey(doc))
+	})
+	if err != nil {
+		slog.Error("Error getting API keys from MongoDB", "error", err)
+	}
+	return keys
+}
+
+// SaveAPIKey creates or replaces the API key with the same ID
+func (ms *MongoDBStore) SaveAPIKey(key domain.APIKey) {
+	defer metrics.ObserveStoreOperation("mongodb", "save_api_key", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "save_api_key")()
+
+	if err := replaceByID(ms.apiKeys, key.ID, MongoDBAPIKey(key)); err != nil {
+		slog.Error("Error saving API key in MongoDB", "key", key.ID, "error", err)
+	}
+}
+
+// GetAPIKeyUsage returns the usage of one key, oldest day first
+func (ms *MongoDBStore) GetAPIKeyUsage(keyID string) []domain.APIKeyUsage {
+	defer metrics.ObserveStoreOperation("mongodb", "get_api_key_usage", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "get_api_key_usage")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "day", Value: 1}})
+	cursor, err := ms.apiKeyUsage.Find(ctx, bson.M{"keyId": keyID}, opts)
+	if err != nil {
+		slog.Error("Error getting API key usage from MongoDB", "key", keyID, "error", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var usage []domain.APIKeyUsage
+	for cursor.Next(ctx) {
+		var doc MongoDBAPIKeyUsage
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		usage = append(usage, domain.APIKeyUsage{
+			KeyID:      doc.KeyID,
+			Day:        doc.Day,
+			Requests:   doc.Requests,
+			Rejected:   doc.Rejected,
+			LastUsedAt: doc.LastUsedAt,
+		})
+	}
+	return usage
+}
+
+// AddAPIKeyUsage adds usage to the counts of its key and day with a single
+// upsert, so concurrent servers do not overwrite each other's counts
+func (ms *MongoDBStore) AddAPIKeyUsage(usage domain.APIKeyUsage) {
+	defer metrics.ObserveStoreOperation("mongodb", "add_api_key_usage", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "add_api_key_usage")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$inc":         bson.M{"requests": usage.Requests, "rejected": usage.Rejected},
+		"$max":         bson.M{"lastUsedAt": usage.LastUsedAt},
+		"$setOnInsert": bson.M{"keyId": usage.KeyID, "day": usage.Day},
+	}
+	_, err := ms.apiKeyUsage.UpdateOne(ctx, bson.M{"_id": usageID(usage)}, update, options.Update().SetUpsert(true))
+	if err != nil {
+		slog.Error("Error adding API key usage in MongoDB", "key", usage.KeyID, "error", err)
+	}
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+var priceType = reflect.TypeOf(domain.Price{})
+
+// newRegistry returns the default BSON registry with prices stored as
+// Decimal128. Documents written before prices were decimals hold doubles,
+// which are still read.
+func newRegistry() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	registry.RegisterTypeEncoder(priceType, bsoncodec.ValueEncoderFunc(encodePrice))
+	registry.RegisterTypeDecoder(priceType, bsoncodec.ValueDecoderFunc(decodePrice))
+	return registry
+}
+
+func encodePrice(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+	price := val.Interface().(domain.Price)
+
+	value, err := primitive.ParseDecimal128(price.String())
+	if err != nil {
+		return fmt.Errorf("price %s does not fit Decimal128: %w", price, err)
+	}
+	return vw.WriteDecimal128(value)
+}
+
+func decodePrice(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+	var price domain.Price
+
+	switch vr.Type() {
+	case bsontype.Decimal128:
+		value, err := vr.ReadDecimal128()
+		if err != nil {
+			return err
+		}
+		if price, err = domain.ParsePrice(value.String()); err != nil {
+			return err
+		}
+	case bsontype.Double:
+		value, err := vr.ReadDouble()
+		if err != nil {
+			return err
+		}
+		price = domain.NewPrice(value)
+	case bsontype.Int32:
+		value, err := vr.ReadInt32()
+		if err != nil {
+			return err
+		}
+		price = domain.NewPrice(float64(value))
+	case bsontype.Int64:
+		value, err := vr.ReadInt64()
+		if err != nil {
+			return err
+		}
+		price = domain.NewPrice(float64(value))
+	case bsontype.String:
+		value, err := vr.ReadString()
+		if err != nil {
+			return err
+		}
+		if price, err = domain.ParsePrice(value); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot decode BSON %v into a price", vr.Type())
+	}
+
+	val.Set(reflect.ValueOf(price))
+	return nil
+}
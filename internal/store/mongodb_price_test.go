@@ -0,0 +1,91 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+func TestPriceCodec_Decimal128(t *testing.T) {
+	price, err := domain.ParsePrice("69420.12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := bson.MarshalWithRegistry(newRegistry(), MongoDBPriceEvent{Timestamp: 100, Price: price})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if kind := bson.Raw(data).Lookup("price").Type; kind != bsontype.Decimal128 {
+		t.Errorf("Expected price stored as Decimal128, got %v", kind)
+	}
+
+	var decoded MongoDBPriceEvent
+	if err := bson.UnmarshalWithRegistry(newRegistry(), data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Price.String() != "69420.12345678" {
+		t.Errorf("Expected 69420.12345678, got %s", decoded.Price)
+	}
+}
+
+func TestPriceCodec_LegacyDouble(t *testing.T) {
+	data, err := bson.Marshal(bson.M{"timestamp": int64(100), "price": 50000.12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded MongoDBPriceEvent
+	if err := bson.UnmarshalWithRegistry(newRegistry(), data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Timestamp != 100 || decoded.Price.String() != "50000.12" {
+		t.Errorf("Unexpected event: %+v", decoded)
+	}
+}
+
+func TestToDocument_ExpiresAt(t *testing.T) {
+	event := domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(50000)}
+
+	expiring := (&MongoDBStore{ttl: time.Hour}).toDocument(event)
+	if time.Until(expiring.ExpiresAt) < 59*time.Minute {
+		t.Errorf("Expected the document to expire in an hour, got %v", expiring.ExpiresAt)
+	}
+
+	data, err := bson.MarshalWithRegistry(newRegistry(), (&MongoDBStore{}).toDocument(event))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bson.Raw(data).LookupErr("expiresAt"); err == nil {
+		t.Error("Expected no expiresAt field without a TTL")
+	}
+}
+
+func TestOnlyDuplicateKeys(t *testing.T) {
+	duplicate := mongo.WriteError{Code: duplicateKeyCode}
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"No error", nil, false},
+		{"Duplicates", mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: duplicate}, {WriteError: duplicate}}}, true},
+		{"Other write error", mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: duplicate}, {WriteError: mongo.WriteError{Code: 121}}}}, false},
+		{"Write concern", mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{{WriteError: duplicate}}, WriteConcernError: &mongo.WriteConcernError{Code: 64}}, false},
+		{"Other error", errors.New("connection refused"), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := onlyDuplicateKeys(tc.err); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
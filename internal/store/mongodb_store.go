@@ -1,34 +1,70 @@
 package store
 
 import (
-	"btc-price-tracker/internal/domain"
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+	"github.com/tstojkovski/btc-price-tracker/internal/tracing"
 )
 
-// MongoDBStore implements EventStore using MongoDB with TTL
+// MongoDBStore implements EventStore using MongoDB with TTL. Writes go
+// through an asynchronous batch writer so a slow or unavailable MongoDB
+// never delays the price pipeline.
 type MongoDBStore struct {
 	client     *mongo.Client
 	collection *mongo.Collection
-	ttl        time.Duration
+	candles    *mongo.Collection
+	// compaction holds the earliest out-of-order insert for the compactor,
+	// so imports from another process are compacted too
+	compaction *mongo.Collection
+	// Alert rules and their evaluation state are kept without TTL
+	alertRules  *mongo.Collection
+	alertStates *mongo.Collection
+	webhooks    *mongo.Collection
+	deadLetters *mongo.Collection
+	apiKeys     *mongo.Collection
+	apiKeyUsage *mongo.Collection
+	ttl         time.Duration
+	writer      *batchWriter
 }
 
 // MongoDBPriceEvent is the MongoDB document structure
 type MongoDBPriceEvent struct {
-	Timestamp int64     `bson:"timestamp"`
-	Price     float64   `bson:"price"`
-	ExpiresAt time.Time `bson:"expiresAt"` // TTL field
+	Timestamp  int64        `bson:"timestamp"`
+	Price      domain.Price `bson:"price"`
+	Backfilled bool         `bson:"backfilled,omitempty"`
+	// ExpiresAt is the TTL field, left out when documents never expire
+	ExpiresAt time.Time `bson:"expiresAt,omitempty"`
+	// TraceParent links the batch insert back to the poll that produced the event
+	TraceParent string `bson:"-" json:"-"`
+}
+
+// MongoDBCandle is the MongoDB document structure for downsampled candles
+type MongoDBCandle struct {
+	Timestamp  int64        `bson:"timestamp"`
+	Resolution int64        `bson:"resolution"`
+	Open       domain.Price `bson:"open"`
+	High       domain.Price `bson:"high"`
+	Low        domain.Price `bson:"low"`
+	Close      domain.Price `bson:"close"`
+	Count      int64        `bson:"count"`
 }
 
 // NewMongoDBStore creates a new MongoDB-backed event store
-func NewMongoDBStore(uri string, dbName string, collectionName string, ttl time.Duration) (*MongoDBStore, error) {
+func NewMongoDBStore(uri string, dbName string, collectionName string, ttl time.Duration, writerConfig WriterConfig) (*MongoDBStore, error) {
 	// Set client options
-	clientOptions := options.Client().ApplyURI(uri)
+	clientOptions := options.Client().ApplyURI(uri).SetRegistry(newRegistry())
 
 	// Connect to MongoDB
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -59,50 +95,328 @@ func NewMongoDBStore(uri string, dbName string, collectionName string, ttl time.
 		return nil, err
 	}
 
-	// Create timestamp index for efficient querying
-	timestampIndex := mongo.IndexModel{
-		Keys: bson.D{{Key: "timestamp", Value: 1}},
+	// Create a unique timestamp index for efficient querying. Timestamps
+	// double as stream event IDs, so at most one event is kept per second.
+	if err := ensureUniqueTimestamps(collection); err != nil {
+		return nil, err
+	}
+
+	// Candles live in a sibling collection, one document per resolution and bucket
+	candles := client.Database(dbName).Collection(collectionName + "_candles")
+	candleIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "resolution", Value: 1}, {Key: "timestamp", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err = candles.Indexes().CreateOne(ctx, candleIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Usage is looked up per key when listing a key's history
+	apiKeyUsage := client.Database(dbName).Collection(collectionName + "_api_key_usage")
+	usageIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "keyId", Value: 1}, {Key: "day", Value: 1}},
 	}
 
-	_, err = collection.Indexes().CreateOne(ctx, timestampIndex)
+	_, err = apiKeyUsage.Indexes().CreateOne(ctx, usageIndex)
 	if err != nil {
 		return nil, err
 	}
 
-	return &MongoDBStore{
-		client:     client,
-		collection: collection,
-		ttl:        ttl,
-	}, nil
+	ms := &MongoDBStore{
+		client:      client,
+		collection:  collection,
+		candles:     candles,
+		compaction:  client.Database(dbName).Collection(collectionName + "_compaction"),
+		alertRules:  client.Database(dbName).Collection(collectionName + "_alert_rules"),
+		alertStates: client.Database(dbName).Collection(collectionName + "_alert_states"),
+		webhooks:    client.Database(dbName).Collection(collectionName + "_webhooks"),
+		deadLetters: client.Database(dbName).Collection(collectionName + "_dead_letters"),
+		apiKeys:     client.Database(dbName).Collection(collectionName + "_api_keys"),
+		apiKeyUsage: apiKeyUsage,
+		ttl:         ttl,
+	}
+	ms.writer = newBatchWriter(writerConfig, ms.insertMany)
+	metrics.SetMongoWriterSource(func() metrics.MongoWriterStats {
+		stats := ms.writer.Stats()
+		return metrics.MongoWriterStats{
+			QueueDepth:    float64(stats.QueueDepth),
+			WriteFailures: float64(stats.WriteFailures),
+			Dropped:       float64(stats.Dropped),
+			Spilled:       float64(stats.Spilled),
+		}
+	})
+
+	return ms, nil
 }
 
+// Start runs the asynchronous write pipeline until ctx is cancelled
+func (ms *MongoDBStore) Start(ctx context.Context) {
+	ms.writer.Start(ctx)
+}
+
+// Close waits for queued writes to be flushed and disconnects from MongoDB.
+// The context passed to Start must be cancelled first.
 func (ms *MongoDBStore) Close() error {
+	ms.writer.Close()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	return ms.client.Disconnect(ctx)
 }
 
-// Store saves a price update event to MongoDB
+// Ping checks that MongoDB is reachable
+func (ms *MongoDBStore) Ping(ctx context.Context) error {
+	return ms.client.Ping(ctx, nil)
+}
+
+// WriterStats reports the state of the write pipeline
+func (ms *MongoDBStore) WriterStats() WriterStats {
+	return ms.writer.Stats()
+}
+
+// Store queues a price update event to be written to MongoDB
 func (ms *MongoDBStore) Store(event domain.PriceUpdateEvent) {
+	defer traceOperation(tracing.Extract(event.TraceParent), "mongodb", "enqueue")()
+
 	// Convert domain event to MongoDB document
-	doc := MongoDBPriceEvent{
-		Timestamp: event.Timestamp,
-		Price:     event.Price,
-		ExpiresAt: time.Now().Add(ms.ttl), // TTL field
+	ms.writer.Enqueue(ms.toDocument(event))
+}
+
+// InsertEvents synchronously writes backfilled events, bypassing the write
+// queue. It stops at the first batch that fails to be written.
+func (ms *MongoDBStore) InsertEvents(events []domain.PriceUpdateEvent) error {
+	batchSize := ms.writer.config.BatchSize
+
+	written := 0
+	defer func() {
+		if written > 0 {
+			ms.recordLateWrites(events[:written])
+		}
+	}()
+
+	for start := 0; start < len(events); start += batchSize {
+		end := min(start+batchSize, len(events))
+
+		docs := make([]MongoDBPriceEvent, 0, end-start)
+		for _, event := range events[start:end] {
+			docs = append(docs, ms.toDocument(event))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := ms.insertMany(ctx, docs)
+		cancel()
+		// A failed batch may still be partly written
+		written = end
+		if err != nil {
+			return fmt.Errorf("inserting events: %w", err)
+		}
+	}
+	return nil
+}
+
+// lateWritesID is the compaction document holding the earliest late write
+const lateWritesID = "late_writes"
+
+// recordLateWrites lowers the stored earliest late write to the oldest of events
+func (ms *MongoDBStore) recordLateWrites(events []domain.PriceUpdateEvent) {
+	from := events[0].Timestamp
+	for _, event := range events[1:] {
+		from = min(from, event.Timestamp)
 	}
 
-	// Insert document
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := ms.collection.InsertOne(ctx, doc)
+	_, err := ms.compaction.UpdateOne(ctx, bson.M{"_id": lateWritesID},
+		bson.M{"$min": bson.M{"from": from}}, options.Update().SetUpsert(true))
+	if err != nil {
+		slog.Error("Error recording late writes for compaction", "error", err)
+	}
+}
+
+// TakeLateWrites returns and clears the earliest timestamp inserted by
+// InsertEvents since the last call, including from other processes
+func (ms *MongoDBStore) TakeLateWrites() (int64, bool) {
+	defer metrics.ObserveStoreOperation("mongodb", "take_late_writes", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var doc struct {
+		From int64 `bson:"from"`
+	}
+	err := ms.compaction.FindOneAndDelete(ctx, bson.M{"_id": lateWritesID}).Decode(&doc)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			slog.Error("Error reading late writes for compaction", "error", err)
+		}
+		return 0, false
+	}
+	return doc.From, true
+}
+
+func (ms *MongoDBStore) toDocument(event domain.PriceUpdateEvent) MongoDBPriceEvent {
+	doc := MongoDBPriceEvent{
+		Timestamp:   event.Timestamp,
+		Price:       event.Price,
+		Backfilled:  event.Backfilled,
+		TraceParent: event.TraceParent,
+	}
+	if ms.ttl > 0 {
+		doc.ExpiresAt = time.Now().Add(ms.ttl)
+	}
+	return doc
+}
+
+func (doc MongoDBPriceEvent) toEvent() domain.PriceUpdateEvent {
+	return domain.PriceUpdateEvent{
+		Timestamp:  doc.Timestamp,
+		Price:      doc.Price,
+		Backfilled: doc.Backfilled,
+	}
+}
+
+func (ms *MongoDBStore) insertMany(ctx context.Context, docs []MongoDBPriceEvent) (err error) {
+	defer metrics.ObserveStoreOperation("mongodb", "insert_many", time.Now())
+
+	// A batch holds events from many polls, so it links to each of them
+	// rather than picking one parent
+	links := make([]trace.Link, 0, len(docs))
+	for _, doc := range docs {
+		if link, ok := tracing.Link(doc.TraceParent); ok {
+			links = append(links, link)
+		}
+	}
+	ctx, span := startOperation(ctx, "mongodb", "insert_many",
+		trace.WithLinks(links...),
+		trace.WithAttributes(attribute.Int("batch_size", len(docs))),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	batch := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		batch[i] = doc
+	}
+
+	// Unordered so one bad document doesn't block the rest of the batch.
+	// Events for a second that is already stored are refused by the unique
+	// timestamp index, which is not a failure.
+	_, err = ms.collection.InsertMany(ctx, batch, options.InsertMany().SetOrdered(false))
+	if onlyDuplicateKeys(err) {
+		return nil
+	}
+	return err
+}
+
+// duplicateKeyCode is the MongoDB error code for a unique index violation
+const duplicateKeyCode = 11000
+
+// onlyDuplicateKeys reports whether every write in a failed insert was
+// refused by a unique index
+func onlyDuplicateKeys(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != duplicateKeyCode {
+			return false
+		}
+	}
+	return true
+}
+
+// timestampIndexName is the name MongoDB gives the timestamp index
+const timestampIndexName = "timestamp_1"
+
+// ensureUniqueTimestamps creates the unique timestamp index. Collections
+// from versions where the index was not unique may hold several events for
+// a second; all but the first are removed before the index is replaced.
+func ensureUniqueTimestamps(collection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing indexes: %w", err)
+	}
+	var indexes []struct {
+		Name   string `bson:"name"`
+		Unique bool   `bson:"unique"`
+	}
+	if err := cursor.All(ctx, &indexes); err != nil {
+		return fmt.Errorf("listing indexes: %w", err)
+	}
+
+	for _, index := range indexes {
+		if index.Name != timestampIndexName {
+			continue
+		}
+		if index.Unique {
+			return nil
+		}
+
+		removed, err := removeDuplicateTimestamps(ctx, collection)
+		if err != nil {
+			return fmt.Errorf("removing duplicate timestamps: %w", err)
+		}
+		if removed > 0 {
+			slog.Warn("Removed events sharing a second with an earlier event", "removed", removed)
+		}
+		if _, err := collection.Indexes().DropOne(ctx, timestampIndexName); err != nil {
+			return fmt.Errorf("dropping timestamp index: %w", err)
+		}
+	}
+
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "timestamp", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// removeDuplicateTimestamps deletes all but the first inserted event of each
+// second and returns how many were deleted
+func removeDuplicateTimestamps(ctx context.Context, collection *mongo.Collection) (int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$timestamp"},
+			{Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
 	if err != nil {
-		log.Printf("Error storing event: %v", err)
+		return 0, err
 	}
+	defer cursor.Close(ctx)
+
+	var removed int64
+	for cursor.Next(ctx) {
+		var group struct {
+			IDs []any `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return removed, err
+		}
+		result, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}})
+		if err != nil {
+			return removed, err
+		}
+		removed += result.DeletedCount
+	}
+	return removed, cursor.Err()
 }
 
 // GetEventsSince retrieves events since the given timestamp
 func (ms *MongoDBStore) GetEventsSince(timestamp int64) []domain.PriceUpdateEvent {
+	defer metrics.ObserveStoreOperation("mongodb", "get_events_since", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "get_events_since")()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -125,14 +439,11 @@ func (ms *MongoDBStore) GetEventsSince(timestamp int64) []domain.PriceUpdateEven
 			continue
 		}
 
-		results = append(results, domain.PriceUpdateEvent{
-			Timestamp: doc.Timestamp,
-			Price:     doc.Price,
-		})
+		results = append(results, doc.toEvent())
 	}
 
 	if err := cursor.Err(); err != nil {
-		log.Printf("Error getting events from MongoDB: %v", err)
+		slog.Error("Error getting events from MongoDB", "error", err)
 	}
 
 	return results
@@ -140,6 +451,9 @@ func (ms *MongoDBStore) GetEventsSince(timestamp int64) []domain.PriceUpdateEven
 
 // GetLatestEvent retrieves the most recent price update event
 func (ms *MongoDBStore) GetLatestEvent() (domain.PriceUpdateEvent, bool) {
+	defer metrics.ObserveStoreOperation("mongodb", "get_latest_event", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "get_latest_event")()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -153,8 +467,140 @@ func (ms *MongoDBStore) GetLatestEvent() (domain.PriceUpdateEvent, bool) {
 		return domain.PriceUpdateEvent{}, false
 	}
 
-	return domain.PriceUpdateEvent{
-		Timestamp: doc.Timestamp,
-		Price:     doc.Price,
-	}, true
+	return doc.toEvent(), true
+}
+
+// DeleteEventsBefore removes raw events older than the given timestamp
+func (ms *MongoDBStore) DeleteEventsBefore(timestamp int64) int {
+	defer metrics.ObserveStoreOperation("mongodb", "delete_events_before", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "delete_events_before")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := ms.collection.DeleteMany(ctx, bson.M{"timestamp": bson.M{"$lt": timestamp}})
+	if err != nil {
+		slog.Error("Error deleting events from MongoDB", "error", err)
+		return 0
+	}
+
+	return int(result.DeletedCount)
+}
+
+// StoreCandles upserts candles, replacing any existing candle for the same bucket
+func (ms *MongoDBStore) StoreCandles(candles []domain.Candle) {
+	defer metrics.ObserveStoreOperation("mongodb", "store_candles", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "store_candles")()
+
+	if len(candles) == 0 {
+		return
+	}
+
+	models := make([]mongo.WriteModel, 0, len(candles))
+	for _, candle := range candles {
+		filter := bson.M{"resolution": candle.Resolution, "timestamp": candle.Timestamp}
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(filter).
+			SetReplacement(MongoDBCandle(candle)).
+			SetUpsert(true))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := ms.candles.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false)); err != nil {
+		slog.Error("Error storing candles in MongoDB", "error", err)
+	}
+}
+
+// GetCandlesSince returns candles of the given resolution starting at or after timestamp
+func (ms *MongoDBStore) GetCandlesSince(resolution int64, timestamp int64) []domain.Candle {
+	defer metrics.ObserveStoreOperation("mongodb", "get_candles_since", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "get_candles_since")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"resolution": resolution, "timestamp": bson.M{"$gte": timestamp}}
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+
+	cursor, err := ms.candles.Find(ctx, filter, opts)
+	if err != nil {
+		return []domain.Candle{}
+	}
+	defer cursor.Close(ctx)
+
+	var results []domain.Candle
+	for cursor.Next(ctx) {
+		var doc MongoDBCandle
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		results = append(results, domain.Candle(doc))
+	}
+
+	if err := cursor.Err(); err != nil {
+		slog.Error("Error getting candles from MongoDB", "error", err)
+	}
+
+	return results
+}
+
+// GetLatestCandle returns the most recent candle of the given resolution
+func (ms *MongoDBStore) GetLatestCandle(resolution int64) (domain.Candle, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}})
+
+	var doc MongoDBCandle
+	if err := ms.candles.FindOne(ctx, bson.M{"resolution": resolution}, opts).Decode(&doc); err != nil {
+		return domain.Candle{}, false
+	}
+
+	return domain.Candle(doc), true
+}
+
+// DeleteCandlesBefore removes candles of the given resolution that start before timestamp
+func (ms *MongoDBStore) DeleteCandlesBefore(resolution int64, timestamp int64) int {
+	defer metrics.ObserveStoreOperation("mongodb", "delete_candles_before", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "delete_candles_before")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"resolution": resolution, "timestamp": bson.M{"$lt": timestamp}}
+	result, err := ms.candles.DeleteMany(ctx, filter)
+	if err != nil {
+		slog.Error("Error deleting candles from MongoDB", "error", err)
+		return 0
+	}
+
+	return int(result.DeletedCount)
+}
+
+// IterateEvents streams events between from and to, inclusive, through a cursor
+func (ms *MongoDBStore) IterateEvents(from, to int64, fn func(domain.PriceUpdateEvent) error) error {
+	ctx := context.Background()
+
+	filter := bson.M{"timestamp": bson.M{"$gte": from, "$lte": to}}
+	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}).SetBatchSize(1000)
+
+	cursor, err := ms.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc MongoDBPriceEvent
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		if err := fn(doc.toEvent()); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
 }
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+)
+
+// MongoDBWebhook is the MongoDB document structure for webhooks
+type MongoDBWebhook struct {
+	ID        string   `bson:"_id"`
+	URL       string   `bson:"url"`
+	Secret    string   `bson:"secret"`
+	Events    []string `bson:"events"`
+	Rules     []string `bson:"rules"`
+	CreatedAt int64    `bson:"createdAt"`
+}
+
+// MongoDBDeadLetter is the MongoDB document structure for failed deliveries
+type MongoDBDeadLetter struct {
+	ID        string `bson:"_id"`
+	WebhookID string `bson:"webhookId"`
+	Event     string `bson:"event"`
+	Payload   string `bson:"payload"`
+	Attempts  int    `bson:"attempts"`
+	LastError string `bson:"lastError"`
+	FailedAt  int64  `bson:"failedAt"`
+}
+
+// GetWebhooks returns all webhooks ordered by ID
+func (ms *MongoDBStore) GetWebhooks() []domain.Webhook {
+	defer metrics.ObserveStoreOperation("mongodb", "get_webhooks", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "get_webhooks")()
+
+	var webhooks []domain.Webhook
+	err := findAll(ms.webhooks, func(doc MongoDBWebhook) {
+		webhooks = append(webhooks, domain.Webhook(doc))
+	})
+	if err != nil {
+		slog.Error("Error getting webhooks from MongoDB", "error", err)
+	}
+	return webhooks
+}
+
+// SaveWebhook creates or replaces the webhook with the same ID
+func (ms *MongoDBStore) SaveWebhook(webhook domain.Webhook) {
+	defer metrics.ObserveStoreOperation("mongodb", "save_webhook", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "save_webhook")()
+
+	if err := replaceByID(ms.webhooks, webhook.ID, MongoDBWebhook(webhook)); err != nil {
+		slog.Error("Error saving webhook in MongoDB", "webhook", webhook.ID, "error", err)
+	}
+}
+
+// DeleteWebhook removes a webhook and reports whether it existed
+func (ms *MongoDBStore) DeleteWebhook(id string) bool {
+	return deleteByID(ms.webhooks, id)
+}
+
+// GetDeadLetters returns all dead letters, oldest first
+func (ms *MongoDBStore) GetDeadLetters() []domain.DeadLetter {
+	defer metrics.ObserveStoreOperation("mongodb", "get_dead_letters", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "get_dead_letters")()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "failedAt", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := ms.deadLetters.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		slog.Error("Error getting dead letters from MongoDB", "error", err)
+		return nil
+	}
+	defer cursor.Close(ctx)
+
+	var letters []domain.DeadLetter
+	for cursor.Next(ctx) {
+		var doc MongoDBDeadLetter
+		if err := cursor.Decode(&doc); err != nil {
+			continue
+		}
+		letters = append(letters, domain.DeadLetter(doc))
+	}
+	return letters
+}
+
+// SaveDeadLetter creates or replaces the dead letter with the same ID
+func (ms *MongoDBStore) SaveDeadLetter(letter domain.DeadLetter) {
+	defer metrics.ObserveStoreOperation("mongodb", "save_dead_letter", time.Now())
+	defer traceOperation(context.Background(), "mongodb", "save_dead_letter")()
+
+	if err := replaceByID(ms.deadLetters, letter.ID, MongoDBDeadLetter(letter)); err != nil {
+		slog.Error("Error saving dead letter in MongoDB", "id", letter.ID, "error", err)
+	}
+}
+
+// DeleteDeadLetter removes a dead letter and reports whether it existed
+func (ms *MongoDBStore) DeleteDeadLetter(id string) bool {
+	return deleteByID(ms.deadLetters, id)
+}
+
+func deleteByID(collection *mongo.Collection, id string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		slog.Error("Error deleting document from MongoDB", "collection", collection.Name(), "id", id, "error", err)
+		return false
+	}
+	return result.DeletedCount > 0
+}
@@ -0,0 +1,366 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WriterConfig controls the asynchronous write pipeline of MongoDBStore
+type WriterConfig struct {
+	QueueSize     int           `yaml:"queue_size"`
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	MaxRetries    int           `yaml:"max_retries"`
+	RetryBackoff  time.Duration `yaml:"retry_backoff"`
+	// SpillPath is an optional file where batches that could not be written
+	// are appended and replayed once MongoDB is reachable again.
+	SpillPath string `yaml:"spill_path"`
+}
+
+// DefaultWriterConfig returns the write pipeline defaults
+func DefaultWriterConfig() WriterConfig {
+	return WriterConfig{
+		QueueSize:     1000,
+		BatchSize:     100,
+		FlushInterval: time.Second,
+		MaxRetries:    3,
+		RetryBackoff:  500 * time.Millisecond,
+	}
+}
+
+// WriterStats is a point-in-time view of the write pipeline
+type WriterStats struct {
+	QueueDepth    int
+	Written       uint64
+	WriteFailures uint64
+	Dropped       uint64
+	Spilled       uint64
+}
+
+type insertManyFunc func(ctx context.Context, docs []MongoDBPriceEvent) error
+
+const (
+	// maxReplayBackoff caps the wait between spill replays while writes fail
+	maxReplayBackoff = time.Minute
+	// spillBlockSize is how much of the spill file is read at a time
+	spillBlockSize = 64 << 10
+)
+
+// batchWriter buffers documents in a bounded queue and writes them in
+// batches, retrying with backoff and spilling to disk when writes keep failing.
+type batchWriter struct {
+	config WriterConfig
+	insert insertManyFunc
+	queue  chan MongoDBPriceEvent
+	done   chan struct{}
+	spill  sync.Mutex
+
+	// replayAfter delays spill replays after a failed write, backing off by
+	// replayBackoff. Both are only used by the run goroutine.
+	replayAfter   time.Time
+	replayBackoff time.Duration
+
+	written       atomic.Uint64
+	writeFailures atomic.Uint64
+	dropped       atomic.Uint64
+	spilled       atomic.Uint64
+}
+
+func newBatchWriter(config WriterConfig, insert insertManyFunc) *batchWriter {
+	return &batchWriter{
+		config: config,
+		insert: insert,
+		queue:  make(chan MongoDBPriceEvent, config.QueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Enqueue adds a document to the write queue without blocking. When the
+// queue is full the document is spilled to disk if configured, or dropped.
+func (bw *batchWriter) Enqueue(doc MongoDBPriceEvent) {
+	select {
+	case bw.queue <- doc:
+	default:
+		slog.Warn("MongoDB write queue full")
+		bw.spillOrDrop([]MongoDBPriceEvent{doc})
+	}
+}
+
+func (bw *batchWriter) Start(ctx context.Context) {
+	go bw.run(ctx)
+}
+
+// Close waits for the writer to drain the queue after its context ends.
+func (bw *batchWriter) Close() {
+	<-bw.done
+}
+
+func (bw *batchWriter) Stats() WriterStats {
+	return WriterStats{
+		QueueDepth:    len(bw.queue),
+		Written:       bw.written.Load(),
+		WriteFailures: bw.writeFailures.Load(),
+		Dropped:       bw.dropped.Load(),
+		Spilled:       bw.spilled.Load(),
+	}
+}
+
+func (bw *batchWriter) run(ctx context.Context) {
+	defer close(bw.done)
+
+	ticker := time.NewTicker(bw.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]MongoDBPriceEvent, 0, bw.config.BatchSize)
+
+	for {
+		select {
+		case doc := <-bw.queue:
+			batch = append(batch, doc)
+			if len(batch) >= bw.config.BatchSize {
+				bw.flush(ctx, batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				bw.flush(ctx, batch)
+				batch = batch[:0]
+			}
+			bw.replaySpill(ctx)
+		case <-ctx.Done():
+			bw.drain(batch)
+			return
+		}
+	}
+}
+
+// drain writes whatever is left in the queue once, without retries, so
+// shutdown is bounded. Anything that still fails is spilled.
+func (bw *batchWriter) drain(batch []MongoDBPriceEvent) {
+loop:
+	for {
+		select {
+		case doc := <-bw.queue:
+			batch = append(batch, doc)
+		default:
+			break loop
+		}
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := bw.insert(ctx, batch); err != nil {
+		bw.writeFailures.Add(1)
+		slog.Error("Error flushing MongoDB batch on shutdown", "count", len(batch), "error", err)
+		bw.spillOrDrop(batch)
+		return
+	}
+	bw.written.Add(uint64(len(batch)))
+}
+
+// flush writes a batch, retrying with exponential backoff. A batch that
+// still fails is spilled and postpones the next spill replay.
+func (bw *batchWriter) flush(ctx context.Context, batch []MongoDBPriceEvent) {
+	if bw.write(ctx, batch) {
+		return
+	}
+	bw.backOffReplay()
+	bw.spillOrDrop(batch)
+}
+
+func (bw *batchWriter) write(ctx context.Context, batch []MongoDBPriceEvent) bool {
+	backoff := bw.config.RetryBackoff
+
+	for attempt := 0; attempt <= bw.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := bw.insert(insertCtx, batch)
+		cancel()
+
+		if err == nil {
+			bw.written.Add(uint64(len(batch)))
+			return true
+		}
+
+		bw.writeFailures.Add(1)
+		slog.Warn("Error writing events to MongoDB", "count", len(batch), "attempt", attempt+1, "error", err)
+	}
+
+	return false
+}
+
+func (bw *batchWriter) spillOrDrop(batch []MongoDBPriceEvent) {
+	if bw.config.SpillPath == "" {
+		bw.dropped.Add(uint64(len(batch)))
+		return
+	}
+
+	if err := bw.appendSpill(batch); err != nil {
+		slog.Error("Error spilling events", "path", bw.config.SpillPath, "count", len(batch), "error", err)
+		bw.dropped.Add(uint64(len(batch)))
+		return
+	}
+	bw.spilled.Add(uint64(len(batch)))
+}
+
+func (bw *batchWriter) appendSpill(batch []MongoDBPriceEvent) error {
+	bw.spill.Lock()
+	defer bw.spill.Unlock()
+
+	return writeSpill(bw.config.SpillPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, batch)
+}
+
+// replaySpill writes spilled events back to MongoDB a batch at a time,
+// unless a recent write failed. Batches are taken from the end of the spill
+// file, which is truncated after each one is written, so progress survives
+// a restart without rewriting the file. The file is removed once empty.
+func (bw *batchWriter) replaySpill(ctx context.Context) {
+	if bw.config.SpillPath == "" || time.Now().Before(bw.replayAfter) {
+		return
+	}
+
+	replayed := 0
+	for ctx.Err() == nil {
+		count, more, err := bw.replayBatch(ctx)
+		replayed += count
+		if err != nil {
+			bw.backOffReplay()
+			slog.Warn("Error replaying spilled events", "path", bw.config.SpillPath, "retry_in", bw.replayBackoff, "error", err)
+			break
+		}
+		if !more {
+			bw.replayAfter = time.Time{}
+			bw.replayBackoff = 0
+			break
+		}
+	}
+
+	if replayed > 0 {
+		slog.Info("Replayed spilled events to MongoDB", "count", replayed)
+	}
+}
+
+// backOffReplay postpones the next spill replay, doubling the wait after
+// each failure up to maxReplayBackoff
+func (bw *batchWriter) backOffReplay() {
+	bw.replayBackoff = min(max(2*bw.replayBackoff, bw.config.FlushInterval), maxReplayBackoff)
+	bw.replayAfter = time.Now().Add(bw.replayBackoff)
+}
+
+// replayBatch writes the last BatchSize events of the spill file and
+// truncates them off. It reports how many were written and whether the file
+// still holds events. The spill lock is held so appends wait for one batch
+// at most.
+func (bw *batchWriter) replayBatch(ctx context.Context) (int, bool, error) {
+	bw.spill.Lock()
+	defer bw.spill.Unlock()
+
+	file, err := os.OpenFile(bw.config.SpillPath, os.O_RDWR, 0) // #nosec G304 -- path comes from operator configuration
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, false, err
+	}
+	start, tail, err := readSpillTail(file, info.Size(), bw.config.BatchSize)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var docs []MongoDBPriceEvent
+	for _, line := range bytes.Split(tail, []byte("\n")) {
+		var doc MongoDBPriceEvent
+		if err := json.Unmarshal(line, &doc); err != nil {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+
+	if len(docs) > 0 {
+		insertCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := bw.insert(insertCtx, docs)
+		cancel()
+		if err != nil {
+			return 0, true, err
+		}
+		bw.written.Add(uint64(len(docs)))
+	}
+
+	if start == 0 {
+		return len(docs), false, os.Remove(bw.config.SpillPath)
+	}
+	return len(docs), true, file.Truncate(start)
+}
+
+// readSpillTail reads the last lines of a spill file of the given size,
+// block by block from the end. It returns the offset the lines start at and
+// their contents.
+func readSpillTail(file *os.File, size int64, lines int) (int64, []byte, error) {
+	var data []byte
+	pos := size
+	for pos > 0 {
+		blockStart := max(0, pos-spillBlockSize)
+		block := make([]byte, pos-blockStart)
+		if _, err := file.ReadAt(block, blockStart); err != nil {
+			return 0, nil, err
+		}
+		data = append(block, data...)
+		pos = blockStart
+
+		// The newline ending the last line does not start another
+		found := 0
+		for i := len(data) - 2; i >= 0; i-- {
+			if data[i] != '\n' {
+				continue
+			}
+			found++
+			if found == lines {
+				return pos + int64(i) + 1, data[i+1:], nil
+			}
+		}
+	}
+	return 0, data, nil
+}
+
+func writeSpill(path string, flag int, docs []MongoDBPriceEvent) error {
+	file, err := os.OpenFile(path, flag, 0o600) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	return file.Close()
+}
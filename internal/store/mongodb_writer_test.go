@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeInserter struct {
+	mu      sync.Mutex
+	fail    bool
+	batches [][]MongoDBPriceEvent
+}
+
+func (f *fakeInserter) insert(_ context.Context, docs []MongoDBPriceEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fail {
+		return errors.New("mongo unavailable")
+	}
+	f.batches = append(f.batches, append([]MongoDBPriceEvent(nil), docs...))
+	return nil
+}
+
+func (f *fakeInserter) setFail(fail bool) {
+	f.mu.Lock()
+	f.fail = fail
+	f.mu.Unlock()
+}
+
+func (f *fakeInserter) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	total := 0
+	for _, batch := range f.batches {
+		total += len(batch)
+	}
+	return total
+}
+
+func testWriterConfig() WriterConfig {
+	return WriterConfig{
+		QueueSize:     10,
+		BatchSize:     3,
+		FlushInterval: 20 * time.Millisecond,
+		MaxRetries:    1,
+		RetryBackoff:  time.Millisecond,
+	}
+}
+
+func TestBatchWriter_BatchesWrites(t *testing.T) {
+	inserter := &fakeInserter{}
+	writer := newBatchWriter(testWriterConfig(), inserter.insert)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	writer.Start(ctx)
+
+	for i := int64(0); i < 7; i++ {
+		writer.Enqueue(MongoDBPriceEvent{Timestamp: i})
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	writer.Close()
+
+	if inserter.count() != 7 {
+		t.Errorf("Expected 7 written events, got %d", inserter.count())
+	}
+	if len(inserter.batches[0]) != 3 {
+		t.Errorf("Expected first batch of 3, got %d", len(inserter.batches[0]))
+	}
+
+	stats := writer.Stats()
+	if stats.Written != 7 || stats.QueueDepth != 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestBatchWriter_DropsWhenQueueFull(t *testing.T) {
+	inserter := &fakeInserter{}
+	config := testWriterConfig()
+	config.QueueSize = 2
+	writer := newBatchWriter(config, inserter.insert)
+
+	// Writer not started, so nothing drains the queue
+	for i := int64(0); i < 5; i++ {
+		writer.Enqueue(MongoDBPriceEvent{Timestamp: i})
+	}
+
+	stats := writer.Stats()
+	if stats.QueueDepth != 2 {
+		t.Errorf("Expected queue depth 2, got %d", stats.QueueDepth)
+	}
+	if stats.Dropped != 3 {
+		t.Errorf("Expected 3 dropped events, got %d", stats.Dropped)
+	}
+}
+
+func TestBatchWriter_SpillsAndReplays(t *testing.T) {
+	inserter := &fakeInserter{fail: true}
+	config := testWriterConfig()
+	config.SpillPath = filepath.Join(t.TempDir(), "spill.jsonl")
+	writer := newBatchWriter(config, inserter.insert)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	writer.Start(ctx)
+
+	for i := int64(0); i < 3; i++ {
+		writer.Enqueue(MongoDBPriceEvent{Timestamp: i})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	stats := writer.Stats()
+	if stats.Spilled != 3 {
+		t.Fatalf("Expected 3 spilled events, got %d", stats.Spilled)
+	}
+	if stats.WriteFailures == 0 {
+		t.Error("Expected write failures to be counted")
+	}
+	if _, err := os.Stat(config.SpillPath); err != nil {
+		t.Fatalf("Expected spill file to exist: %v", err)
+	}
+
+	// Once MongoDB recovers the spill file is replayed and removed, after
+	// the replay backoff
+	inserter.setFail(false)
+	for deadline := time.Now().Add(2 * time.Second); inserter.count() < 3 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if inserter.count() != 3 {
+		t.Errorf("Expected 3 replayed events, got %d", inserter.count())
+	}
+	if _, err := os.Stat(config.SpillPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected spill file to be removed, got %v", err)
+	}
+}
+
+func TestBatchWriter_ReplaysSpillInBatches(t *testing.T) {
+	inserter := &fakeInserter{}
+	config := testWriterConfig()
+	config.SpillPath = filepath.Join(t.TempDir(), "spill.jsonl")
+	writer := newBatchWriter(config, inserter.insert)
+
+	var docs []MongoDBPriceEvent
+	for i := int64(0); i < 7; i++ {
+		docs = append(docs, MongoDBPriceEvent{Timestamp: i})
+	}
+	if err := writer.appendSpill(docs); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first batch written is truncated off before the second one fails
+	calls := 0
+	writer.insert = func(ctx context.Context, batch []MongoDBPriceEvent) error {
+		if calls++; calls > 1 {
+			return errors.New("mongo unavailable")
+		}
+		return inserter.insert(ctx, batch)
+	}
+	writer.replaySpill(context.Background())
+
+	if inserter.count() != 3 || inserter.batches[0][0].Timestamp != 4 {
+		t.Fatalf("Expected the last 3 events to be replayed, got %v", inserter.batches)
+	}
+	data, err := os.ReadFile(config.SpillPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 4 {
+		t.Errorf("Expected 4 events left in the spill file, got %d", lines)
+	}
+
+	// Replays wait out the backoff after a failure
+	writer.insert = inserter.insert
+	writer.replaySpill(context.Background())
+	if inserter.count() != 3 {
+		t.Errorf("Expected no replay during the backoff, got %d events", inserter.count())
+	}
+
+	writer.replayAfter = time.Time{}
+	writer.replaySpill(context.Background())
+	if inserter.count() != 7 {
+		t.Errorf("Expected every event to be replayed, got %d", inserter.count())
+	}
+	if _, err := os.Stat(config.SpillPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected spill file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// Snapshot file layout (all integers big-endian):
+//
+//	magic    [4]byte  "BTCS"
+//	version  uint16
+//	checksum uint32   CRC-32 (IEEE) of payload
+//	length   uint32   payload length in bytes
+//	payload  []byte   JSON snapshotPayload
+//
+// Version 1 payloads are a bare JSON array of events, oldest first, and are
+// still accepted on restore.
+const (
+	snapshotMagic      = "BTCS"
+	snapshotVersion    = 2
+	snapshotHeaderSize = 4 + 2 + 4 + 4
+)
+
+type snapshotPayload struct {
+	Events      []domain.PriceUpdateEvent `json:"events"`
+	AlertRules  []domain.AlertRule        `json:"alert_rules,omitempty"`
+	AlertStates []domain.AlertState       `json:"alert_states,omitempty"`
+	Webhooks    []domain.Webhook          `json:"webhooks,omitempty"`
+	DeadLetters []domain.DeadLetter       `json:"dead_letters,omitempty"`
+	APIKeys     []domain.APIKey           `json:"api_keys,omitempty"`
+	APIKeyUsage []domain.APIKeyUsage      `json:"api_key_usage,omitempty"`
+}
+
+var (
+	ErrSnapshotCorrupt     = errors.New("snapshot is corrupt")
+	ErrSnapshotUnsupported = errors.New("snapshot version is not supported")
+)
+
+// EnableSnapshots configures the store to periodically persist its ring
+// buffer to path. Snapshotting begins once Start is called.
+func (ms *MemoryStore) EnableSnapshots(path string, interval time.Duration) {
+	ms.snapshotPath = path
+	ms.snapshotInterval = interval
+}
+
+// Start runs the periodic snapshot loop until ctx is cancelled.
+func (ms *MemoryStore) Start(ctx context.Context) {
+	if ms.snapshotPath == "" || ms.snapshotInterval <= 0 {
+		return
+	}
+	go ms.snapshotLoop(ctx)
+}
+
+// Close writes a final snapshot if snapshotting is enabled.
+func (ms *MemoryStore) Close() error {
+	if ms.snapshotPath == "" {
+		return nil
+	}
+	return ms.SaveSnapshot(ms.snapshotPath)
+}
+
+func (ms *MemoryStore) snapshotLoop(ctx context.Context) {
+	ticker := time.NewTicker(ms.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ms.SaveSnapshot(ms.snapshotPath); err != nil {
+				slog.Error("Error writing snapshot", "path", ms.snapshotPath, "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// SaveSnapshot atomically writes the current contents of the store to path.
+func (ms *MemoryStore) SaveSnapshot(path string) error {
+	payload, err := json.Marshal(snapshotPayload{
+		Events:      ms.GetEventsSince(0),
+		AlertRules:  ms.GetAlertRules(),
+		AlertStates: ms.GetAlertStates(),
+		Webhooks:    ms.GetWebhooks(),
+		DeadLetters: ms.GetDeadLetters(),
+		APIKeys:     ms.GetAPIKeys(),
+		APIKeyUsage: ms.allAPIKeyUsage(),
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(snapshotVersion))
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(payload))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(payload)))
+	buf.Write(payload)
+
+	// Write to a temporary file first so a crash mid-write never leaves a
+	// truncated snapshot in place.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// RestoreSnapshot loads events from the snapshot at path into the store.
+// A corrupt or unsupported snapshot leaves the store untouched.
+func (ms *MemoryStore) RestoreSnapshot(path string) (int, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path comes from operator configuration
+	if err != nil {
+		return 0, err
+	}
+
+	snapshot, err := decodeSnapshot(data)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range snapshot.Events {
+		ms.Store(event)
+	}
+	for _, rule := range snapshot.AlertRules {
+		ms.SaveAlertRule(rule)
+	}
+	for _, state := range snapshot.AlertStates {
+		ms.SaveAlertState(state)
+	}
+	for _, webhook := range snapshot.Webhooks {
+		ms.SaveWebhook(webhook)
+	}
+	for _, letter := range snapshot.DeadLetters {
+		ms.SaveDeadLetter(letter)
+	}
+	for _, key := range snapshot.APIKeys {
+		ms.SaveAPIKey(key)
+	}
+	for _, usage := range snapshot.APIKeyUsage {
+		ms.AddAPIKeyUsage(usage)
+	}
+
+	return len(snapshot.Events), nil
+}
+
+func decodeSnapshot(data []byte) (snapshotPayload, error) {
+	var snapshot snapshotPayload
+	if len(data) < snapshotHeaderSize || string(data[:4]) != snapshotMagic {
+		return snapshot, ErrSnapshotCorrupt
+	}
+
+	version := binary.BigEndian.Uint16(data[4:6])
+	if version != 1 && version != snapshotVersion {
+		return snapshot, fmt.Errorf("%w: %d", ErrSnapshotUnsupported, version)
+	}
+
+	checksum := binary.BigEndian.Uint32(data[6:10])
+	length := binary.BigEndian.Uint32(data[10:14])
+	payload := data[snapshotHeaderSize:]
+
+	if uint32(len(payload)) != length || crc32.ChecksumIEEE(payload) != checksum {
+		return snapshot, ErrSnapshotCorrupt
+	}
+
+	target := any(&snapshot)
+	if version == 1 {
+		target = &snapshot.Events
+	}
+	if err := json.Unmarshal(payload, target); err != nil {
+		return snapshotPayload{}, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+
+	return snapshot, nil
+}
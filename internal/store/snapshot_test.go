@@ -0,0 +1,176 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+func TestMemoryStore_SnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.bin")
+
+	// Wrap the ring buffer so the snapshot has to preserve ordering
+	original := NewMemoryStore(3)
+	for i := int64(1); i <= 4; i++ {
+		original.Store(domain.PriceUpdateEvent{Timestamp: i * 100, Price: domain.NewPrice(float64(50000 + i))})
+	}
+
+	if err := original.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	restored := NewMemoryStore(3)
+	count, err := restored.RestoreSnapshot(path)
+	if err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 restored events, got %d", count)
+	}
+
+	events := restored.GetEventsSince(0)
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	if events[0].Timestamp != 200 || events[2].Timestamp != 400 {
+		t.Errorf("Expected events 200..400, got %d..%d", events[0].Timestamp, events[2].Timestamp)
+	}
+
+	latest, _ := restored.GetLatestEvent()
+	if latest.Price.String() != "50004" {
+		t.Errorf("Expected latest price 50004, got %s", latest.Price)
+	}
+}
+
+func TestMemoryStore_RestoreCorruptSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.bin")
+
+	original := NewMemoryStore(3)
+	original.Store(domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(50000.0)})
+	if err := original.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"Flipped payload byte", flipLastByte(data), ErrSnapshotCorrupt},
+		{"Truncated payload", data[:len(data)-1], ErrSnapshotCorrupt},
+		{"Truncated header", data[:5], ErrSnapshotCorrupt},
+		{"Unknown version", withVersion(data, 99), ErrSnapshotUnsupported},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.WriteFile(path, tc.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			restored := NewMemoryStore(3)
+			_, err := restored.RestoreSnapshot(path)
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+			if _, exists := restored.GetLatestEvent(); exists {
+				t.Error("Expected store to remain empty after failed restore")
+			}
+		})
+	}
+}
+
+func flipLastByte(data []byte) []byte {
+	out := append([]byte(nil), data...)
+	out[len(out)-1] ^= 0xFF
+	return out
+}
+
+func withVersion(data []byte, version byte) []byte {
+	out := append([]byte(nil), data...)
+	out[4], out[5] = 0, version
+	return out
+}
+
+func TestMemoryStore_SnapshotAlerts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.bin")
+
+	original := NewMemoryStore(3)
+	original.SaveAlertRule(domain.AlertRule{ID: "btc-100k", Type: domain.AlertAbove, Threshold: 100000})
+	original.SaveAlertState(domain.AlertState{RuleID: "btc-100k", LastFired: 1700000000})
+	if err := original.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	restored := NewMemoryStore(3)
+	if _, err := restored.RestoreSnapshot(path); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	rules := restored.GetAlertRules()
+	if len(rules) != 1 || rules[0].Threshold != 100000 {
+		t.Errorf("Expected restored rule, got %+v", rules)
+	}
+	states := restored.GetAlertStates()
+	if len(states) != 1 || states[0].Armed || states[0].LastFired != 1700000000 {
+		t.Errorf("Expected restored state, got %+v", states)
+	}
+}
+
+func TestMemoryStore_SnapshotAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.bin")
+
+	original := NewMemoryStore(3)
+	original.SaveAPIKey(domain.APIKey{ID: "k1", Owner: "alice", Hash: "abc", DailyQuota: 100})
+	original.AddAPIKeyUsage(domain.APIKeyUsage{KeyID: "k1", Day: "2024-04-02", Requests: 7})
+	original.AddAPIKeyUsage(domain.APIKeyUsage{KeyID: "k1", Day: "2024-04-01", Requests: 3})
+	if err := original.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	restored := NewMemoryStore(3)
+	if _, err := restored.RestoreSnapshot(path); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	keys := restored.GetAPIKeys()
+	if len(keys) != 1 || keys[0].Hash != "abc" || keys[0].DailyQuota != 100 {
+		t.Errorf("Expected restored key, got %+v", keys)
+	}
+	usage := restored.GetAPIKeyUsage("k1")
+	if len(usage) != 2 || usage[0].Day != "2024-04-01" || usage[1].Requests != 7 {
+		t.Errorf("Expected restored usage oldest first, got %+v", usage)
+	}
+}
+
+func TestMemoryStore_RestoreVersion1Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.bin")
+
+	payload := []byte(`[{"timestamp":100,"price":50000}]`)
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(1))
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(payload))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(payload)))
+	buf.Write(payload)
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewMemoryStore(3)
+	count, err := restored.RestoreSnapshot(path)
+	if err != nil || count != 1 {
+		t.Fatalf("Expected 1 restored event, got %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/tracing"
+)
+
+// traceOperation starts a span for a store operation and returns the
+// function that ends it. Like the metrics it is meant to be deferred:
+// defer traceOperation(ctx, "memory", "store")()
+func traceOperation(ctx context.Context, store string, operation string, opts ...trace.SpanStartOption) func() {
+	_, span := startOperation(ctx, store, operation, opts...)
+	return func() { span.End() }
+}
+
+func startOperation(ctx context.Context, store string, operation string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	opts = append(opts, trace.WithAttributes(
+		attribute.String("store", store),
+		attribute.String("operation", operation),
+	))
+	return tracing.Start(ctx, "store."+operation, opts...)
+}
@@ -0,0 +1,96 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Client certificate modes for mutual TLS
+const (
+	// ClientAuthRequest verifies client certificates when clients send one
+	ClientAuthRequest = "request"
+	// ClientAuthRequire rejects clients without a valid certificate
+	ClientAuthRequire = "require"
+)
+
+// Config controls native TLS serving. TLS is disabled unless CertFile and
+// KeyFile are set.
+type Config struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ClientCAFile enables mutual TLS with client certificates issued by
+	// the CAs in this PEM bundle
+	ClientCAFile string `yaml:"client_ca_file"`
+	ClientAuth   string `yaml:"client_auth"`
+	// MinVersion is the oldest accepted protocol version, 1.2 or 1.3
+	MinVersion string `yaml:"min_version"`
+	// HTTP2 is negotiated with clients that support it so many streams can
+	// share one connection
+	HTTP2 bool `yaml:"http2"`
+	// ReloadInterval is how often the files are checked for changes
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// DefaultConfig returns the TLS defaults; TLS itself stays disabled until
+// a certificate is configured
+func DefaultConfig() Config {
+	return Config{
+		ClientAuth:     ClientAuthRequire,
+		MinVersion:     "1.2",
+		HTTP2:          true,
+		ReloadInterval: 30 * time.Second,
+	}
+}
+
+// Enabled reports whether a certificate is configured
+func (c Config) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// Validate checks the TLS settings
+func (c Config) Validate() error {
+	var errs []error
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls.cert_file and server.tls.key_file must be set together"))
+	}
+	if c.ClientCAFile != "" && c.CertFile == "" {
+		errs = append(errs, errors.New("server.tls.client_ca_file requires server.tls.cert_file"))
+	}
+	switch c.ClientAuth {
+	case ClientAuthRequest, ClientAuthRequire:
+	default:
+		errs = append(errs, fmt.Errorf("server.tls.client_auth must be %s or %s, got %q", ClientAuthRequest, ClientAuthRequire, c.ClientAuth))
+	}
+	if _, err := c.minVersion(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.ReloadInterval <= 0 {
+		errs = append(errs, fmt.Errorf("server.tls.reload_interval must be positive, got %v", c.ReloadInterval))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c Config) minVersion() (uint16, error) {
+	switch c.MinVersion {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("server.tls.min_version must be 1.2 or 1.3, got %q", c.MinVersion)
+	}
+}
+
+func (c Config) clientAuth() tls.ClientAuthType {
+	if c.ClientCAFile == "" {
+		return tls.NoClientCert
+	}
+	if c.ClientAuth == ClientAuthRequest {
+		return tls.VerifyClientCertIfGiven
+	}
+	return tls.RequireAndVerifyClientCert
+}
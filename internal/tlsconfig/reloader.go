@@ -0,0 +1,169 @@
+// Package tlsconfig serves TLS from certificate files that are reloaded when
+// they change, with optional client certificate verification.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+)
+
+// fileStamp identifies a version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Reloader holds the TLS configuration built from the certificate files and
+// replaces it when the files change. Handshakes always use the latest
+// configuration that loaded successfully.
+type Reloader struct {
+	config  Config
+	current atomic.Pointer[tls.Config]
+	stamps  []fileStamp
+}
+
+// NewReloader loads the configured certificate, key and client CAs
+func NewReloader(config Config) (*Reloader, error) {
+	r := &Reloader{config: config}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig returns the server configuration. Its certificate and client
+// CAs follow reloads.
+func (r *Reloader) TLSConfig() *tls.Config {
+	current := r.current.Load()
+	return &tls.Config{
+		MinVersion: current.MinVersion,
+		NextProtos: current.NextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.current.Load(), nil
+		},
+		// Only used if a handshake bypasses GetConfigForClient
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &r.current.Load().Certificates[0], nil
+		},
+	}
+}
+
+// Start checks the files for changes every ReloadInterval until ctx is
+// cancelled
+func (r *Reloader) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.config.ReloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.reloadIfChanged()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// reloadIfChanged loads the files again when any of them changed. A failed
+// load keeps the current certificate, so a half-written renewal does no harm.
+func (r *Reloader) reloadIfChanged() {
+	stamps, err := r.stat()
+	if err != nil {
+		slog.Error("Error checking TLS certificate files", "error", err)
+		return
+	}
+	if equalStamps(stamps, r.stamps) {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		slog.Error("Error reloading TLS certificate, keeping the current one", "error", err)
+		return
+	}
+	slog.Info("TLS certificate reloaded", "cert_file", r.config.CertFile)
+}
+
+func (r *Reloader) load() error {
+	// Stat before reading so a change during the read is picked up next time
+	stamps, err := r.stat()
+	if err != nil {
+		return err
+	}
+
+	minVersion, err := r.config.minVersion()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   minVersion,
+		Certificates: []tls.Certificate{certificate},
+		NextProtos:   []string{"http/1.1"},
+		ClientAuth:   r.config.clientAuth(),
+	}
+	if r.config.HTTP2 {
+		config.NextProtos = []string{"h2", "http/1.1"}
+	}
+
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("loading client CAs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("loading client CAs: no certificates found")
+		}
+		config.ClientCAs = pool
+	}
+
+	r.current.Store(config)
+	r.stamps = stamps
+	if leaf := certificate.Leaf; leaf != nil {
+		metrics.TLSCertificateExpiry.Set(float64(leaf.NotAfter.Unix()))
+	}
+	return nil
+}
+
+func (r *Reloader) stat() ([]fileStamp, error) {
+	var stamps []fileStamp
+	for _, path := range []string{r.config.CertFile, r.config.KeyFile, r.config.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		stamps = append(stamps, fileStamp{modTime: info.ModTime(), size: info.Size()})
+	}
+	return stamps, nil
+}
+
+func equalStamps(a, b []fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,274 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns PEM encoded certificate and key for name
+func (ca *testCA) issue(t *testing.T, name string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveTLS serves a handler that echoes the protocol and client certificate
+// name and returns its address
+func serveTLS(t *testing.T, reloader *Reloader) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := ""
+			if len(r.TLS.PeerCertificates) > 0 {
+				name = r.TLS.PeerCertificates[0].Subject.CommonName
+			}
+			_, _ = w.Write([]byte(r.Proto + " " + name))
+		}),
+		TLSConfig: reloader.TLSConfig(),
+	}
+	go func() { _ = server.ServeTLS(listener, "", "") }()
+	t.Cleanup(func() { server.Close() })
+	return listener.Addr().String()
+}
+
+func newClient(ca *testCA, certificates ...tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, Certificates: certificates},
+		ForceAttemptHTTP2: true,
+		DisableKeepAlives: true,
+	}}
+}
+
+func get(t *testing.T, client *http.Client, addr string) (*http.Response, string, error) {
+	t.Helper()
+
+	resp, err := client.Get("https://" + addr + "/")
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body := make([]byte, 64)
+	n, _ := resp.Body.Read(body)
+	return resp, string(body[:n]), nil
+}
+
+func TestReloader_ServesHTTP2AndReloads(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.CertFile = filepath.Join(dir, "cert.pem")
+	config.KeyFile = filepath.Join(dir, "key.pem")
+
+	cert, key := ca.issue(t, "localhost", 100, x509.ExtKeyUsageServerAuth)
+	modTime := time.Now().Add(-time.Minute)
+	writeFile(t, config.CertFile, cert, modTime)
+	writeFile(t, config.KeyFile, key, modTime)
+
+	reloader, err := NewReloader(config)
+	if err != nil {
+		t.Fatalf("NewReloader failed: %v", err)
+	}
+	addr := serveTLS(t, reloader)
+	client := newClient(ca)
+
+	resp, body, err := get(t, client, addr)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.ProtoMajor != 2 || body != "HTTP/2.0 " {
+		t.Errorf("Expected HTTP/2, got %s (%q)", resp.Proto, body)
+	}
+	if serial := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 100 {
+		t.Errorf("Expected certificate 100, got %d", serial)
+	}
+
+	// A half-written renewal keeps the current certificate
+	writeFile(t, config.CertFile, []byte("not a certificate"), modTime.Add(time.Second))
+	reloader.reloadIfChanged()
+	if resp, _, err := get(t, client, addr); err != nil || resp.TLS.PeerCertificates[0].SerialNumber.Int64() != 100 {
+		t.Fatalf("Expected the current certificate after a failed reload, got %v", err)
+	}
+
+	cert, key = ca.issue(t, "localhost", 200, x509.ExtKeyUsageServerAuth)
+	writeFile(t, config.CertFile, cert, modTime.Add(2*time.Second))
+	writeFile(t, config.KeyFile, key, modTime.Add(2*time.Second))
+	reloader.reloadIfChanged()
+
+	resp, _, err = get(t, client, addr)
+	if err != nil {
+		t.Fatalf("Request after reload failed: %v", err)
+	}
+	if serial := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); serial != 200 {
+		t.Errorf("Expected reloaded certificate 200, got %d", serial)
+	}
+}
+
+func TestReloader_HTTP2Disabled(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.HTTP2 = false
+	config.CertFile = filepath.Join(dir, "cert.pem")
+	config.KeyFile = filepath.Join(dir, "key.pem")
+
+	cert, key := ca.issue(t, "localhost", 100, x509.ExtKeyUsageServerAuth)
+	writeFile(t, config.CertFile, cert, time.Now())
+	writeFile(t, config.KeyFile, key, time.Now())
+
+	reloader, err := NewReloader(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _, err := get(t, newClient(ca), serveTLS(t, reloader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ProtoMajor != 1 {
+		t.Errorf("Expected HTTP/1.1, got %s", resp.Proto)
+	}
+}
+
+func TestReloader_MutualTLS(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.CertFile = filepath.Join(dir, "cert.pem")
+	config.KeyFile = filepath.Join(dir, "key.pem")
+	config.ClientCAFile = filepath.Join(dir, "ca.pem")
+
+	cert, key := ca.issue(t, "localhost", 100, x509.ExtKeyUsageServerAuth)
+	writeFile(t, config.CertFile, cert, time.Now())
+	writeFile(t, config.KeyFile, key, time.Now())
+	writeFile(t, config.ClientCAFile, ca.pem, time.Now())
+
+	reloader, err := NewReloader(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveTLS(t, reloader)
+
+	if _, _, err := get(t, newClient(ca), addr); err == nil {
+		t.Error("Expected clients without a certificate to be rejected")
+	}
+
+	clientCert, clientKey := ca.issue(t, "ingest-worker", 300, x509.ExtKeyUsageClientAuth)
+	certificate, err := tls.X509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, body, err := get(t, newClient(ca, certificate), addr)
+	if err != nil {
+		t.Fatalf("Expected client certificate to be accepted: %v", err)
+	}
+	if body != "HTTP/2.0 ingest-worker" {
+		t.Errorf("Expected the client certificate name, got %q", body)
+	}
+
+	// With client_auth: request, the certificate is optional
+	config.ClientAuth = ClientAuthRequest
+	optional, err := NewReloader(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := get(t, newClient(ca), serveTLS(t, optional)); err != nil {
+		t.Errorf("Expected clients without a certificate to be accepted, got %v", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Expected defaults to be valid, got %v", err)
+	}
+
+	tests := map[string]func(c *Config){
+		"key_file":       func(c *Config) { c.CertFile = "cert.pem" },
+		"client_ca_file": func(c *Config) { c.ClientCAFile = "ca.pem" },
+		"client_auth":    func(c *Config) { c.ClientAuth = "optional" },
+		"min_version":    func(c *Config) { c.MinVersion = "1.1" },
+	}
+
+	for fragment, mutate := range tests {
+		config := DefaultConfig()
+		mutate(&config)
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), fragment) {
+			t.Errorf("Expected error mentioning %s, got %v", fragment, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package tracing wires OpenTelemetry tracing through the price pipeline.
+// Spans are no-ops until Setup installs an exporter.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const (
+	serviceName     = "btc-price-tracker"
+	instrumentation = "btc-price-tracker"
+)
+
+// Config selects the span exporter and sampling
+type Config struct {
+	// Exporter is none, stdout or otlp
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP collector address, e.g. localhost:4318
+	Endpoint string `yaml:"endpoint"`
+	// Insecure sends OTLP over plain HTTP instead of HTTPS
+	Insecure bool `yaml:"insecure"`
+	// SampleRatio is the fraction of new traces that are recorded
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// DefaultConfig returns a configuration with tracing disabled
+func DefaultConfig() Config {
+	return Config{
+		Exporter:    ExporterNone,
+		Endpoint:    "localhost:4318",
+		Insecure:    true,
+		SampleRatio: 1,
+	}
+}
+
+// Validate checks the tracing settings
+func (c Config) Validate() error {
+	var errs []error
+
+	switch c.Exporter {
+	case ExporterNone, ExporterStdout:
+	case ExporterOTLP:
+		if c.Endpoint == "" {
+			errs = append(errs, errors.New("tracing.endpoint is required for the otlp exporter"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be none, stdout or otlp, got %q", c.Exporter))
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio must be between 0 and 1, got %v", c.SampleRatio))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Setup installs the global tracer provider for the configured exporter.
+// The stdout exporter writes to w. The returned function flushes pending
+// spans and must be called on shutdown.
+func Setup(ctx context.Context, cfg Config, w io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cfg.Exporter) {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span from the global tracer provider
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, opts...)
+}
+
+// End records err on span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject returns the W3C traceparent of the span in ctx so it can travel
+// with an event. It is empty when ctx carries no valid span.
+func Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// Extract returns a context whose remote parent is the span described by
+// traceParent. An empty or malformed traceParent yields a bare context.
+func Extract(traceParent string) context.Context {
+	if traceParent == "" {
+		return context.Background()
+	}
+	carrier := propagation.MapCarrier{"traceparent": traceParent}
+	return propagation.TraceContext{}.Extract(context.Background(), carrier)
+}
+
+// Link returns a span link to the span described by traceParent
+func Link(traceParent string) (trace.Link, bool) {
+	spanContext := trace.SpanContextFromContext(Extract(traceParent))
+	if !spanContext.IsValid() {
+		return trace.Link{}, false
+	}
+	return trace.Link{SpanContext: spanContext}, true
+}
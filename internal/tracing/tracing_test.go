@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, parent := Start(context.Background(), "parent")
+	traceParent := Inject(ctx)
+	parent.End()
+
+	if traceParent == "" {
+		t.Fatal("Expected a traceparent for a recording span")
+	}
+
+	_, child := Start(Extract(traceParent), "child")
+	child.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if spans[1].Parent().SpanID() != spans[0].SpanContext().SpanID() {
+		t.Error("Expected child span to be parented to the injected span")
+	}
+
+	if Inject(context.Background()) != "" {
+		t.Error("Expected empty traceparent without a span")
+	}
+	if trace.SpanContextFromContext(Extract("garbage")).IsValid() {
+		t.Error("Expected malformed traceparent to be ignored")
+	}
+}
+
+func TestSetup_Stdout(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := Setup(context.Background(), Config{Exporter: ExporterStdout, SampleRatio: 1}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := Start(context.Background(), "price.poll")
+	span.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(`"Name":"price.poll"`)) {
+		t.Errorf("Expected span in stdout output, got %q", buf.String())
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Expected default config to be valid, got %v", err)
+	}
+	if err := (Config{Exporter: "jaeger", SampleRatio: 1}).Validate(); err == nil {
+		t.Error("Expected error for unknown exporter")
+	}
+	if err := (Config{Exporter: ExporterOTLP, SampleRatio: 2}).Validate(); err == nil {
+		t.Error("Expected error for missing endpoint and invalid ratio")
+	}
+}
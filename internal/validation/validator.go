@@ -0,0 +1,218 @@
+// Package validation screens fetched prices before they are stored, so bad
+// ticks from a provider never reach the store or the stream.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// Reasons a tick is quarantined, used as the metric label
+const (
+	ReasonInvalid   = "invalid"
+	ReasonDeviation = "deviation"
+)
+
+// Config controls which ticks are accepted
+type Config struct {
+	// MaxDeviation is the largest accepted move, in percent, from the median
+	// of recent prices. Zero disables the check.
+	MaxDeviation float64 `yaml:"max_deviation"`
+	// Window is how many recent accepted prices the median is taken over
+	Window int `yaml:"window"`
+	// MaxAge excludes older prices from the median, so a restart after an
+	// outage does not compare against stale prices
+	MaxAge time.Duration `yaml:"max_age"`
+	// MaxRejections is how many consecutive ticks may be rejected for
+	// deviation before the next one is accepted as the new price level. The
+	// last MaxRejections rejected ticks and the next one must be within
+	// MaxDeviation of their own median, so scattered bad ticks are not
+	// mistaken for a move.
+	MaxRejections int `yaml:"max_rejections"`
+}
+
+// DefaultConfig returns the validation defaults
+func DefaultConfig() Config {
+	return Config{
+		MaxDeviation:  10,
+		Window:        10,
+		MaxAge:        10 * time.Minute,
+		MaxRejections: 3,
+	}
+}
+
+// Validate checks the validation settings
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.MaxDeviation < 0 || math.IsNaN(c.MaxDeviation) {
+		errs = append(errs, fmt.Errorf("validation.max_deviation must not be negative, got %v", c.MaxDeviation))
+	}
+	if c.Window < 1 {
+		errs = append(errs, fmt.Errorf("validation.window must be at least 1, got %d", c.Window))
+	}
+	if c.MaxAge <= 0 {
+		errs = append(errs, fmt.Errorf("validation.max_age must be positive, got %v", c.MaxAge))
+	}
+	if c.MaxRejections < 1 {
+		errs = append(errs, fmt.Errorf("validation.max_rejections must be at least 1, got %d", c.MaxRejections))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Rejection explains why a tick was quarantined
+type Rejection struct {
+	Reason string
+	Detail string
+}
+
+func (r *Rejection) Error() string {
+	return r.Detail
+}
+
+// Validator checks each tick against basic sanity rules and the recent
+// accepted prices
+type Validator struct {
+	cfg Config
+
+	mutex sync.Mutex
+	// recent holds accepted events, oldest first
+	recent []domain.PriceUpdateEvent
+	// rejected holds the prices of the last MaxRejections consecutive ticks
+	// rejected for deviation, oldest first
+	rejected []domain.Price
+}
+
+func NewValidator(cfg Config) *Validator {
+	return &Validator{cfg: cfg}
+}
+
+// Prime seeds the recent prices from the store so the deviation check
+// applies from the first tick after a restart
+func (v *Validator) Prime(eventStore store.EventStore) {
+	events := eventStore.GetEventsSince(time.Now().Add(-v.cfg.MaxAge).Unix())
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	for _, event := range events {
+		if !event.Backfilled && checkPrice(event.Price) == nil {
+			v.accept(event)
+		}
+	}
+}
+
+// Check accepts event or returns a *Rejection explaining why it must be
+// quarantined
+func (v *Validator) Check(event domain.PriceUpdateEvent) error {
+	if err := checkPrice(event.Price); err != nil {
+		return err
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.expire(event.Timestamp)
+	if v.cfg.MaxDeviation > 0 && len(v.recent) > 0 {
+		median := v.median()
+		move := event.Price.Sub(median).Abs()
+
+		if move.Cmp(median.Percent(v.cfg.MaxDeviation)) > 0 {
+			if !v.persistentMove(event.Price) {
+				v.reject(event.Price)
+				deviation := move.Float64() / median.Float64() * 100
+				return &Rejection{
+					Reason: ReasonDeviation,
+					Detail: fmt.Sprintf("price %s deviates %.1f%% from recent median %s, limit is %.1f%%", event.Price, deviation, median, v.cfg.MaxDeviation),
+				}
+			}
+			// The move persisted, so it is the market rather than a bad tick
+			v.recent = v.recent[:0]
+		}
+	}
+
+	v.accept(event)
+	return nil
+}
+
+func checkPrice(price domain.Price) error {
+	if price.Sign() <= 0 {
+		return &Rejection{Reason: ReasonInvalid, Detail: fmt.Sprintf("price %s is not positive", price)}
+	}
+	return nil
+}
+
+// persistentMove reports whether price and the ticks rejected before it
+// form a new price level: at least MaxRejections rejected ticks that are
+// all, with price, within MaxDeviation of their median
+func (v *Validator) persistentMove(price domain.Price) bool {
+	if len(v.rejected) < v.cfg.MaxRejections {
+		return false
+	}
+
+	prices := append(slices.Clone(v.rejected), price)
+	level := median(prices)
+	limit := level.Percent(v.cfg.MaxDeviation)
+	for _, p := range prices {
+		if p.Sub(level).Abs().Cmp(limit) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// reject remembers a price rejected for deviation, keeping the last
+// MaxRejections
+func (v *Validator) reject(price domain.Price) {
+	v.rejected = append(v.rejected, price)
+	if len(v.rejected) > v.cfg.MaxRejections {
+		v.rejected = v.rejected[len(v.rejected)-v.cfg.MaxRejections:]
+	}
+}
+
+func (v *Validator) accept(event domain.PriceUpdateEvent) {
+	v.rejected = v.rejected[:0]
+	v.recent = append(v.recent, event)
+	if len(v.recent) > v.cfg.Window {
+		v.recent = v.recent[len(v.recent)-v.cfg.Window:]
+	}
+}
+
+// expire drops accepted prices older than MaxAge at timestamp
+func (v *Validator) expire(timestamp int64) {
+	cutoff := timestamp - int64(v.cfg.MaxAge.Seconds())
+
+	trim := 0
+	for trim < len(v.recent) && v.recent[trim].Timestamp < cutoff {
+		trim++
+	}
+	v.recent = v.recent[trim:]
+}
+
+// median returns the exact median of the recent prices
+func (v *Validator) median() domain.Price {
+	prices := make([]domain.Price, len(v.recent))
+	for i, event := range v.recent {
+		prices[i] = event.Price
+	}
+	return median(prices)
+}
+
+// median returns the exact median of prices, sorting them in place
+func median(prices []domain.Price) domain.Price {
+	slices.SortFunc(prices, domain.Price.Cmp)
+
+	middle := len(prices) / 2
+	if len(prices)%2 == 0 {
+		return prices[middle-1].Add(prices[middle]).Half()
+	}
+	return prices[middle]
+}
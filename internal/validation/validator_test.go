@@ -0,0 +1,103 @@
+package validation
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+func reason(err error) string {
+	var rejection *Rejection
+	if errors.As(err, &rejection) {
+		return rejection.Reason
+	}
+	return ""
+}
+
+func TestValidator_RejectsInvalidPrices(t *testing.T) {
+	validator := NewValidator(DefaultConfig())
+
+	for _, price := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		if err := validator.Check(domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(price)}); reason(err) != ReasonInvalid {
+			t.Errorf("Expected price %v to be rejected as invalid, got %v", price, err)
+		}
+	}
+}
+
+func TestValidator_Deviation(t *testing.T) {
+	validator := NewValidator(Config{MaxDeviation: 10, Window: 5, MaxAge: time.Hour, MaxRejections: 2})
+
+	for i, price := range []float64{100, 101, 99, 100} {
+		if err := validator.Check(domain.PriceUpdateEvent{Timestamp: int64(i), Price: domain.NewPrice(price)}); err != nil {
+			t.Fatalf("Expected price %v to be accepted, got %v", price, err)
+		}
+	}
+
+	// A single spike is rejected and does not move the median
+	if err := validator.Check(domain.PriceUpdateEvent{Timestamp: 10, Price: domain.NewPrice(1000)}); reason(err) != ReasonDeviation {
+		t.Errorf("Expected a spike to be rejected, got %v", err)
+	}
+	if err := validator.Check(domain.PriceUpdateEvent{Timestamp: 11, Price: domain.NewPrice(105)}); err != nil {
+		t.Errorf("Expected a price within the limit to be accepted, got %v", err)
+	}
+
+	// A move that persists beyond MaxRejections ticks becomes the new level
+	for i := int64(0); i < 2; i++ {
+		if err := validator.Check(domain.PriceUpdateEvent{Timestamp: 20 + i, Price: domain.NewPrice(150)}); reason(err) != ReasonDeviation {
+			t.Fatalf("Expected tick %d of the move to be rejected, got %v", i, err)
+		}
+	}
+	if err := validator.Check(domain.PriceUpdateEvent{Timestamp: 22, Price: domain.NewPrice(150)}); err != nil {
+		t.Fatalf("Expected the persistent move to be accepted, got %v", err)
+	}
+	if err := validator.Check(domain.PriceUpdateEvent{Timestamp: 23, Price: domain.NewPrice(151)}); err != nil {
+		t.Errorf("Expected prices at the new level to be accepted, got %v", err)
+	}
+}
+
+func TestValidator_ScatteredOutliers(t *testing.T) {
+	validator := NewValidator(Config{MaxDeviation: 10, Window: 5, MaxAge: time.Hour, MaxRejections: 3})
+	if err := validator.Check(domain.PriceUpdateEvent{Timestamp: 1, Price: domain.NewPrice(100)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Outliers that disagree with each other are not a new level, however
+	// many arrive in a row
+	for i, price := range []float64{1000, 5, 300, 150, 150, 150} {
+		if err := validator.Check(domain.PriceUpdateEvent{Timestamp: int64(10 + i), Price: domain.NewPrice(price)}); reason(err) != ReasonDeviation {
+			t.Fatalf("Expected outlier %v to be rejected, got %v", price, err)
+		}
+	}
+
+	// Once the last rejected ticks agree, the next one moves the level
+	if err := validator.Check(domain.PriceUpdateEvent{Timestamp: 20, Price: domain.NewPrice(151)}); err != nil {
+		t.Errorf("Expected the persistent move to be accepted, got %v", err)
+	}
+}
+
+func TestValidator_PrimeAndExpire(t *testing.T) {
+	now := time.Now().Unix()
+	memStore := store.NewMemoryStore(10)
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: now - 3600, Price: domain.NewPrice(10)})
+	memStore.Store(domain.PriceUpdateEvent{Timestamp: now - 60, Price: domain.NewPrice(100)})
+
+	validator := NewValidator(Config{MaxDeviation: 10, Window: 5, MaxAge: 10 * time.Minute, MaxRejections: 3})
+	validator.Prime(memStore)
+
+	// Only the recent stored price is compared against
+	if err := validator.Check(domain.PriceUpdateEvent{Timestamp: now, Price: domain.NewPrice(150)}); reason(err) != ReasonDeviation {
+		t.Errorf("Expected a deviation from the primed price to be rejected, got %v", err)
+	}
+	if err := validator.Check(domain.PriceUpdateEvent{Timestamp: now, Price: domain.NewPrice(100)}); err != nil {
+		t.Errorf("Expected the primed level to be accepted, got %v", err)
+	}
+
+	// After MaxAge without ticks there is nothing left to compare against
+	if err := validator.Check(domain.PriceUpdateEvent{Timestamp: now + 3600, Price: domain.NewPrice(150)}); err != nil {
+		t.Errorf("Expected the first tick after a gap to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/api"
+	"github.com/tstojkovski/btc-price-tracker/internal/auth"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+)
+
+// webhookRequest is the body accepted when registering a webhook
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Rules  []string `json:"rules"`
+}
+
+// RegisterRoutes adds the webhook management API to mux. Webhooks receive
+// every matching event and dead letters hold their payloads, so every
+// endpoint needs an admin key.
+func (d *Dispatcher) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/webhooks", auth.RequireAdmin(d.listWebhooks))
+	mux.HandleFunc("POST /api/v1/webhooks", auth.RequireAdmin(d.createWebhook))
+	mux.HandleFunc("GET /api/v1/webhooks/{id}", auth.RequireAdmin(d.getWebhook))
+	mux.HandleFunc("DELETE /api/v1/webhooks/{id}", auth.RequireAdmin(d.deleteWebhook))
+	mux.HandleFunc("GET /api/v1/dead-letters", auth.RequireAdmin(d.listDeadLetters))
+	mux.HandleFunc("POST /api/v1/dead-letters/{id}/redeliver", auth.RequireAdmin(d.redeliver))
+	mux.HandleFunc("DELETE /api/v1/dead-letters/{id}", auth.RequireAdmin(d.deleteDeadLetter))
+}
+
+// withoutSecret hides the signing secret, which is only returned on creation
+func withoutSecret(webhook domain.Webhook) domain.Webhook {
+	webhook.Secret = ""
+	return webhook
+}
+
+func (d *Dispatcher) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks := d.Webhooks()
+	for i := range webhooks {
+		webhooks[i] = withoutSecret(webhooks[i])
+	}
+	api.WriteJSON(w, http.StatusOK, webhooks)
+}
+
+func (d *Dispatcher) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if !api.DecodeJSON(w, r, &req) {
+		return
+	}
+
+	webhook, err := d.AddWebhook(domain.Webhook{
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: req.Events,
+		Rules:  req.Rules,
+	})
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	api.WriteJSON(w, http.StatusCreated, webhook)
+}
+
+func (d *Dispatcher) getWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := d.Webhook(r.PathValue("id"))
+	if !ok {
+		api.WriteError(w, http.StatusNotFound, "webhook %q not found", r.PathValue("id"))
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, withoutSecret(webhook))
+}
+
+func (d *Dispatcher) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if !d.DeleteWebhook(r.PathValue("id")) {
+		api.WriteError(w, http.StatusNotFound, "webhook %q not found", r.PathValue("id"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *Dispatcher) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	letters := d.DeadLetters()
+	if letters == nil {
+		letters = []domain.DeadLetter{}
+	}
+	api.WriteJSON(w, http.StatusOK, letters)
+}
+
+func (d *Dispatcher) redeliver(w http.ResponseWriter, r *http.Request) {
+	if err := d.Redeliver(r.PathValue("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		api.WriteError(w, status, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (d *Dispatcher) deleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !d.DeleteDeadLetter(r.PathValue("id")) {
+		api.WriteError(w, http.StatusNotFound, "dead letter %q not found", r.PathValue("id"))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
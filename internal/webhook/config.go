@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Config controls webhook delivery
+type Config struct {
+	Workers        int           `yaml:"workers"`
+	QueueSize      int           `yaml:"queue_size"`
+	Timeout        time.Duration `yaml:"timeout"`
+	MaxRetries     int           `yaml:"max_retries"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	// AllowPrivate permits webhooks on private, loopback and link-local
+	// addresses, e.g. receivers on the same host or network
+	AllowPrivate bool `yaml:"allow_private"`
+}
+
+// DefaultConfig returns the webhook delivery defaults
+func DefaultConfig() Config {
+	return Config{
+		Workers:        4,
+		QueueSize:      1000,
+		Timeout:        10 * time.Second,
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	}
+}
+
+// Validate checks the webhook delivery settings
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Workers < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.workers must be at least 1, got %d", c.Workers))
+	}
+	if c.QueueSize < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.queue_size must be at least 1, got %d", c.QueueSize))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.timeout must be positive, got %v", c.Timeout))
+	}
+	if c.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("webhooks.max_retries must not be negative, got %d", c.MaxRetries))
+	}
+	if c.InitialBackoff <= 0 || c.MaxBackoff < c.InitialBackoff {
+		errs = append(errs, errors.New("webhooks.initial_backoff must be positive and no larger than webhooks.max_backoff"))
+	}
+
+	return errors.Join(errs...)
+}
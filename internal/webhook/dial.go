@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// errPrivateAddress is returned for webhook URLs that resolve to an address
+// inside the deployment's network
+var errPrivateAddress = errors.New("webhook address is private, loopback or link-local")
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip does not
+// count as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// isPublicAddress reports whether addr may receive webhook deliveries when
+// private addresses are not allowed
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsUnspecified() && !addr.IsLoopback() && !addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// rejectPrivate is a net.Dialer Control function refusing connections to
+// non-public addresses. It runs after name resolution and for every
+// redirect, so DNS names pointing inside the network are caught too.
+func rejectPrivate(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("parsing dial address %q: %w", address, err)
+	}
+	if !isPublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// newHTTPClient returns the client used for deliveries. Unless allowPrivate
+// is set it cannot reach private addresses, and proxies from the environment
+// are ignored since only the proxy's address could be checked.
+func newHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivate {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: rejectPrivate}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
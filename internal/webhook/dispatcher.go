@@ -0,0 +1,382 @@
+// Package webhook delivers price events and fired alerts to registered HTTP
+// endpoints with signing, retries and a dead-letter queue.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/metrics"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+var (
+	ErrNotFound       = errors.New("not found")
+	ErrInvalidWebhook = errors.New("invalid webhook")
+)
+
+// Payload is the JSON body of every delivery
+type Payload struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	Data      any    `json:"data"`
+}
+
+type delivery struct {
+	webhook domain.Webhook
+	id      string
+	event   string
+	body    []byte
+}
+
+// Dispatcher fans price events and alerts out to the registered webhooks.
+// Webhooks and dead letters are kept in the store.
+type Dispatcher struct {
+	store  store.WebhookStore
+	config Config
+	client *http.Client
+	queue  chan delivery
+	now    func() time.Time
+
+	mutex    sync.RWMutex
+	webhooks map[string]domain.Webhook
+}
+
+func NewDispatcher(store store.WebhookStore, config Config) *Dispatcher {
+	return &Dispatcher{
+		store:    store,
+		config:   config,
+		client:   newHTTPClient(config.Timeout, config.AllowPrivate),
+		queue:    make(chan delivery, config.QueueSize),
+		now:      time.Now,
+		webhooks: make(map[string]domain.Webhook),
+	}
+}
+
+// Load reads the registered webhooks from the store
+func (d *Dispatcher) Load() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, webhook := range d.store.GetWebhooks() {
+		d.webhooks[webhook.ID] = webhook
+	}
+}
+
+// Start delivers events from prices and alerts until ctx is cancelled.
+// Either channel may be nil.
+func (d *Dispatcher) Start(ctx context.Context, prices <-chan domain.PriceUpdateEvent, alerts <-chan domain.Alert) {
+	for i := 0; i < d.config.Workers; i++ {
+		go d.worker(ctx)
+	}
+
+	go func() {
+		for {
+			select {
+			case event := <-prices:
+				d.Publish(domain.WebhookEventPrice, "", event)
+			case alert := <-alerts:
+				d.Publish(domain.WebhookEventAlert, alert.RuleID, alert)
+			case <-ctx.Done():
+				slog.Info("Stopping webhook dispatcher")
+				return
+			}
+		}
+	}()
+}
+
+// Publish queues data for every webhook subscribed to event. ruleID is the
+// alert rule that fired, if any, and is matched against webhook rule filters.
+func (d *Dispatcher) Publish(event string, ruleID string, data any) {
+	payload := Payload{
+		ID:        newID(),
+		Type:      event,
+		Timestamp: d.now().Unix(),
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Error marshaling webhook payload", "event", event, "error", err)
+		return
+	}
+
+	// A full queue saves dead letters to the store, which must not block
+	// changes to the webhooks, so deliveries are queued outside the lock
+	d.mutex.RLock()
+	var subscribed []domain.Webhook
+	for _, webhook := range d.webhooks {
+		if matches(webhook, event, ruleID) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	d.mutex.RUnlock()
+
+	for _, webhook := range subscribed {
+		d.enqueue(delivery{webhook: webhook, id: payload.ID, event: event, body: body})
+	}
+}
+
+func (d *Dispatcher) enqueue(job delivery) {
+	select {
+	case d.queue <- job:
+	default:
+		slog.Warn("Webhook delivery queue full", "webhook", job.webhook.ID)
+		d.deadLetter(job, 0, errors.New("delivery queue full"))
+	}
+}
+
+// matches applies a webhook's event and rule filters
+func matches(webhook domain.Webhook, event string, ruleID string) bool {
+	if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, event) {
+		return false
+	}
+	if event == domain.WebhookEventAlert && len(webhook.Rules) > 0 && !slices.Contains(webhook.Rules, ruleID) {
+		return false
+	}
+	return true
+}
+
+func (d *Dispatcher) worker(ctx context.Context) {
+	for {
+		select {
+		case job := <-d.queue:
+			d.deliver(ctx, job)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver attempts a delivery, retrying with exponential backoff, and moves
+// it to the dead-letter queue once retries are exhausted or the receiver
+// rejects it outright
+func (d *Dispatcher) deliver(ctx context.Context, job delivery) {
+	backoff := d.config.InitialBackoff
+
+	var err error
+	attempts := 0
+	for attempts <= d.config.MaxRetries {
+		if attempts > 0 {
+			metrics.WebhookDeliveries.WithLabelValues("retry").Inc()
+			select {
+			case <-time.After(backoff):
+				backoff = min(backoff*2, d.config.MaxBackoff)
+			case <-ctx.Done():
+				d.deadLetter(job, attempts, fmt.Errorf("shutdown before retry: %w", err))
+				return
+			}
+		}
+
+		var retryable bool
+		attempts++
+		retryable, err = d.attempt(ctx, job)
+		if err == nil {
+			metrics.WebhookDeliveries.WithLabelValues("success").Inc()
+			return
+		}
+
+		slog.Warn("Webhook delivery failed", "webhook", job.webhook.ID, "attempt", attempts, "error", err)
+		if !retryable {
+			break
+		}
+	}
+
+	d.deadLetter(job, attempts, err)
+}
+
+// attempt sends one signed request. Network errors, 429 and 5xx responses
+// are retryable; refused addresses and any other non-2xx response are not.
+func (d *Dispatcher) attempt(ctx context.Context, job delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.webhook.URL, bytes.NewReader(job.body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "btc-price-tracker-webhook")
+	req.Header.Set(HeaderEvent, job.event)
+	req.Header.Set(HeaderWebhookID, job.webhook.ID)
+	req.Header.Set(HeaderDelivery, job.id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(job.webhook.Secret, timestamp, job.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, errPrivateAddress), err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("receiver responded %s", resp.Status)
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, err
+}
+
+func (d *Dispatcher) deadLetter(job delivery, attempts int, err error) {
+	metrics.WebhookDeliveries.WithLabelValues("dead_letter").Inc()
+	slog.Error("Webhook delivery moved to dead-letter queue", "webhook", job.webhook.ID, "delivery", job.id, "attempts", attempts, "error", err)
+
+	d.store.SaveDeadLetter(domain.DeadLetter{
+		ID:        job.id + "-" + job.webhook.ID,
+		WebhookID: job.webhook.ID,
+		Event:     job.event,
+		Payload:   string(job.body),
+		Attempts:  attempts,
+		LastError: err.Error(),
+		FailedAt:  d.now().Unix(),
+	})
+}
+
+// Webhooks returns the registered webhooks ordered by ID
+func (d *Dispatcher) Webhooks() []domain.Webhook {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	webhooks := make([]domain.Webhook, 0, len(d.webhooks))
+	for _, webhook := range d.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks
+}
+
+// Webhook returns a registered webhook
+func (d *Dispatcher) Webhook(id string) (domain.Webhook, bool) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	webhook, ok := d.webhooks[id]
+	return webhook, ok
+}
+
+// AddWebhook validates and registers a webhook under a new ID. A secret is
+// generated when none is given.
+func (d *Dispatcher) AddWebhook(webhook domain.Webhook) (domain.Webhook, error) {
+	if err := validateWebhook(webhook, d.config.AllowPrivate); err != nil {
+		return domain.Webhook{}, err
+	}
+
+	webhook.ID = newID()
+	if webhook.Secret == "" {
+		webhook.Secret = newSecret()
+	}
+	webhook.CreatedAt = d.now().Unix()
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.store.SaveWebhook(webhook)
+	d.webhooks[webhook.ID] = webhook
+	return webhook, nil
+}
+
+// DeleteWebhook unregisters a webhook and reports whether it existed
+func (d *Dispatcher) DeleteWebhook(id string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.webhooks[id]; !ok {
+		return false
+	}
+	d.store.DeleteWebhook(id)
+	delete(d.webhooks, id)
+	return true
+}
+
+// DeadLetters returns failed deliveries, oldest first
+func (d *Dispatcher) DeadLetters() []domain.DeadLetter {
+	return d.store.GetDeadLetters()
+}
+
+// Redeliver removes a dead letter and queues it again for its webhook
+func (d *Dispatcher) Redeliver(id string) error {
+	var letter domain.DeadLetter
+	found := false
+	for _, candidate := range d.store.GetDeadLetters() {
+		if candidate.ID == id {
+			letter, found = candidate, true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("dead letter %q: %w", id, ErrNotFound)
+	}
+
+	webhook, ok := d.Webhook(letter.WebhookID)
+	if !ok {
+		return fmt.Errorf("webhook %q: %w", letter.WebhookID, ErrNotFound)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal([]byte(letter.Payload), &payload); err != nil {
+		return fmt.Errorf("dead letter %q has an unreadable payload: %w", id, err)
+	}
+
+	d.store.DeleteDeadLetter(id)
+	d.enqueue(delivery{webhook: webhook, id: payload.ID, event: letter.Event, body: []byte(letter.Payload)})
+	return nil
+}
+
+// DeleteDeadLetter discards a dead letter and reports whether it existed
+func (d *Dispatcher) DeleteDeadLetter(id string) bool {
+	return d.store.DeleteDeadLetter(id)
+}
+
+// validateWebhook checks a webhook before it is registered. Host names are
+// checked when delivering, since they may resolve differently by then.
+func validateWebhook(webhook domain.Webhook, allowPrivate bool) error {
+	var errs []error
+
+	parsed, err := url.Parse(webhook.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		errs = append(errs, fmt.Errorf("url must be an absolute http or https URL, got %q", webhook.URL))
+	} else if addr, err := netip.ParseAddr(parsed.Hostname()); err == nil && !allowPrivate && !isPublicAddress(addr) {
+		errs = append(errs, fmt.Errorf("url must not point to a private address unless webhooks.allow_private is set, got %q", webhook.URL))
+	}
+	for _, event := range webhook.Events {
+		if event != domain.WebhookEventPrice && event != domain.WebhookEventAlert {
+			errs = append(errs, fmt.Errorf("events must be %q or %q, got %q", domain.WebhookEventPrice, domain.WebhookEventAlert, event))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidWebhook, err)
+	}
+	return nil
+}
+
+func newID() string {
+	return randomHex(8)
+}
+
+func newSecret() string {
+	return randomHex(32)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
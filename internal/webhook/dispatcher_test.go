@@ -0,0 +1,340 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/auth"
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+// receiver is an httptest webhook endpoint that fails the first failures
+// requests with status and records verified deliveries
+type receiver struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	secret     string
+	failures   int
+	status     int
+	attempts   int
+	deliveries []Payload
+}
+
+func newReceiver(t *testing.T, secret string, failures int, status int) *receiver {
+	t.Helper()
+
+	rc := &receiver{secret: secret, failures: failures, status: status}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+
+		rc.attempts++
+		if !Verify(rc.secret, timestamp, body, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if rc.attempts <= rc.failures {
+			w.WriteHeader(rc.status)
+			return
+		}
+
+		var payload Payload
+		_ = json.Unmarshal(body, &payload)
+		rc.deliveries = append(rc.deliveries, payload)
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) counts() (attempts int, delivered int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.attempts, len(rc.deliveries)
+}
+
+func testConfig() Config {
+	return Config{
+		Workers:        1,
+		QueueSize:      10,
+		Timeout:        time.Second,
+		MaxRetries:     2,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		// Receivers are httptest servers on loopback
+		AllowPrivate: true,
+	}
+}
+
+func startDispatcher(t *testing.T) (*Dispatcher, *store.MemoryStore) {
+	t.Helper()
+
+	memStore := store.NewMemoryStore(10)
+	dispatcher := NewDispatcher(memStore, testConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	dispatcher.Start(ctx, nil, nil)
+	return dispatcher, memStore
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestDispatcher_RetriesUntilDelivered(t *testing.T) {
+	rc := newReceiver(t, "s3cret", 2, http.StatusServiceUnavailable)
+	dispatcher, _ := startDispatcher(t)
+
+	if _, err := dispatcher.AddWebhook(domain.Webhook{URL: rc.URL, Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Publish(domain.WebhookEventPrice, "", domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(50000)})
+
+	waitFor(t, func() bool { _, delivered := rc.counts(); return delivered == 1 })
+
+	attempts, _ := rc.counts()
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	if rc.deliveries[0].Type != domain.WebhookEventPrice {
+		t.Errorf("Expected price payload, got %+v", rc.deliveries[0])
+	}
+	if letters := dispatcher.DeadLetters(); len(letters) != 0 {
+		t.Errorf("Expected no dead letters, got %+v", letters)
+	}
+}
+
+func TestDispatcher_DeadLetterAndRedeliver(t *testing.T) {
+	rc := newReceiver(t, "s3cret", 3, http.StatusInternalServerError)
+	dispatcher, _ := startDispatcher(t)
+
+	if _, err := dispatcher.AddWebhook(domain.Webhook{URL: rc.URL, Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Publish(domain.WebhookEventAlert, "btc-100k", domain.Alert{RuleID: "btc-100k"})
+
+	waitFor(t, func() bool { return len(dispatcher.DeadLetters()) == 1 })
+
+	letter := dispatcher.DeadLetters()[0]
+	if letter.Attempts != 3 || !strings.Contains(letter.LastError, "500") {
+		t.Errorf("Unexpected dead letter: %+v", letter)
+	}
+
+	// The receiver has recovered, so redelivery succeeds
+	if err := dispatcher.Redeliver(letter.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { _, delivered := rc.counts(); return delivered == 1 })
+	if letters := dispatcher.DeadLetters(); len(letters) != 0 {
+		t.Errorf("Expected dead letter to be removed, got %+v", letters)
+	}
+}
+
+func TestDispatcher_ClientErrorIsNotRetried(t *testing.T) {
+	rc := newReceiver(t, "s3cret", 1, http.StatusGone)
+	dispatcher, _ := startDispatcher(t)
+
+	if _, err := dispatcher.AddWebhook(domain.Webhook{URL: rc.URL, Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Publish(domain.WebhookEventPrice, "", domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(50000)})
+
+	waitFor(t, func() bool { return len(dispatcher.DeadLetters()) == 1 })
+	if attempts, _ := rc.counts(); attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", attempts)
+	}
+}
+
+func TestDispatcher_RejectsPrivateAddresses(t *testing.T) {
+	rc := newReceiver(t, "s3cret", 0, 0)
+	config := testConfig()
+	config.AllowPrivate = false
+	dispatcher := NewDispatcher(store.NewMemoryStore(10), config)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	dispatcher.Start(ctx, nil, nil)
+
+	// Literal addresses are refused when registering
+	if _, err := dispatcher.AddWebhook(domain.Webhook{URL: rc.URL}); !errors.Is(err, ErrInvalidWebhook) {
+		t.Errorf("Expected a loopback URL to be rejected, got %v", err)
+	}
+
+	// Host names are checked once resolved, when delivering
+	_, port, _ := net.SplitHostPort(rc.Listener.Addr().String())
+	if _, err := dispatcher.AddWebhook(domain.Webhook{URL: "http://localhost:" + port, Secret: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+	dispatcher.Publish(domain.WebhookEventPrice, "", domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(50000)})
+
+	waitFor(t, func() bool { return len(dispatcher.DeadLetters()) == 1 })
+	if letter := dispatcher.DeadLetters()[0]; !strings.Contains(letter.LastError, errPrivateAddress.Error()) {
+		t.Errorf("Expected the private address to be refused, got %q", letter.LastError)
+	}
+	if letter := dispatcher.DeadLetters()[0]; letter.Attempts != 1 {
+		t.Errorf("Expected a refused address not to be retried, got %d attempts", letter.Attempts)
+	}
+	if attempts, _ := rc.counts(); attempts != 0 {
+		t.Errorf("Expected no request to reach the receiver, got %d", attempts)
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+	}
+
+	for address, want := range tests {
+		if got := isPublicAddress(netip.MustParseAddr(address)); got != want {
+			t.Errorf("isPublicAddress(%s) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestDispatcher_Filters(t *testing.T) {
+	rc := newReceiver(t, "s3cret", 0, 0)
+	dispatcher, _ := startDispatcher(t)
+
+	_, err := dispatcher.AddWebhook(domain.Webhook{
+		URL:    rc.URL,
+		Secret: "s3cret",
+		Events: []string{domain.WebhookEventAlert},
+		Rules:  []string{"btc-100k"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher.Publish(domain.WebhookEventPrice, "", domain.PriceUpdateEvent{Timestamp: 100, Price: domain.NewPrice(50000)})
+	dispatcher.Publish(domain.WebhookEventAlert, "btc-50k", domain.Alert{RuleID: "btc-50k"})
+	dispatcher.Publish(domain.WebhookEventAlert, "btc-100k", domain.Alert{RuleID: "btc-100k"})
+
+	waitFor(t, func() bool { _, delivered := rc.counts(); return delivered == 1 })
+	time.Sleep(20 * time.Millisecond)
+
+	if attempts, _ := rc.counts(); attempts != 1 {
+		t.Errorf("Expected only the matching alert to be delivered, got %d attempts", attempts)
+	}
+}
+
+// testAdminKey authenticates requests to the admin-only webhook API
+const testAdminKey = "admin-key-for-tests"
+
+func adminRequest(method string, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set(auth.KeyHeader, testAdminKey)
+	return req
+}
+
+func TestDispatcher_API(t *testing.T) {
+	dispatcher, memStore := startDispatcher(t)
+	mux := http.NewServeMux()
+	dispatcher.RegisterRoutes(mux)
+	authConfig := auth.DefaultConfig()
+	authConfig.Enabled = true
+	authConfig.AdminKey = testAdminKey
+	handler := auth.NewAuthenticator(store.NewMemoryStore(10), authConfig).Middleware(mux)
+
+	// Only admins manage webhooks
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/webhooks", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without an admin key, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/dead-letters", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without an admin key, got %d", w.Code)
+	}
+
+	// Create
+	w = httptest.NewRecorder()
+	body := `{"url":"https://hooks.example.com/btc","events":["alert"]}`
+	handler.ServeHTTP(w, adminRequest("POST", "/api/v1/webhooks", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body)
+	}
+
+	var created domain.Webhook
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || len(created.Secret) != 64 {
+		t.Errorf("Expected generated ID and secret, got %+v", created)
+	}
+	if len(memStore.GetWebhooks()) != 1 {
+		t.Error("Expected webhook to be persisted")
+	}
+
+	// List hides the secret
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest("GET", "/api/v1/webhooks", nil))
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Secret) {
+		t.Errorf("Expected listing without secret, got %d: %s", w.Code, w.Body)
+	}
+
+	// Invalid
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest("POST", "/api/v1/webhooks", strings.NewReader(`{"url":"ftp://x","events":["trade"]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+
+	// Delete
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest("DELETE", "/api/v1/webhooks/"+created.ID, nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, adminRequest("GET", "/api/v1/webhooks/"+created.ID, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"price"}`)
+	signature := Sign("s3cret", 1700000000, body)
+
+	if !Verify("s3cret", 1700000000, body, signature) {
+		t.Error("Expected signature to verify")
+	}
+	if Verify("s3cret", 1700000001, body, signature) || Verify("other", 1700000000, body, signature) {
+		t.Error("Expected signature to be bound to timestamp and secret")
+	}
+}
@@ -0,0 +1,228 @@
+// Package client is a Go client for a running btc-price-tracker. It follows
+// the price stream with automatic reconnects and resume, and wraps the REST
+// history endpoints.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// userAgent identifies the client in the tracker's request logs
+const userAgent = "btc-price-tracker-client"
+
+// Config configures a Client. Only BaseURL is required.
+type Config struct {
+	// BaseURL is the tracker's address, e.g. "https://btc.example.com"
+	BaseURL string
+	// APIKey is sent as a bearer token when authentication is enabled
+	APIKey string
+	// HTTPClient sends the requests; http.DefaultClient when nil. Its
+	// Timeout must be zero or streams are cut off after it.
+	HTTPClient *http.Client
+	// MinBackoff and MaxBackoff bound the delay between stream reconnects,
+	// which doubles after every failed attempt (default 1s and 30s)
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// IdleTimeout reconnects a stream that has received nothing for this
+	// long (default 2m). Negative disables it.
+	IdleTimeout time.Duration
+}
+
+// Client talks to one tracker. It is safe for concurrent use.
+type Client struct {
+	baseURL *url.URL
+	config  Config
+}
+
+// New returns a client for the tracker at config.BaseURL
+func New(config Config) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(config.BaseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if baseURL.Scheme != "http" && baseURL.Scheme != "https" {
+		return nil, fmt.Errorf("base URL must be http or https, got %q", config.BaseURL)
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = config.MinBackoff
+	}
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = 2 * time.Minute
+	}
+
+	return &Client{baseURL: baseURL, config: config}, nil
+}
+
+// APIError is returned for error responses from the tracker
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay requested by a 429 or 503 response
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("tracker returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("tracker returned %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// temporary reports whether retrying the request may succeed
+func (e *APIError) temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode >= 500
+}
+
+// History returns the events between from and to, inclusive and oldest
+// first, fetching as many pages as needed
+func (c *Client) History(ctx context.Context, from, to time.Time) ([]PriceUpdateEvent, error) {
+	var events []PriceUpdateEvent
+
+	query := url.Values{}
+	query.Set("to", strconv.FormatInt(to.Unix(), 10))
+	next := from.Unix()
+	for {
+		query.Set("from", strconv.FormatInt(next, 10))
+
+		var page struct {
+			Events []PriceUpdateEvent `json:"events"`
+			Next   int64              `json:"next"`
+		}
+		if err := c.get(ctx, "/api/v1/prices", query, &page); err != nil {
+			return nil, err
+		}
+		events = append(events, page.Events...)
+
+		if page.Next <= next {
+			return events, nil
+		}
+		next = page.Next
+	}
+}
+
+// Latest returns the latest stored event
+func (c *Client) Latest(ctx context.Context) (PriceUpdateEvent, error) {
+	var event PriceUpdateEvent
+	err := c.get(ctx, "/api/v1/prices/latest", nil, &event)
+	return event, err
+}
+
+// Stats returns the rolling 1h, 24h and 7d statistics
+func (c *Client) Stats(ctx context.Context) (PriceStats, error) {
+	var stats PriceStats
+	err := c.get(ctx, "/api/v1/stats", nil, &stats)
+	return stats, err
+}
+
+// Indicators returns indicator values after each event between from and to.
+// indicators is a comma-separated list such as "sma:20,rsi:14".
+func (c *Client) Indicators(ctx context.Context, indicators string, from, to time.Time) ([]IndicatorPoint, error) {
+	query := url.Values{}
+	query.Set("indicators", indicators)
+	query.Set("from", strconv.FormatInt(from.Unix(), 10))
+	query.Set("to", strconv.FormatInt(to.Unix(), 10))
+
+	var series struct {
+		Points []IndicatorPoint `json:"points"`
+	}
+	err := c.get(ctx, "/api/v1/indicators", query, &series)
+	return series.Points, err
+}
+
+// LatestIndicators returns indicator values after the latest event
+func (c *Client) LatestIndicators(ctx context.Context, indicators string) (IndicatorPoint, error) {
+	query := url.Values{}
+	query.Set("indicators", indicators)
+
+	var point IndicatorPoint
+	err := c.get(ctx, "/api/v1/indicators/latest", query, &point)
+	return point, err
+}
+
+// get decodes the JSON response of a GET request into target
+func (c *Client) get(ctx context.Context, path string, query url.Values, target any) error {
+	response, err := c.do(ctx, path, query, nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if err := json.NewDecoder(response.Body).Decode(target); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+// do sends a GET request and turns error statuses into an *APIError
+func (c *Client) do(ctx context.Context, path string, query url.Values, header http.Header) (*http.Response, error) {
+	target := c.baseURL.JoinPath(path)
+	target.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	request.Header.Set("User-Agent", userAgent)
+	if c.config.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+
+	response, err := c.config.HTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		return nil, newAPIError(response)
+	}
+	return response, nil
+}
+
+func newAPIError(response *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: response.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+	var decoded struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &decoded) == nil && decoded.Error != "" {
+		apiErr.Message = decoded.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// isPermanent reports whether err means reconnecting cannot succeed, such as
+// a rejected API key
+func isPermanent(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && !apiErr.temporary()
+}
@@ -0,0 +1,323 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tstojkovski/btc-price-tracker/internal/domain"
+	"github.com/tstojkovski/btc-price-tracker/internal/indicator"
+	"github.com/tstojkovski/btc-price-tracker/internal/service"
+	"github.com/tstojkovski/btc-price-tracker/internal/store"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := New(Config{BaseURL: server.URL, APIKey: "btk_test", MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSSEReader(t *testing.T) {
+	input := ": comment\n" +
+		"id: 1\ndata: {\"a\":1}\n\n" +
+		"event: alert\r\ndata: line1\r\ndata:line2\r\n\r\n" +
+		"event: empty\n\n" +
+		"data: last"
+	reader := newSSEReader(strings.NewReader(input))
+
+	expected := []sseEvent{
+		{ID: "1", Name: "message", Data: `{"a":1}`},
+		{ID: "1", Name: "alert", Data: "line1\nline2"},
+	}
+	for _, want := range expected {
+		got, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	}
+
+	// An event cut off by the end of the stream is not dispatched
+	if _, err := reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestStream_ResumesAfterDisconnect(t *testing.T) {
+	var connections atomic.Int32
+	lastEventIDs := make(chan string, 2)
+
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer btk_test" {
+			t.Errorf("Expected the API key as bearer token, got %q", r.Header.Get("Authorization"))
+		}
+		lastEventIDs <- r.Header.Get("Last-Event-ID")
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		if connections.Add(1) == 1 {
+			fmt.Fprint(w, "id: 100\ndata: {\"timestamp\":100,\"price\":\"69420.25\"}\n\n")
+			fmt.Fprint(w, "event: heartbeat\ndata: {\"timestamp\":150}\n\n")
+			fmt.Fprint(w, "id: 200\ndata: {\"timestamp\":200,\"price\":69421}\n\n")
+			return
+		}
+		// Replays 200 like a server without Last-Event-ID support
+		fmt.Fprint(w, "id: 200\ndata: {\"timestamp\":200,\"price\":\"69421\"}\n\n")
+		fmt.Fprint(w, "id: 300\ndata: {\"timestamp\":300,\"price\":\"69422.5\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var prices []string
+	var heartbeats []int64
+	var disconnects []error
+	err := client.Stream(ctx, StreamOptions{Since: 100}, Handler{
+		Price: func(event PriceUpdateEvent) {
+			prices = append(prices, fmt.Sprintf("%d=%s", event.Timestamp, event.Price))
+			if len(prices) == 3 {
+				cancel()
+			}
+		},
+		Heartbeat:    func(heartbeat Heartbeat) { heartbeats = append(heartbeats, heartbeat.Timestamp) },
+		Disconnected: func(err error, retryIn time.Duration) { disconnects = append(disconnects, err) },
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if strings.Join(prices, " ") != "100=69420.25 200=69421 300=69422.5" {
+		t.Errorf("Unexpected prices %v", prices)
+	}
+	if len(heartbeats) != 1 || heartbeats[0] != 150 {
+		t.Errorf("Unexpected heartbeats %v", heartbeats)
+	}
+	if len(disconnects) != 1 || !errors.Is(disconnects[0], errStreamClosed) {
+		t.Errorf("Expected one disconnect, got %v", disconnects)
+	}
+	if first, second := <-lastEventIDs, <-lastEventIDs; first != "" || second != "200" {
+		t.Errorf("Expected Last-Event-ID to resume after 200, got %q then %q", first, second)
+	}
+}
+
+func TestStream_Errors(t *testing.T) {
+	t.Run("Rejected key", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":"invalid API key"}`, http.StatusUnauthorized)
+		}))
+
+		err := client.Stream(context.Background(), StreamOptions{}, Handler{})
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "invalid API key" {
+			t.Errorf("Expected a 401 APIError, got %v", err)
+		}
+	})
+
+	t.Run("Retry-After", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "10")
+			http.Error(w, "stream limit reached", http.StatusTooManyRequests)
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var wait time.Duration
+		err := client.Stream(ctx, StreamOptions{}, Handler{Disconnected: func(err error, retryIn time.Duration) {
+			wait = retryIn
+			cancel()
+		}})
+		if !errors.Is(err, context.Canceled) || wait != 10*time.Second {
+			t.Errorf("Expected to wait 10s before retrying, got %v after %v", wait, err)
+		}
+	})
+
+	t.Run("Idle stream", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		client.config.IdleTimeout = 20 * time.Millisecond
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var disconnect error
+		client.Stream(ctx, StreamOptions{}, Handler{Disconnected: func(err error, retryIn time.Duration) {
+			disconnect = err
+			cancel()
+		}})
+		if disconnect == nil || !strings.Contains(disconnect.Error(), "no events for 20ms") {
+			t.Errorf("Expected an idle timeout, got %v", disconnect)
+		}
+	})
+
+	t.Run("Malformed event", func(t *testing.T) {
+		client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "id: 100\ndata: {\"timestamp\":100,\"price\":\"oops\"}\n\n")
+			fmt.Fprint(w, "id: 200\ndata: {\"timestamp\":200,\"price\":\"69421\"}\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		var malformed []string
+		var prices []int64
+		var disconnects int
+		client.Stream(ctx, StreamOptions{}, Handler{
+			Price: func(event PriceUpdateEvent) {
+				prices = append(prices, event.Timestamp)
+				cancel()
+			},
+			Malformed:    func(event string, err error) { malformed = append(malformed, event) },
+			Disconnected: func(err error, retryIn time.Duration) { disconnects++ },
+		})
+		if len(malformed) != 1 || malformed[0] != "message" {
+			t.Errorf("Expected one malformed message, got %v", malformed)
+		}
+		if len(prices) != 1 || prices[0] != 200 || disconnects != 0 {
+			t.Errorf("Expected the stream to go on with 200, got %v after %d disconnects", prices, disconnects)
+		}
+	})
+}
+
+func TestClient_AgainstTracker(t *testing.T) {
+	memStore := store.NewMemoryStore(10)
+	for _, timestamp := range []int64{100, 200, 300} {
+		memStore.Store(domain.PriceUpdateEvent{Timestamp: timestamp, Price: domain.NewPrice(float64(timestamp) + 0.5)})
+	}
+	updates := make(chan domain.PriceUpdateEvent, 1)
+	broadcastService := service.NewBroadcastService(memStore, updates)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	broadcastService.Start(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/prices/stream", broadcastService.SSEHandler)
+	service.NewHistoryAPI(memStore).RegisterRoutes(mux)
+	client := newTestClient(t, mux)
+
+	history, err := client.History(ctx, time.Unix(150, 0), time.Unix(300, 0))
+	if err != nil || len(history) != 2 || history[0].Price.String() != "200.5" {
+		t.Errorf("Unexpected history %v (%v)", history, err)
+	}
+	latest, err := client.Latest(ctx)
+	if err != nil || latest.Timestamp != 300 {
+		t.Errorf("Unexpected latest event %v (%v)", latest, err)
+	}
+
+	prices, errs := client.Prices(ctx, StreamOptions{Since: 200})
+	for _, expected := range []int64{200, 300} {
+		if event := <-prices; event.Timestamp != expected {
+			t.Errorf("Expected replayed event %d, got %d", expected, event.Timestamp)
+		}
+	}
+	updates <- domain.PriceUpdateEvent{Timestamp: 400, Price: domain.NewPrice(400.5)}
+	if event := <-prices; event.Timestamp != 400 || event.Price.String() != "400.5" {
+		t.Errorf("Expected the live event, got %v", event)
+	}
+
+	cancel()
+	for range prices {
+	}
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestHistory_Pages(t *testing.T) {
+	var requests []string
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		if r.URL.Query().Get("from") == "100" {
+			fmt.Fprint(w, `{"events":[{"timestamp":100,"price":"1"},{"timestamp":200,"price":"2"}],"next":300}`)
+			return
+		}
+		fmt.Fprint(w, `{"events":[{"timestamp":300,"price":"3"}]}`)
+	}))
+
+	events, err := client.History(context.Background(), time.Unix(100, 0), time.Unix(400, 0))
+	if err != nil || len(events) != 3 || events[2].Timestamp != 300 {
+		t.Errorf("Unexpected events %v (%v)", events, err)
+	}
+	if strings.Join(requests, " ") != "from=100&to=400 from=300&to=400" {
+		t.Errorf("Unexpected requests %v", requests)
+	}
+}
+
+func TestNew_InvalidBaseURL(t *testing.T) {
+	if _, err := New(Config{BaseURL: "localhost:8082"}); err == nil {
+		t.Error("Expected an error for a base URL without scheme")
+	}
+}
+
+func TestWireTypes_MatchTracker(t *testing.T) {
+	price, err := domain.ParsePrice("69420.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every field the tracker sends survives decoding into the client types
+	tests := []struct {
+		name   string
+		sent   any
+		target any
+	}{
+		{"Price", domain.PriceUpdateEvent{Timestamp: 100, Price: price, Backfilled: true}, &PriceUpdateEvent{}},
+		{"Alert", domain.Alert{RuleID: "r1", Owner: "alice", Type: domain.AlertPercentChange, Timestamp: 100, Price: price, Threshold: 1, Change: 2.5, Message: "moved"}, &Alert{}},
+		{"Heartbeat", domain.Heartbeat{Timestamp: 100}, &Heartbeat{}},
+		{"Stats", domain.PriceStats{Timestamp: 100, Windows: []domain.WindowStats{{Window: "1h", Count: 2, Open: 1, High: 2, Low: 0.5, Close: 1.5, Change: 50, StdDev: 0.1, Volatility: 3}}}, &PriceStats{}},
+		{"Indicators", indicator.Point{Timestamp: 100, Price: price, Values: map[string]indicator.Value{"bb:20": {Value: 1, Upper: 2, Lower: 0.5}}}, &IndicatorPoint{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sent, err := json.Marshal(tc.sent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(sent, tc.target); err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := json.Marshal(tc.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(decoded) != string(sent) {
+				t.Errorf("Expected %s, got %s", sent, decoded)
+			}
+		})
+	}
+}
+
+func TestPrice_UnmarshalJSON(t *testing.T) {
+	for _, input := range []string{`"69420.25"`, `69420.25`} {
+		var price Price
+		if err := json.Unmarshal([]byte(input), &price); err != nil || price.String() != "69420.25" {
+			t.Errorf("%s: expected 69420.25, got %s (%v)", input, price, err)
+		}
+	}
+	for _, input := range []string{`"high"`, `"123`, `""`} {
+		var price Price
+		if err := json.Unmarshal([]byte(input), &price); err == nil {
+			t.Errorf("%s: expected an error, got %s", input, price)
+		}
+	}
+}
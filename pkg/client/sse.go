@@ -0,0 +1,74 @@
+package client
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxEventSize bounds a single line of the stream
+const maxEventSize = 1 << 20
+
+// sseEvent is one dispatched Server-Sent Event. Name is "message" for
+// unnamed events.
+type sseEvent struct {
+	ID   string
+	Name string
+	Data string
+}
+
+// sseReader parses the text/event-stream format
+type sseReader struct {
+	scanner *bufio.Scanner
+	// lastID persists across events as the SSE format requires
+	lastID string
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxEventSize)
+	return &sseReader{scanner: scanner}
+}
+
+// Next returns the next event, or io.EOF once the stream ends. Comments and
+// events without data are skipped.
+func (r *sseReader) Next() (sseEvent, error) {
+	var name string
+	var data []string
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+
+		if line == "" {
+			if data == nil {
+				name = ""
+				continue
+			}
+			if name == "" {
+				name = "message"
+			}
+			return sseEvent{ID: r.lastID, Name: name, Data: strings.Join(data, "\n")}, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			name = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastID = value
+			}
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return sseEvent{}, err
+	}
+	return sseEvent{}, io.EOF
+}
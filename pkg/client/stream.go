@@ -0,0 +1,229 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// errStreamClosed is reported when the tracker ends a stream, e.g. on shutdown
+var errStreamClosed = errors.New("stream closed by the tracker")
+
+// StreamOptions selects what a stream replays and carries
+type StreamOptions struct {
+	// Since replays stored events from this Unix timestamp before live
+	// updates. 0 starts with the latest event.
+	Since int64
+	// Stats asks for rolling statistics after each update
+	Stats bool
+	// Indicators asks for indicator values after each update, e.g. "sma:20,rsi:14"
+	Indicators string
+}
+
+// Handler receives stream events. Nil callbacks are skipped. Callbacks run
+// on the stream's goroutine, one at a time, and delay the events behind them.
+type Handler struct {
+	Price      func(PriceUpdateEvent)
+	Alert      func(Alert)
+	Heartbeat  func(Heartbeat)
+	Stats      func(PriceStats)
+	Indicators func(IndicatorPoint)
+	// Disconnected is called with the error that ended a connection before
+	// waiting retryIn to reconnect
+	Disconnected func(err error, retryIn time.Duration)
+	// Malformed is called with events that could not be decoded. They are
+	// skipped and the stream goes on.
+	Malformed func(event string, err error)
+}
+
+// streamState carries the resume position across reconnects
+type streamState struct {
+	// lastTimestamp is the timestamp of the last price event delivered
+	lastTimestamp int64
+	// received is set once the current connection delivered an event
+	received bool
+}
+
+// Stream follows /prices/stream until ctx is cancelled, calling handler for
+// every event. Dropped connections are reopened with exponential backoff and
+// resume after the last price event through Last-Event-ID, so no event is
+// delivered twice. Stream returns ctx.Err() once cancelled, or an *APIError
+// when the tracker refuses the stream for good, e.g. for an invalid API key.
+func (c *Client) Stream(ctx context.Context, options StreamOptions, handler Handler) error {
+	var state streamState
+	backoff := c.config.MinBackoff
+
+	for {
+		state.received = false
+		err := c.stream(ctx, options, handler, &state)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if isPermanent(err) {
+			return err
+		}
+
+		if state.received {
+			backoff = c.config.MinBackoff
+		}
+		wait := backoff/2 + rand.N(backoff/2+1)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+			wait = apiErr.RetryAfter
+		}
+		if handler.Disconnected != nil {
+			handler.Disconnected(err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, c.config.MaxBackoff)
+	}
+}
+
+// Prices follows the stream like Stream and delivers price events on the
+// returned channel, which is closed when the stream ends. The error that
+// ended it is then sent on the error channel.
+func (c *Client) Prices(ctx context.Context, options StreamOptions) (<-chan PriceUpdateEvent, <-chan error) {
+	prices := make(chan PriceUpdateEvent, 64)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		err := c.Stream(ctx, options, Handler{Price: func(event PriceUpdateEvent) {
+			select {
+			case prices <- event:
+			case <-ctx.Done():
+			}
+		}})
+		close(prices)
+		errs <- err
+	}()
+
+	return prices, errs
+}
+
+// stream reads one connection until it fails or ends
+func (c *Client) stream(ctx context.Context, options StreamOptions, handler Handler, state *streamState) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	query := url.Values{}
+	if options.Since > 0 {
+		query.Set("since", strconv.FormatInt(options.Since, 10))
+	}
+	if options.Stats {
+		query.Set("stats", "true")
+	}
+	if options.Indicators != "" {
+		query.Set("indicators", options.Indicators)
+	}
+	header := http.Header{}
+	header.Set("Accept", "text/event-stream")
+	if state.lastTimestamp > 0 {
+		header.Set("Last-Event-ID", strconv.FormatInt(state.lastTimestamp, 10))
+	}
+
+	response, err := c.do(ctx, "/prices/stream", query, header)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// A stream that goes quiet is reopened, since heartbeats and price
+	// events keep a healthy one busy
+	idle := c.config.IdleTimeout
+	var timer *time.Timer
+	if idle > 0 {
+		timer = time.AfterFunc(idle, cancel)
+	}
+
+	reader := newSSEReader(response.Body)
+	for {
+		event, err := reader.Next()
+		if timer != nil && !timer.Stop() {
+			return fmt.Errorf("no events for %s", idle)
+		}
+		if errors.Is(err, io.EOF) {
+			return errStreamClosed
+		}
+		if err != nil {
+			return err
+		}
+
+		dispatch(event, handler, state)
+		if timer != nil {
+			timer.Reset(idle)
+		}
+	}
+}
+
+// dispatch decodes an event and passes it to its callback. Events that
+// cannot be decoded are reported to Malformed and skipped, since a resumed
+// stream would only deliver them again. Event types added to the tracker
+// later are ignored.
+func dispatch(event sseEvent, handler Handler, state *streamState) {
+	state.received = true
+
+	switch event.Name {
+	case "message":
+		var price PriceUpdateEvent
+		if !decodeEvent(event, &price, handler) {
+			return
+		}
+		// Servers without Last-Event-ID support replay from since again.
+		// The tracker keeps one event per second, so timestamps are unique.
+		if price.Timestamp <= state.lastTimestamp {
+			return
+		}
+		state.lastTimestamp = price.Timestamp
+		if handler.Price != nil {
+			handler.Price(price)
+		}
+	case "alert":
+		var alert Alert
+		if handler.Alert != nil && decodeEvent(event, &alert, handler) {
+			handler.Alert(alert)
+		}
+	case "heartbeat":
+		var heartbeat Heartbeat
+		if handler.Heartbeat != nil && decodeEvent(event, &heartbeat, handler) {
+			handler.Heartbeat(heartbeat)
+		}
+	case "stats":
+		var stats PriceStats
+		if handler.Stats != nil && decodeEvent(event, &stats, handler) {
+			handler.Stats(stats)
+		}
+	case "indicators":
+		var point IndicatorPoint
+		if handler.Indicators != nil && decodeEvent(event, &point, handler) {
+			handler.Indicators(point)
+		}
+	}
+}
+
+// decodeEvent decodes the event's data into target and reports whether it
+// could, passing the error to the handler's Malformed callback otherwise
+func decodeEvent(event sseEvent, target any, handler Handler) bool {
+	err := json.Unmarshal([]byte(event.Data), target)
+	if err == nil {
+		return true
+	}
+	if handler.Malformed != nil {
+		handler.Malformed(event.Name, fmt.Errorf("decoding %s event: %w", event.Name, err))
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceUpdateEvent is a stored price. The tracker keeps at most one event per
+// second, so Timestamp identifies it.
+type PriceUpdateEvent struct {
+	Timestamp int64 `json:"timestamp"`
+	Price     Price `json:"price"`
+	// Backfilled marks events recovered from exchange history rather than live polling
+	Backfilled bool `json:"backfilled,omitempty"`
+}
+
+// Alert is a fired alert rule
+type Alert struct {
+	RuleID string `json:"rule_id"`
+	Owner  string `json:"owner,omitempty"`
+	// Type is the rule type: above, below, crosses or percent_change
+	Type      string  `json:"type"`
+	Timestamp int64   `json:"timestamp"`
+	Price     Price   `json:"price"`
+	Threshold float64 `json:"threshold,omitempty"`
+	// Change is the percent move that fired a percent change rule
+	Change  float64 `json:"change,omitempty"`
+	Message string  `json:"message"`
+}
+
+// Heartbeat marks a fetch whose price was unchanged and therefore neither
+// stored nor broadcast
+type Heartbeat struct {
+	Timestamp int64 `json:"timestamp"`
+}
+
+// PriceStats holds the rolling statistics after the event at Timestamp
+type PriceStats struct {
+	Timestamp int64         `json:"timestamp"`
+	Windows   []WindowStats `json:"windows"`
+}
+
+// WindowStats summarizes the prices in a rolling window. Change, StdDev and
+// Volatility are zero until the window holds two events.
+type WindowStats struct {
+	// Window is the window length, e.g. "24h"
+	Window string  `json:"window"`
+	Count  int     `json:"count"`
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	// Change is the percent change from Open to Close
+	Change float64 `json:"change"`
+	// StdDev is the population standard deviation of the prices in USD
+	StdDev float64 `json:"stddev"`
+	// Volatility is the realized volatility over the window in percent
+	Volatility float64 `json:"volatility"`
+}
+
+// IndicatorPoint holds indicator values after the event at Timestamp, keyed
+// by the requested spec such as "sma:20"
+type IndicatorPoint struct {
+	Timestamp int64                     `json:"timestamp"`
+	Price     Price                     `json:"price"`
+	Values    map[string]IndicatorValue `json:"values"`
+}
+
+// IndicatorValue is one indicator reading. Upper and Lower are set for bands.
+type IndicatorValue struct {
+	Value float64 `json:"value"`
+	Upper float64 `json:"upper,omitempty"`
+	Lower float64 `json:"lower,omitempty"`
+}
+
+// Price is an exact decimal USD price. The zero value is a price of 0.
+type Price struct {
+	value decimal.Decimal
+}
+
+// ParsePrice parses a decimal string such as "69420.25"
+func ParsePrice(s string) (Price, error) {
+	value, err := decimal.NewFromString(s)
+	if err != nil {
+		return Price{}, fmt.Errorf("invalid price %q: %w", s, err)
+	}
+	return Price{value: value}, nil
+}
+
+// Decimal returns the exact value
+func (p Price) Decimal() decimal.Decimal {
+	return p.value
+}
+
+// Float64 returns the nearest float
+func (p Price) Float64() float64 {
+	return p.value.InexactFloat64()
+}
+
+// String returns the exact decimal representation
+func (p Price) String() string {
+	return p.value.String()
+}
+
+// Sign returns -1, 0 or 1 for negative, zero and positive prices
+func (p Price) Sign() int {
+	return p.value.Sign()
+}
+
+// Cmp compares p and other, returning -1, 0 or 1
+func (p Price) Cmp(other Price) int {
+	return p.value.Cmp(other.value)
+}
+
+// Sub returns the exact difference p - other
+func (p Price) Sub(other Price) Price {
+	return Price{value: p.value.Sub(other.value)}
+}
+
+// MarshalJSON encodes the price as a decimal string, as the tracker does
+func (p Price) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + p.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts both the string and the number form, since the
+// tracker can be configured to send numbers
+func (p *Price) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*p = Price{}
+		return nil
+	}
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+
+	parsed, err := ParsePrice(string(data))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}